@@ -0,0 +1,268 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"yudhiesh/snippetbox/pkg/forms"
+	"yudhiesh/snippetbox/pkg/models"
+)
+
+// The name of the manifest in an export archive, which lists the snippets
+// and where their files are
+const manifestName = "manifest.json"
+
+// The largest archive which can be imported, the most its files may hold
+// once decompressed and the most snippets it may hold
+const (
+	maxImportSize     = 10 << 20
+	maxImportContent  = 20 << 20
+	maxImportSnippets = 500
+)
+
+// The manifest of an export archive
+type manifest struct {
+	Exported time.Time          `json:"exported"`
+	Snippets []*manifestSnippet `json:"snippets"`
+}
+
+// A snippet in the manifest of an export archive. Snippets which never expire
+// have no expiry time. Passwords aren't exported, so protected snippets are
+// only marked as such.
+type manifestSnippet struct {
+	Title            string          `json:"title"`
+	Visibility       string          `json:"visibility"`
+	BurnAfterReading bool            `json:"burn_after_reading,omitempty"`
+	Protected        bool            `json:"protected,omitempty"`
+	Created          time.Time       `json:"created"`
+	Expires          *time.Time      `json:"expires,omitempty"`
+	Tags             []string        `json:"tags"`
+	Files            []*manifestFile `json:"files"`
+}
+
+// A file of a snippet in the manifest of an export archive. The path is where
+// its content is in the archive.
+type manifestFile struct {
+	Name     string `json:"name"`
+	Language string `json:"language,omitempty"`
+	Path     string `json:"path"`
+}
+
+// Writes snippets to w as a zip archive holding the files of each snippet in
+// a directory of its own, followed by the manifest. Each snippet is loaded
+// with its files by load just before they are written, so that the files of
+// only one snippet are held at a time, and is left out if load returns nil.
+// The files are stamped with the time their snippet was created.
+func writeExport(w io.Writer, snippets []*models.Snippet, load func(*models.Snippet) (*models.Snippet, error), now time.Time) error {
+	zw := zip.NewWriter(w)
+	m := &manifest{Exported: now.UTC(), Snippets: []*manifestSnippet{}}
+	for _, listed := range snippets {
+		s, err := load(listed)
+		if err != nil {
+			return err
+		}
+		if s == nil {
+			continue
+		}
+		ms := &manifestSnippet{
+			Title:            s.Title,
+			Visibility:       s.Visibility,
+			BurnAfterReading: s.BurnAfterReading,
+			Protected:        s.Protected,
+			Created:          s.Created.UTC(),
+			Tags:             append([]string{}, s.Tags...),
+		}
+		if !s.Expires.IsZero() {
+			expires := s.Expires.UTC()
+			ms.Expires = &expires
+		}
+		dir := fmt.Sprintf("%d-%s", s.ID, titleSlug(s.Title))
+		for _, f := range s.Files {
+			mf := &manifestFile{Name: f.Name, Language: f.Language, Path: dir + "/" + f.Name}
+			fw, err := zw.CreateHeader(&zip.FileHeader{
+				Name:     mf.Path,
+				Method:   zip.Deflate,
+				Modified: s.Created.UTC(),
+			})
+			if err != nil {
+				return err
+			}
+			if _, err = io.WriteString(fw, f.Content); err != nil {
+				return err
+			}
+			ms.Files = append(ms.Files, mf)
+		}
+		m.Snippets = append(m.Snippets, ms)
+	}
+
+	fw, err := zw.CreateHeader(&zip.FileHeader{Name: manifestName, Method: zip.Deflate, Modified: now.UTC()})
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(fw)
+	enc.SetIndent("", "  ")
+	if err = enc.Encode(m); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// An importError is a problem with an archive as a whole, which is shown to
+// the user
+type importError string
+
+func (e importError) Error() string {
+	return string(e)
+}
+
+// A snippet read from an import archive, as the create form it would have
+// been entered with. Skipped holds the reason a snippet is left out of the
+// import, such as having expired since it was exported.
+type importEntry struct {
+	Title   string
+	Form    *forms.Form
+	Skipped string
+}
+
+// Reads the snippets listed in the manifest of an export archive, each as the
+// values of a create form. Problems with the archive as a whole are returned
+// as an importError, and the files missing from a snippet are added to the
+// errors of its form. Snippets which have expired by now are skipped.
+func readImport(r io.ReaderAt, size int64, now time.Time) ([]*importEntry, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, importError("This file isn't a zip archive")
+	}
+	files := map[string]*zip.File{}
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+
+	remaining := int64(maxImportContent)
+	read := func(name string) ([]byte, error) {
+		f, ok := files[name]
+		if !ok {
+			return nil, nil
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, importError(fmt.Sprintf("The file %s can't be read", name))
+		}
+		defer rc.Close()
+		b, err := ioutil.ReadAll(io.LimitReader(rc, remaining+1))
+		if err != nil {
+			return nil, importError(fmt.Sprintf("The file %s can't be read", name))
+		}
+		remaining -= int64(len(b))
+		if remaining < 0 {
+			return nil, importError(fmt.Sprintf("The archive is too large once decompressed (maximum is %d MB)", maxImportContent>>20))
+		}
+		return b, nil
+	}
+
+	b, err := read(manifestName)
+	if err != nil {
+		return nil, err
+	}
+	if b == nil {
+		return nil, importError("The archive has no " + manifestName)
+	}
+	var m manifest
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, importError(fmt.Sprintf("The %s is invalid", manifestName))
+	}
+	switch {
+	case len(m.Snippets) == 0:
+		return nil, importError("The archive has no snippets")
+	case len(m.Snippets) > maxImportSnippets:
+		return nil, importError(fmt.Sprintf("The archive has too many snippets (maximum is %d)", maxImportSnippets))
+	}
+
+	entries := make([]*importEntry, len(m.Snippets))
+	for i, ms := range m.Snippets {
+		if ms == nil {
+			ms = &manifestSnippet{}
+		}
+		title := ms.Title
+		if title == "" {
+			title = fmt.Sprintf("Snippet %d", i+1)
+		}
+		if ms.Expires != nil && !ms.Expires.After(now) {
+			entries[i] = &importEntry{
+				Title:   title,
+				Form:    forms.New(url.Values{}),
+				Skipped: fmt.Sprintf("This snippet expired on %s", humanDate(*ms.Expires)),
+			}
+			continue
+		}
+		values := url.Values{}
+		values.Set("title", ms.Title)
+		values.Set("visibility", ms.Visibility)
+		// The passwords of protected snippets aren't exported, so they are
+		// kept from everyone else instead
+		if ms.Protected {
+			values.Set("visibility", models.VisibilityPrivate)
+		}
+		if ms.BurnAfterReading {
+			values.Set("burn", "true")
+		}
+		values.Set("tags", strings.Join(ms.Tags, ", "))
+		if ms.Expires == nil {
+			values.Set("expires", "never")
+		} else {
+			// Rounded up to the minute, which the form holds times to, so
+			// that a snippet expiring within the current minute is kept
+			expires := ms.Expires.UTC()
+			if rounded := expires.Truncate(time.Minute); !rounded.Equal(expires) {
+				expires = rounded.Add(time.Minute)
+			}
+			values.Set("expires", customExpiry)
+			values.Set("expires_at", expires.Format(customExpiryFormat))
+		}
+
+		var missing []string
+		for _, mf := range ms.Files {
+			if mf == nil {
+				continue
+			}
+			content, err := read(path.Clean(mf.Path))
+			if err != nil {
+				return nil, err
+			}
+			if content == nil {
+				missing = append(missing, mf.Path)
+				continue
+			}
+			// A language which was detected rather than picked on the create
+			// form, such as "docker", is detected again when it is imported
+			language := mf.Language
+			if !permitted(language, languageValues()) {
+				language = ""
+			}
+			values.Add("filename", mf.Name)
+			values.Add("language", language)
+			values.Add("content", string(content))
+		}
+
+		entry := &importEntry{Title: title, Form: forms.New(values)}
+		for _, p := range missing {
+			entry.Form.Errors.Add("files", fmt.Sprintf("The archive has no file %s", p))
+		}
+		entries[i] = entry
+	}
+	return entries, nil
+}
+
+// Reports whether err is a problem with an import archive as a whole
+func isImportError(err error) bool {
+	var ie importError
+	return errors.As(err, &ie)
+}
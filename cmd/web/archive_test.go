@@ -0,0 +1,451 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"yudhiesh/snippetbox/pkg/models"
+)
+
+// Returns the snippet itself, as the snippets passed to writeExport in the
+// tests already hold their files
+func loadListed(s *models.Snippet) (*models.Snippet, error) {
+	return s, nil
+}
+
+// Returns an archive holding the given files
+func makeZip(t *testing.T, files map[string]string) []byte {
+	var b bytes.Buffer
+	zw := zip.NewWriter(&b)
+	for name, content := range files {
+		fw, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func TestWriteExport(t *testing.T) {
+	now := time.Date(2021, 3, 14, 15, 9, 26, 0, time.UTC)
+	expires := now.AddDate(0, 0, 7).Truncate(time.Minute)
+	snippets := []*models.Snippet{
+		{
+			ID:         7,
+			Title:      "Hello service",
+			Visibility: models.VisibilityUnlisted,
+			Tags:       []string{"go", "docker"},
+			Created:    now.AddDate(0, 0, -1),
+			Expires:    expires,
+			Files: []*models.File{
+				{Name: "Dockerfile", Content: "FROM golang"},
+				{Name: "main.go", Language: "go", Content: "package main"},
+			},
+		},
+		{
+			ID:         8,
+			Title:      "Secret",
+			Visibility: models.VisibilityPublic,
+			Protected:  true,
+			Created:    now.AddDate(0, 0, -2),
+			Files:      []*models.File{{Name: "file1", Content: "hunter2"}},
+		},
+		// Left out by load
+		{ID: 9, Title: "Expired"},
+	}
+	load := func(s *models.Snippet) (*models.Snippet, error) {
+		if s.ID == 9 {
+			return nil, nil
+		}
+		return s, nil
+	}
+	var b bytes.Buffer
+	if err := writeExport(&b, snippets, load, now); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := zip.NewReader(bytes.NewReader(b.Bytes()), int64(b.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, zf := range r.File {
+		names = append(names, zf.Name)
+	}
+	wantNames := []string{"7-hello-service/Dockerfile", "7-hello-service/main.go", "8-secret/file1", manifestName}
+	if !reflect.DeepEqual(names, wantNames) {
+		t.Fatalf("want %q; got %q", wantNames, names)
+	}
+	rc, err := r.File[3].Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	var m manifest
+	if err := json.NewDecoder(rc).Decode(&m); err != nil {
+		t.Fatal(err)
+	}
+	if !m.Exported.Equal(now) || len(m.Snippets) != 2 {
+		t.Fatalf("want 2 snippets exported at %v; got %d at %v", now, len(m.Snippets), m.Exported)
+	}
+	if ms := m.Snippets[0]; ms.Expires == nil || !ms.Expires.Equal(expires) || !reflect.DeepEqual(ms.Tags, []string{"go", "docker"}) || ms.Files[1].Language != "go" {
+		t.Errorf("want the expiry, tags and languages of the first snippet; got %+v", ms)
+	}
+	if ms := m.Snippets[1]; ms.Expires != nil || !ms.Protected {
+		t.Errorf("want the second snippet protected and never expiring; got %+v", ms)
+	}
+
+	// Importing the archive gives back the snippets as they were created,
+	// other than the password
+	entries, err := readImport(bytes.NewReader(b.Bytes()), int64(b.Len()), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("want 2 entries; got %d", len(entries))
+	}
+	want := []url.Values{
+		{
+			"title":      {"Hello service"},
+			"visibility": {"unlisted"},
+			"tags":       {"go, docker"},
+			"expires":    {customExpiry},
+			"expires_at": {expires.Format(customExpiryFormat)},
+			"filename":   {"Dockerfile", "main.go"},
+			"language":   {"", "go"},
+			"content":    {"FROM golang", "package main"},
+		},
+		{
+			"title":      {"Secret"},
+			"visibility": {"private"},
+			"tags":       {""},
+			"expires":    {"never"},
+			"filename":   {"file1"},
+			"language":   {""},
+			"content":    {"hunter2"},
+		},
+	}
+	for i, e := range entries {
+		if !reflect.DeepEqual(e.Form.Values, want[i]) {
+			t.Errorf("entry %d: want %v; got %v", i, want[i], e.Form.Values)
+		}
+		if !e.Form.Valid() {
+			t.Errorf("entry %d: want no errors; got %v", i, e.Form.Errors)
+		}
+	}
+}
+
+func TestReadImport(t *testing.T) {
+	tests := []struct {
+		name    string
+		archive []byte
+		wantErr string
+	}{
+		{"Not A Zip", []byte("snippets"), "This file isn't a zip archive"},
+		{"No Manifest", makeZip(t, map[string]string{"1-a/file1": "a"}), "The archive has no manifest.json"},
+		{"Invalid Manifest", makeZip(t, map[string]string{manifestName: "{"}), "The manifest.json is invalid"},
+		{"No Snippets", makeZip(t, map[string]string{manifestName: `{"snippets": []}`}), "The archive has no snippets"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := readImport(bytes.NewReader(tt.archive), int64(len(tt.archive)), time.Now())
+			if !isImportError(err) || err.Error() != tt.wantErr {
+				t.Errorf("want %q; got %v", tt.wantErr, err)
+			}
+		})
+	}
+
+	t.Run("Missing File", func(t *testing.T) {
+		archive := makeZip(t, map[string]string{
+			manifestName: `{"snippets": [{"title": "Gone", "files": [{"name": "file1", "path": "1-gone/file1"}]}]}`,
+		})
+		entries, err := readImport(bytes.NewReader(archive), int64(len(archive)), time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := entries[0].Form.Errors.Get("files"); got != "The archive has no file 1-gone/file1" {
+			t.Errorf("want the missing file reported; got %q", got)
+		}
+	})
+}
+
+func TestReadImportExpiry(t *testing.T) {
+	now := time.Date(2021, 3, 14, 15, 9, 26, 0, time.UTC)
+	file := []*models.File{{Name: "file1", Content: "An old silent pond..."}}
+	snippets := []*models.Snippet{
+		{ID: 1, Title: "Expired", Expires: now.Add(-time.Hour), Files: file},
+		{ID: 2, Title: "Expiring", Expires: now.Add(20 * time.Second), Files: file},
+	}
+	var b bytes.Buffer
+	if err := writeExport(&b, snippets, loadListed, now.AddDate(0, 0, -7)); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := readImport(bytes.NewReader(b.Bytes()), int64(b.Len()), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "This snippet expired on 14 Mar 2021 at 14:09"; entries[0].Skipped != want {
+		t.Errorf("expired: want skipped with %q; got %q", want, entries[0].Skipped)
+	}
+	// Rounded up rather than down to a time which has passed
+	if got := entries[1].Form.Get("expires_at"); entries[1].Skipped != "" || got != "2021-03-14T15:10" {
+		t.Errorf("expiring: want kept until 2021-03-14T15:10; got %q skipped with %q", got, entries[1].Skipped)
+	}
+}
+
+func TestExportSnippets(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	if code, headers, _ := ts.get(t, "/user/export"); code != http.StatusSeeOther || headers.Get("Location") != "/user/login" {
+		t.Errorf("anonymous: want a redirect to /user/login; got %d to %q", code, headers.Get("Location"))
+	}
+
+	ts.login(t)
+	code, headers, body := ts.get(t, "/user/export")
+	if code != http.StatusOK {
+		t.Fatalf("want %d; got %d", http.StatusOK, code)
+	}
+	if ct := headers.Get("Content-Type"); ct != "application/zip" {
+		t.Errorf("want a zip archive; got %q", ct)
+	}
+	if d := headers.Get("Content-Disposition"); d != "attachment; filename=snippets.zip" {
+		t.Errorf("want an attachment; got %q", d)
+	}
+	r, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, zf := range r.File {
+		names = append(names, zf.Name)
+	}
+	if want := []string{"1-an-old-silent-pond/file1", manifestName}; !reflect.DeepEqual(names, want) {
+		t.Errorf("want %q; got %q", want, names)
+	}
+}
+
+func TestImportSnippets(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+	_, _, body := ts.get(t, "/user/import")
+	csrfToken := extractCSRFToken(t, body)
+
+	var valid bytes.Buffer
+	if err := writeExport(&valid, []*models.Snippet{{
+		ID:         1,
+		Title:      "An old silent pond",
+		Visibility: models.VisibilityPublic,
+		Files:      []*models.File{{Name: "file1", Content: "An old silent pond..."}},
+	}}, loadListed, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	invalid := makeZip(t, map[string]string{
+		"1-a/file1":  "",
+		manifestName: `{"snippets": [{"title": "", "visibility": "everyone", "files": [{"name": "file1", "path": "1-a/file1"}]}]}`,
+	})
+
+	tests := []struct {
+		name         string
+		archive      []byte
+		wantCode     int
+		wantLocation string
+		wantBody     []byte
+	}{
+		{"Valid", valid.Bytes(), http.StatusSeeOther, "/user/profile", nil},
+		{"Not A Zip", []byte("snippets"), http.StatusOK, "", []byte("This file isn&#39;t a zip archive")},
+		{"Invalid Entry", invalid, http.StatusOK, "", []byte("<td>Snippet 1</td>")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{"csrf_token": {csrfToken}}
+			code, headers, body := ts.postFile(t, "/user/import", form, "archive", "snippets.zip", tt.archive)
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+			if loc := headers.Get("Location"); loc != tt.wantLocation {
+				t.Errorf("want location %q; got %q", tt.wantLocation, loc)
+			}
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestImportExpiredJourney(t *testing.T) {
+	app := newMemoryTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ctx := context.Background()
+	if err := app.users.Insert(ctx, "Alice", "alice@example.com", "validPa$$word"); err != nil {
+		t.Fatal(err)
+	}
+	_, _, body := ts.get(t, "/user/login")
+	form := url.Values{}
+	form.Add("email", "alice@example.com")
+	form.Add("password", "validPa$$word")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+	ts.postForm(t, "/user/login", form)
+	_, _, body = ts.get(t, "/user/import")
+	form = url.Values{"csrf_token": {extractCSRFToken(t, body)}}
+
+	// An archive exported a month ago, since when one snippet has expired and
+	// another is about to
+	file := []*models.File{{Name: "file1", Content: "An old silent pond..."}}
+	var archive bytes.Buffer
+	if err := writeExport(&archive, []*models.Snippet{
+		{ID: 1, Title: "Expired", Visibility: "public", Expires: time.Now().AddDate(0, 0, -1), Files: file},
+		{ID: 2, Title: "Expiring", Visibility: "public", Expires: time.Now().Add(time.Second), Files: file},
+		{ID: 3, Title: "Kept", Visibility: "public", Expires: nextWeek, Files: file},
+	}, loadListed, time.Now().AddDate(0, -1, 0)); err != nil {
+		t.Fatal(err)
+	}
+	code, headers, _ := ts.postFile(t, "/user/import", form, "archive", "snippets.zip", archive.Bytes())
+	if code != http.StatusSeeOther || headers.Get("Location") != "/user/profile" {
+		t.Fatalf("import: want a redirect to /user/profile; got %d to %q", code, headers.Get("Location"))
+	}
+	_, _, body = ts.get(t, "/user/profile")
+	if want := "Imported 2 snippets! 1 snippet had expired and was skipped."; !bytes.Contains(body, []byte(want)) {
+		t.Errorf("want the flash %q", want)
+	}
+	snippets, err := app.snippets.ByUser(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snippets) != 2 {
+		t.Errorf("want 2 imported snippets; got %d", len(snippets))
+	}
+}
+
+func TestImportJourney(t *testing.T) {
+	app := newMemoryTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ctx := context.Background()
+	for _, name := range []string{"Alice", "Bob"} {
+		if err := app.users.Insert(ctx, name, strings.ToLower(name)+"@example.com", "validPa$$word"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := app.snippets.Insert(ctx, 1, "Secret", oneFile("hunter2", ""), time.Time{}, "public", false, "pa$$word", nil); err != nil {
+		t.Fatal(err)
+	}
+	login := func(email string) string {
+		_, _, body := ts.get(t, "/user/login")
+		form := url.Values{}
+		form.Add("email", email)
+		form.Add("password", "validPa$$word")
+		form.Add("csrf_token", extractCSRFToken(t, body))
+		ts.postForm(t, "/user/login", form)
+		_, _, body = ts.get(t, "/user/import")
+		return extractCSRFToken(t, body)
+	}
+
+	// Alice creates a snippet whose languages are detected, the Dockerfile's
+	// as one which isn't offered on the create form
+	csrfToken := login("alice@example.com")
+	form := url.Values{}
+	form.Add("title", "Hello service")
+	for _, f := range [][3]string{
+		{"Dockerfile", "FROM golang:1.16\n", ""},
+		{"main.go", "package main\n", ""},
+	} {
+		form.Add("filename", f[0])
+		form.Add("content", f[1])
+		form.Add("language", f[2])
+	}
+	form.Add("tags", "go, docker")
+	form.Add("expires", "1d")
+	form.Add("visibility", "unlisted")
+	form.Add("csrf_token", csrfToken)
+	if code, _, _ := ts.postForm(t, "/snippet/create", form); code != http.StatusSeeOther {
+		t.Fatalf("create: want %d; got %d", http.StatusSeeOther, code)
+	}
+
+	// and exports her snippets
+	code, _, archive := ts.get(t, "/user/export")
+	if code != http.StatusOK {
+		t.Fatalf("export: want %d; got %d", http.StatusOK, code)
+	}
+	ts.postForm(t, "/user/logout", url.Values{"csrf_token": {csrfToken}})
+
+	// and Bob imports them as his own
+	csrfToken = login("bob@example.com")
+	form = url.Values{"csrf_token": {csrfToken}}
+	code, headers, _ := ts.postFile(t, "/user/import", form, "archive", "snippets.zip", archive)
+	if code != http.StatusSeeOther || headers.Get("Location") != "/user/profile" {
+		t.Fatalf("import: want a redirect to /user/profile; got %d to %q", code, headers.Get("Location"))
+	}
+	snippets, err := app.snippets.ByUser(ctx, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snippets) != 2 {
+		t.Fatalf("want 2 imported snippets; got %d", len(snippets))
+	}
+	byTitle := map[string]*models.Snippet{}
+	for _, s := range snippets {
+		s, err := app.snippets.Get(ctx, s.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		byTitle[s.Title] = s
+	}
+	hello := byTitle["Hello service"]
+	if hello == nil || hello.Visibility != "unlisted" || len(hello.Files) != 2 || hello.Files[0].Language != "docker" || hello.Files[1].Language != "go" || !reflect.DeepEqual(hello.Tags, []string{"docker", "go"}) {
+		t.Errorf("want the service snippet recreated; got %+v", hello)
+	}
+	if secret := byTitle["Secret"]; secret == nil || secret.Visibility != "private" || secret.Protected || !secret.Expires.IsZero() {
+		t.Errorf("want the protected snippet recreated as private; got %+v", secret)
+	}
+
+	// An archive with an invalid entry imports nothing
+	r, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := map[string]string{}
+	for _, zf := range r.File {
+		rc, err := zf.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := ioutil.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries[zf.Name] = string(b)
+	}
+	entries[manifestName] = strings.Replace(entries[manifestName], `"title": "Secret"`, `"title": ""`, 1)
+	code, _, body := ts.postFile(t, "/user/import", form, "archive", "snippets.zip", makeZip(t, entries))
+	if code != http.StatusOK || !bytes.Contains(body, []byte("Some of the snippets can&#39;t be imported")) || !bytes.Contains(body, []byte("This field cannot be blank")) {
+		t.Errorf("invalid import: want the entry errors shown; got %d", code)
+	}
+	if snippets, err := app.snippets.ByUser(ctx, 2); err != nil || len(snippets) != 2 {
+		t.Errorf("invalid import: want nothing imported; got %d snippets", len(snippets))
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"yudhiesh/snippetbox/pkg/forms"
+	"yudhiesh/snippetbox/pkg/models"
+)
+
+// The longest the description of a collection may be, in characters
+const maxCollectionDescriptionLength = 1000
+
+// Checks the fields of the form used to create and edit collections, adding
+// any problem to the form's errors. Collections are either public or private.
+func validateCollectionForm(form *forms.Form) {
+	form.Required("title", "visibility")
+	form.MaxLength("title", 100)
+	form.MaxLength("description", maxCollectionDescriptionLength)
+	form.PermittedValues("visibility", models.VisibilityPublic, models.VisibilityPrivate)
+}
+
+// Fetches the collection named by the :id URL parameter and checks that the
+// current user may view it, sending the error response and returning false
+// if not. Private collections are only found by their owner.
+func (app *Application) collectionFromURL(w http.ResponseWriter, r *http.Request) (*models.Collection, bool) {
+	id, err := strconv.Atoi(r.URL.Query().Get(":id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return nil, false
+	}
+	c, err := app.collections.Get(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return nil, false
+	}
+	userID := app.session.GetInt(r, "authenticatedUserID")
+	if c.Visibility == models.VisibilityPrivate && c.UserID != userID {
+		app.notFound(w)
+		return nil, false
+	}
+	// The snippets in a public collection are still only listed for those
+	// who may view them
+	visible := []*models.Snippet{}
+	for _, s := range c.Snippets {
+		if s.VisibleTo(userID) {
+			visible = append(visible, s)
+		}
+	}
+	c.Snippets = visible
+	return c, true
+}
+
+// Fetches a collection of the current user by its ID, sending the error
+// response and returning false if there is no such collection or the user
+// doesn't own it. Collections of other users are reported as not found, so
+// that the response doesn't give away which private collections exist.
+func (app *Application) ownedCollection(w http.ResponseWriter, r *http.Request, id int) (*models.Collection, bool) {
+	c, err := app.collections.Get(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return nil, false
+	}
+	if c.UserID != app.session.GetInt(r, "authenticatedUserID") {
+		app.notFound(w)
+		return nil, false
+	}
+	return c, true
+}
+
+// Returns the collections of the current user which the snippet is in and
+// those which it could be added to, or nothing for anonymous visitors
+func (app *Application) collectionsOf(r *http.Request, s *models.Snippet) (in, notIn []*models.Collection, err error) {
+	userID := app.session.GetInt(r, "authenticatedUserID")
+	if userID == 0 {
+		return nil, nil, nil
+	}
+	all, err := app.collections.ByUser(r.Context(), userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	in, err = app.collections.Containing(r.Context(), userID, s.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	holds := map[int]bool{}
+	for _, c := range in {
+		holds[c.ID] = true
+	}
+	for _, c := range all {
+		if !holds[c.ID] {
+			notIn = append(notIn, c)
+		}
+	}
+	return in, notIn, nil
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/alecthomas/chroma"
+	"github.com/alecthomas/chroma/lexers"
+
+	"yudhiesh/snippetbox/pkg/forms"
+	"yudhiesh/snippetbox/pkg/models"
+)
+
+// The longest a comment may be, in characters
+const maxCommentLength = 2000
+
+// A reference to a line in the fragment of a link, such as L10 for the tenth
+// line of the first file or F2L10 for that of the second file
+var lineRefRX = regexp.MustCompile(`^(?:F(\d+))?L(\d+)$`)
+
+// Parses a range of lines written as in the fragment of a link to them, such
+// as L10, L10-L20 or F2L3-F2L5, and returns the position of the file and the
+// first and last lines. Both ends have to be in the same file.
+func parseLineRange(value string) (file, start, end int, ok bool) {
+	parts := strings.SplitN(strings.TrimPrefix(value, "#"), "-", 2)
+	file, start, ok = parseLineRef(parts[0])
+	if !ok {
+		return 0, 0, 0, false
+	}
+	end = start
+	if len(parts) == 2 {
+		var endFile int
+		endFile, end, ok = parseLineRef(parts[1])
+		if !ok || endFile != file {
+			return 0, 0, 0, false
+		}
+	}
+	if end < start {
+		start, end = end, start
+	}
+	return file, start, end, true
+}
+
+// Parses a single line reference, returning the position of the file and the
+// line number
+func parseLineRef(ref string) (file, line int, ok bool) {
+	m := lineRefRX.FindStringSubmatch(ref)
+	if m == nil {
+		return 0, 0, false
+	}
+	if m[1] != "" {
+		n, err := strconv.Atoi(m[1])
+		if err != nil || n < 1 {
+			return 0, 0, false
+		}
+		file = n - 1
+	}
+	line, err := strconv.Atoi(m[2])
+	if err != nil || line < 1 {
+		return 0, 0, false
+	}
+	return file, line, true
+}
+
+// Returns the range of lines a comment is anchored to as the fragment of a
+// link to them, such as L10-L20, or the empty string if it isn't anchored
+func lineRange(c *models.Comment) string {
+	if !c.Anchored() {
+		return ""
+	}
+	prefix := linePrefix(c.File)
+	if c.StartLine == c.EndLine {
+		return fmt.Sprintf("%s%d", prefix, c.StartLine)
+	}
+	return fmt.Sprintf("%s%d-%s%d", prefix, c.StartLine, prefix, c.EndLine)
+}
+
+// Returns the number of lines of a file as they are numbered when it is
+// shown. A final newline doesn't start another line.
+func lineCount(content string) int {
+	return strings.Count(strings.TrimSuffix(content, "\n"), "\n") + 1
+}
+
+// Returns the comment entered on the comment form of a snippet. The optional
+// "lines" field anchors the comment to a range of lines, given as in a link to
+// them, which have to be in the snippet. Any problem is added to the form's
+// errors.
+func commentFromForm(form *forms.Form, s *models.Snippet) *models.Comment {
+	form.Required("body")
+	form.MaxLength("body", maxCommentLength)
+
+	c := &models.Comment{SnippetID: s.ID, Body: form.Get("body")}
+	lines := strings.TrimSpace(form.Get("lines"))
+	if lines == "" {
+		return c
+	}
+	file, start, end, ok := parseLineRange(lines)
+	switch {
+	case !ok:
+		form.Errors.Add("lines", "This field is invalid")
+	case file >= len(s.Files):
+		form.Errors.Add("lines", fmt.Sprintf("This snippet only has %d files", len(s.Files)))
+	case end > lineCount(s.Files[file].Content):
+		form.Errors.Add("lines", fmt.Sprintf("This file only has %d lines", lineCount(s.Files[file].Content)))
+	default:
+		c.File, c.StartLine, c.EndLine = file, start, end
+	}
+	return c
+}
+
+// A run of consecutive highlighted lines of a file, followed by the comments
+// anchored to ranges which end on its last line
+type codeSegment struct {
+	Code     template.HTML
+	Comments []*models.Comment
+}
+
+// Highlights a file of a snippet like highlight, but splits it after every
+// line which ends the range of one of the comments anchored to the file, so
+// that those comments can be shown right below the lines they are about.
+// Ranges which end past the last line, because the file has been edited since,
+// are shown after it. Files without anchored comments are a single segment.
+func annotate(content, language string, file int, comments []*models.Comment) []codeSegment {
+	prefix := linePrefix(file)
+	anchored := []*models.Comment{}
+	byEnd := map[int][]*models.Comment{}
+	for _, c := range comments {
+		if c.Anchored() && c.File == file {
+			anchored = append(anchored, c)
+			byEnd[c.EndLine] = append(byEnd[c.EndLine], c)
+		}
+	}
+	// Should the file fail to split, it is shown whole with all of its
+	// comments after it
+	whole := func() []codeSegment {
+		return []codeSegment{{Code: highlight(content, language, prefix), Comments: anchored}}
+	}
+	if len(anchored) == 0 {
+		return whole()
+	}
+
+	l := lexer(language)
+	if l == nil {
+		l = lexers.Fallback
+	}
+	iterator, err := chroma.Coalesce(l).Tokenise(nil, content)
+	if err != nil {
+		return whole()
+	}
+	lines := chroma.SplitTokensIntoLines(iterator.Tokens())
+	if len(lines) == 0 {
+		return whole()
+	}
+
+	segments := []codeSegment{}
+	start := 0
+	for i := range lines {
+		n := i + 1
+		if _, ok := byEnd[n]; !ok && n != len(lines) {
+			continue
+		}
+		tokens := []chroma.Token{}
+		for _, line := range lines[start:n] {
+			tokens = append(tokens, line...)
+		}
+		var b strings.Builder
+		err := highlightFormatter(prefix, start+1).Format(&b, highlightStyle, chroma.Literator(tokens...))
+		if err != nil {
+			return whole()
+		}
+		segments = append(segments, codeSegment{Code: template.HTML(b.String()), Comments: byEnd[n]})
+		start = n
+	}
+	last := &segments[len(segments)-1]
+	for _, c := range anchored {
+		if c.EndLine > len(lines) {
+			last.Comments = append(last.Comments, c)
+		}
+	}
+	return segments
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"yudhiesh/snippetbox/pkg/models"
+)
+
+func TestParseLineRange(t *testing.T) {
+	tests := []struct {
+		name      string
+		value     string
+		wantFile  int
+		wantStart int
+		wantEnd   int
+		wantOK    bool
+	}{
+		{"Line", "L10", 0, 10, 10, true},
+		{"Range", "L10-L20", 0, 10, 20, true},
+		{"Fragment", "#L10-L20", 0, 10, 20, true},
+		{"Reversed", "L20-L10", 0, 10, 20, true},
+		{"Second file", "F2L3-F2L5", 1, 3, 5, true},
+		{"First file", "F1L3", 0, 3, 3, true},
+		{"Different files", "F2L3-F3L5", 0, 0, 0, false},
+		{"File and no file", "F2L3-L5", 0, 0, 0, false},
+		{"Line zero", "L0", 0, 0, 0, false},
+		{"File zero", "F0L1", 0, 0, 0, false},
+		{"Too large", "L99999999999999999999", 0, 0, 0, false},
+		{"Words", "line 10", 0, 0, 0, false},
+		{"Open range", "L10-", 0, 0, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, start, end, ok := parseLineRange(tt.value)
+			if ok != tt.wantOK || file != tt.wantFile || start != tt.wantStart || end != tt.wantEnd {
+				t.Errorf("want %d, %d, %d, %t; got %d, %d, %d, %t", tt.wantFile, tt.wantStart, tt.wantEnd, tt.wantOK, file, start, end, ok)
+			}
+		})
+	}
+}
+
+func TestLineRange(t *testing.T) {
+	tests := []struct {
+		name    string
+		comment *models.Comment
+		want    string
+	}{
+		{"Not anchored", &models.Comment{}, ""},
+		{"Line", &models.Comment{StartLine: 3, EndLine: 3}, "L3"},
+		{"Range", &models.Comment{StartLine: 3, EndLine: 5}, "L3-L5"},
+		{"Second file", &models.Comment{File: 1, StartLine: 3, EndLine: 5}, "F2L3-F2L5"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := lineRange(tt.comment); got != tt.want {
+				t.Errorf("want %q; got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestAnnotate(t *testing.T) {
+	content := "package main\n\nfunc main() {\n}\n"
+	first := &models.Comment{ID: 1, StartLine: 1, EndLine: 1}
+	range2 := &models.Comment{ID: 2, StartLine: 2, EndLine: 3}
+	range3 := &models.Comment{ID: 3, StartLine: 3, EndLine: 3}
+	past := &models.Comment{ID: 4, StartLine: 9, EndLine: 10}
+	other := &models.Comment{ID: 5, File: 1, StartLine: 1, EndLine: 1}
+	thread := &models.Comment{ID: 6}
+
+	tests := []struct {
+		name         string
+		comments     []*models.Comment
+		wantLines    [][]int
+		wantComments [][]int
+	}{
+		{"No comments", nil, [][]int{{1, 2, 3, 4}}, [][]int{nil}},
+		{"Not anchored here", []*models.Comment{other, thread}, [][]int{{1, 2, 3, 4}}, [][]int{nil}},
+		{"Split", []*models.Comment{first, range2, range3}, [][]int{{1}, {2, 3}, {4}}, [][]int{{1}, {2, 3}, nil}},
+		{"Last line", []*models.Comment{{ID: 7, StartLine: 4, EndLine: 4}}, [][]int{{1, 2, 3, 4}}, [][]int{{7}}},
+		{"Past the end", []*models.Comment{past, first}, [][]int{{1}, {2, 3, 4}}, [][]int{{1}, {4}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			segments := annotate(content, "go", 0, tt.comments)
+			if len(segments) != len(tt.wantLines) {
+				t.Fatalf("want %d segments; got %d", len(tt.wantLines), len(segments))
+			}
+			for i, segment := range segments {
+				var lines []int
+				for n := 1; n <= 4; n++ {
+					if strings.Contains(string(segment.Code), fmt.Sprintf(`id="L%d"`, n)) {
+						lines = append(lines, n)
+					}
+				}
+				if !reflect.DeepEqual(lines, tt.wantLines[i]) {
+					t.Errorf("segment %d: want lines %v; got %v", i, tt.wantLines[i], lines)
+				}
+				var ids []int
+				for _, c := range segment.Comments {
+					ids = append(ids, c.ID)
+				}
+				if !reflect.DeepEqual(ids, tt.wantComments[i]) {
+					t.Errorf("segment %d: want comments %v; got %v", i, tt.wantComments[i], ids)
+				}
+			}
+		})
+	}
+}
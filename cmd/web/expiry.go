@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"yudhiesh/snippetbox/pkg/forms"
+)
+
+// An expiry offered when a snippet is created or renewed. Snippets which
+// never expire have no duration.
+type expiryChoice struct {
+	Value    string
+	Label    string
+	Duration time.Duration
+}
+
+// Every expiry choice, shortest first
+var expiryChoices = []expiryChoice{
+	{"10m", "Ten Minutes", 10 * time.Minute},
+	{"1h", "One Hour", time.Hour},
+	{"1d", "One Day", 24 * time.Hour},
+	{"7d", "One Week", 7 * 24 * time.Hour},
+	{"30d", "One Month", 30 * 24 * time.Hour},
+	{"365d", "One Year", 365 * 24 * time.Hour},
+	{"never", "Never", 0},
+}
+
+// The "expires" value of a custom expiry, whose time is given in the
+// "expires_at" field in the format of a datetime-local input. The browser's
+// offset from UTC at that time is sent in the "expires_offset" field, in the
+// minutes of JavaScript's getTimezoneOffset, and the time is read as UTC
+// without it.
+const (
+	customExpiry       = "custom"
+	customExpiryFormat = "2006-01-02T15:04"
+	// The furthest any time zone is from UTC, in minutes
+	maxExpiryOffset = 14 * 60
+)
+
+// Returns the expiry choices within the maximum expiry. Snippets can only be
+// kept forever when there is no maximum.
+func (app *Application) expiryChoices() []expiryChoice {
+	choices := []expiryChoice{}
+	for _, c := range expiryChoices {
+		if app.maxExpiry == 0 || (c.Duration != 0 && c.Duration <= app.maxExpiry) {
+			choices = append(choices, c)
+		}
+	}
+	return choices
+}
+
+// Returns the value of the longest expiry choice which still expires, which
+// the create form starts with
+func (app *Application) defaultExpiry() string {
+	value := customExpiry
+	for _, c := range app.expiryChoices() {
+		if c.Duration != 0 {
+			value = c.Value
+		}
+	}
+	return value
+}
+
+// Returns the expiry time of a snippet created now with the default expiry,
+// for snippets which aren't created through the create form
+func (app *Application) defaultExpiryTime(now time.Time) time.Time {
+	now = now.UTC().Truncate(time.Second)
+	expires := now.Add(app.maxExpiry)
+	for _, c := range app.expiryChoices() {
+		if c.Duration != 0 {
+			expires = now.Add(c.Duration)
+		}
+	}
+	return expires
+}
+
+// Returns the expiry time picked in the "expires" field of the form, counting
+// from now, or the zero time if the snippet never expires. A custom time is
+// read from the "expires_at" field in the browser's time zone and must be in
+// the future. Any problem is added to the form's errors.
+func (app *Application) expiryFromForm(form *forms.Form, now time.Time) time.Time {
+	now = now.UTC().Truncate(time.Second)
+	value := form.Get("expires")
+	if value == "" {
+		return time.Time{}
+	}
+
+	if value == customExpiry {
+		if form.Get("expires_at") == "" {
+			form.Errors.Add("expires_at", "This field cannot be blank")
+			return time.Time{}
+		}
+		zone := time.UTC
+		if v := form.Get("expires_offset"); v != "" {
+			offset, err := strconv.Atoi(v)
+			if err != nil || offset < -maxExpiryOffset || offset > maxExpiryOffset {
+				form.Errors.Add("expires_at", "This field is invalid")
+				return time.Time{}
+			}
+			// getTimezoneOffset is the minutes to add to the local time to get
+			// UTC, so zones east of UTC have negative offsets
+			zone = time.FixedZone("", -offset*60)
+		}
+		t, err := time.ParseInLocation(customExpiryFormat, form.Get("expires_at"), zone)
+		if err != nil {
+			form.Errors.Add("expires_at", "This field is invalid")
+			return time.Time{}
+		}
+		t = t.UTC()
+		switch {
+		case !t.After(now):
+			form.Errors.Add("expires_at", "This time has already passed")
+		case app.maxExpiry != 0 && t.After(now.Add(app.maxExpiry)):
+			form.Errors.Add("expires_at", fmt.Sprintf("This time is too far away (latest is %s UTC)", humanDate(now.Add(app.maxExpiry))))
+		}
+		return t
+	}
+
+	for _, c := range app.expiryChoices() {
+		if c.Value != value {
+			continue
+		}
+		if c.Duration == 0 {
+			return time.Time{}
+		}
+		return now.Add(c.Duration)
+	}
+	form.Errors.Add("expires", "This field is invalid")
+	return time.Time{}
+}
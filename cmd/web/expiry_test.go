@@ -0,0 +1,125 @@
+package main
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"yudhiesh/snippetbox/pkg/forms"
+)
+
+func TestExpiryChoices(t *testing.T) {
+	tests := []struct {
+		name        string
+		maxExpiry   time.Duration
+		want        []string
+		wantDefault string
+	}{
+		{"No maximum", 0, []string{"10m", "1h", "1d", "7d", "30d", "365d", "never"}, "365d"},
+		{"One week", 7 * 24 * time.Hour, []string{"10m", "1h", "1d", "7d"}, "7d"},
+		{"Two hours", 2 * time.Hour, []string{"10m", "1h"}, "1h"},
+		{"One minute", time.Minute, []string{}, "custom"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := &Application{maxExpiry: tt.maxExpiry}
+			got := []string{}
+			for _, c := range app.expiryChoices() {
+				got = append(got, c.Value)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("want %v; got %v", tt.want, got)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("want %v; got %v", tt.want, got)
+				}
+			}
+			if d := app.defaultExpiry(); d != tt.wantDefault {
+				t.Errorf("want default %q; got %q", tt.wantDefault, d)
+			}
+		})
+	}
+}
+
+func TestExpiryFromForm(t *testing.T) {
+	now := time.Date(2021, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		maxExpiry time.Duration
+		expires   string
+		expiresAt string
+		want      time.Time
+		wantError string
+	}{
+		{"Choice", 0, "1h", "", now.Add(time.Hour), ""},
+		{"Never", 0, "never", "", time.Time{}, ""},
+		{"Custom", 0, "custom", "2021-03-04T05:06", time.Date(2021, 3, 4, 5, 6, 0, 0, time.UTC), ""},
+		{"Unknown choice", 0, "2", "", time.Time{}, "expires"},
+		{"Choice beyond the maximum", time.Hour, "1d", "", time.Time{}, "expires"},
+		{"Never with a maximum", time.Hour, "never", "", time.Time{}, "expires"},
+		{"Empty custom time", 0, "custom", "", time.Time{}, "expires_at"},
+		{"Invalid custom time", 0, "custom", "tomorrow", time.Time{}, "expires_at"},
+		{"Past custom time", 0, "custom", "2021-01-01T10:00", time.Date(2021, 1, 1, 10, 0, 0, 0, time.UTC), "expires_at"},
+		{"Custom time beyond the maximum", time.Hour, "custom", "2021-01-01T11:01", time.Date(2021, 1, 1, 11, 1, 0, 0, time.UTC), "expires_at"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := &Application{maxExpiry: tt.maxExpiry}
+			form := forms.New(url.Values{})
+			form.Set("expires", tt.expires)
+			form.Set("expires_at", tt.expiresAt)
+
+			got := app.expiryFromForm(form, now)
+			if !got.Equal(tt.want) {
+				t.Errorf("want %v; got %v", tt.want, got)
+			}
+			if tt.wantError == "" && !form.Valid() {
+				t.Errorf("want no errors; got %v", form.Errors)
+			}
+			if tt.wantError != "" && form.Errors.Get(tt.wantError) == "" {
+				t.Errorf("want an error for %s; got %v", tt.wantError, form.Errors)
+			}
+		})
+	}
+}
+
+func TestExpiryFromFormOffset(t *testing.T) {
+	now := time.Date(2021, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name          string
+		expiresAt     string
+		expiresOffset string
+		want          time.Time
+		wantError     bool
+	}{
+		{"No offset", "2021-03-04T05:06", "", time.Date(2021, 3, 4, 5, 6, 0, 0, time.UTC), false},
+		{"East of UTC", "2021-03-04T05:06", "-480", time.Date(2021, 3, 3, 21, 6, 0, 0, time.UTC), false},
+		{"West of UTC", "2021-03-04T05:06", "300", time.Date(2021, 3, 4, 10, 6, 0, 0, time.UTC), false},
+		{"Passed in UTC", "2021-01-01T11:00", "-120", time.Date(2021, 1, 1, 9, 0, 0, 0, time.UTC), true},
+		{"Invalid offset", "2021-03-04T05:06", "UTC+8", time.Time{}, true},
+		{"Offset too far", "2021-03-04T05:06", "900", time.Time{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := &Application{}
+			form := forms.New(url.Values{})
+			form.Set("expires", customExpiry)
+			form.Set("expires_at", tt.expiresAt)
+			form.Set("expires_offset", tt.expiresOffset)
+
+			got := app.expiryFromForm(form, now)
+			if !got.Equal(tt.want) {
+				t.Errorf("want %v; got %v", tt.want, got)
+			}
+			if hasError := form.Errors.Get("expires_at") != ""; hasError != tt.wantError {
+				t.Errorf("want error %v; got %v", tt.wantError, form.Errors)
+			}
+		})
+	}
+}
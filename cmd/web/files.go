@@ -0,0 +1,147 @@
+package main
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"yudhiesh/snippetbox/pkg/forms"
+	"yudhiesh/snippetbox/pkg/models"
+)
+
+// The most files a snippet may hold
+const maxFiles = 10
+
+// File names may contain letters, digits, spaces and the characters '.', '-'
+// and '_', which keeps them safe to use in URLs and zip archives
+var fileNameRX = regexp.MustCompile(`^[A-Za-z0-9._ -]+$`)
+
+// Returns the key of the form errors of the file at index i
+func fileErrorKey(i int) string {
+	return fmt.Sprintf("files.%d", i)
+}
+
+// Returns the files entered on the create form, in the order they were
+// entered. Each file repeats the "filename", "language" and "content" fields,
+// of which only the content has to be given. Problems with a file are added to
+// the form's errors under fileErrorKey, and problems with the files as a whole
+// under "files".
+func filesFromForm(form *forms.Form) []*models.File {
+	contents := form.Values["content"]
+	if len(contents) == 0 {
+		form.Errors.Add("files", "A snippet needs at least one file")
+		return []*models.File{{}}
+	}
+	if len(contents) > maxFiles {
+		form.Errors.Add("files", fmt.Sprintf("Too many files (maximum is %d)", maxFiles))
+	}
+
+	files := make([]*models.File, len(contents))
+	names := map[string]bool{}
+	for i, content := range contents {
+		f := &models.File{
+			Name:     strings.TrimSpace(valueAt(form.Values["filename"], i)),
+			Language: valueAt(form.Values["language"], i),
+			Content:  content,
+		}
+		files[i] = f
+
+		key := fileErrorKey(i)
+		if strings.TrimSpace(f.Content) == "" {
+			form.Errors.Add(key, "This field cannot be blank")
+		}
+		if f.Language != "" && !permitted(f.Language, languageValues()) {
+			form.Errors.Add(key, "This field is invalid")
+		}
+		if f.Name == "" {
+			continue
+		}
+		// MySQL compares names without regard to case, so they have to be
+		// unique whatever their case
+		lower := strings.ToLower(f.Name)
+		switch {
+		case utf8.RuneCountInString(f.Name) > 100:
+			form.Errors.Add(key, "This name is too long (maximum is 100 characters)")
+		case !fileNameRX.MatchString(f.Name) || f.Name == "." || f.Name == "..":
+			form.Errors.Add(key, "This name contains invalid characters")
+		case names[lower]:
+			form.Errors.Add(key, "This name is used by another file")
+		}
+		names[lower] = true
+	}
+	return files
+}
+
+// Returns the ith value of a repeated form field, or the empty string if the
+// field was given fewer times
+func valueAt(values []string, i int) string {
+	if i < len(values) {
+		return values[i]
+	}
+	return ""
+}
+
+// Reports whether the value is one of the options
+func permitted(value string, options []string) bool {
+	for _, o := range options {
+		if value == o {
+			return true
+		}
+	}
+	return false
+}
+
+// Detects the language of the files which weren't given one and names the
+// files which weren't given a name after their position and language, such
+// as file2.go
+func completeFiles(files []*models.File) {
+	names := map[string]bool{}
+	for _, f := range files {
+		names[strings.ToLower(f.Name)] = true
+	}
+	for i, f := range files {
+		if f.Language == "" {
+			f.Language = detectLanguage(f.Name, f.Content)
+		}
+		if f.Name != "" {
+			continue
+		}
+		for n := i + 1; f.Name == "" || names[strings.ToLower(f.Name)]; n++ {
+			f.Name = fmt.Sprintf("file%d%s", n, fileExtension(f.Language))
+		}
+		names[strings.ToLower(f.Name)] = true
+	}
+}
+
+// Returns the file of the snippet with the given name, or nil if it has none
+func fileNamed(s *models.Snippet, name string) *models.File {
+	for _, f := range s.Files {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// Writes the files of a snippet to w as a zip archive, each file stamped with
+// the time the snippet was created
+func writeZip(w io.Writer, s *models.Snippet) error {
+	zw := zip.NewWriter(w)
+	for _, f := range s.Files {
+		fw, err := zw.CreateHeader(&zip.FileHeader{
+			Name:     f.Name,
+			Method:   zip.Deflate,
+			Modified: s.Created.UTC(),
+		})
+		if err != nil {
+			return err
+		}
+		if _, err = io.WriteString(fw, f.Content); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/ioutil"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"yudhiesh/snippetbox/pkg/forms"
+	"yudhiesh/snippetbox/pkg/models"
+)
+
+func TestFilesFromForm(t *testing.T) {
+	tests := []struct {
+		name       string
+		filenames  []string
+		contents   []string
+		languages  []string
+		wantFiles  int
+		wantErrors map[string]string
+	}{
+		{"Single file", nil, []string{"An old silent pond"}, nil, 1, nil},
+		{"Several files", []string{"Dockerfile", "main.go"}, []string{"FROM golang", "package main"}, []string{"", "go"}, 2, nil},
+		{"No files", nil, nil, nil, 1, map[string]string{"files": "A snippet needs at least one file"}},
+		{"Too many files", nil, strings.Split(strings.Repeat("pond ", 11), " ")[:11], nil, 11, map[string]string{"files": "Too many files (maximum is 10)"}},
+		{"Blank content", []string{"a.txt", "b.txt"}, []string{"An old silent pond", " "}, nil, 2, map[string]string{"files.1": "This field cannot be blank"}},
+		{"Invalid language", nil, []string{"An old silent pond"}, []string{"cobol-ish"}, 1, map[string]string{"files.0": "This field is invalid"}},
+		{"Invalid name", []string{"../etc/passwd"}, []string{"An old silent pond"}, nil, 1, map[string]string{"files.0": "This name contains invalid characters"}},
+		{"Dot name", []string{".."}, []string{"An old silent pond"}, nil, 1, map[string]string{"files.0": "This name contains invalid characters"}},
+		{"Long name", []string{strings.Repeat("a", 101)}, []string{"An old silent pond"}, nil, 1, map[string]string{"files.0": "This name is too long (maximum is 100 characters)"}},
+		{"Duplicate name", []string{"pond.txt", "Pond.txt"}, []string{"An old", "silent pond"}, nil, 2, map[string]string{"files.1": "This name is used by another file"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := forms.New(url.Values{"filename": tt.filenames, "content": tt.contents, "language": tt.languages})
+			files := filesFromForm(form)
+			if len(files) != tt.wantFiles {
+				t.Errorf("want %d files; got %d", tt.wantFiles, len(files))
+			}
+			if len(form.Errors) != len(tt.wantErrors) {
+				t.Errorf("want errors %v; got %v", tt.wantErrors, form.Errors)
+			}
+			for key, want := range tt.wantErrors {
+				if got := form.Errors.Get(key); got != want {
+					t.Errorf("want %q for %s; got %q", want, key, got)
+				}
+			}
+		})
+	}
+}
+
+func TestCompleteFiles(t *testing.T) {
+	files := []*models.File{
+		{Content: "package main"},
+		{Name: "file3.go", Language: "go", Content: "package main"},
+		{Language: "go", Content: "package main"},
+		{Name: "Dockerfile", Content: "FROM golang"},
+	}
+	completeFiles(files)
+
+	want := []*models.File{
+		{Name: "file1.go", Language: "go", Content: "package main"},
+		{Name: "file3.go", Language: "go", Content: "package main"},
+		{Name: "file4.go", Language: "go", Content: "package main"},
+		{Name: "Dockerfile", Language: "docker", Content: "FROM golang"},
+	}
+	if !reflect.DeepEqual(files, want) {
+		for i := range files {
+			t.Errorf("want %v; got %v", want[i], files[i])
+		}
+	}
+}
+
+func TestWriteZip(t *testing.T) {
+	s := &models.Snippet{
+		Created: time.Date(2021, 3, 14, 15, 9, 26, 0, time.UTC),
+		Files: []*models.File{
+			{Name: "Dockerfile", Content: "FROM golang"},
+			{Name: "main.go", Content: "package main"},
+		},
+	}
+	var b bytes.Buffer
+	if err := writeZip(&b, s); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := zip.NewReader(bytes.NewReader(b.Bytes()), int64(b.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.File) != len(s.Files) {
+		t.Fatalf("want %d files; got %d", len(s.Files), len(r.File))
+	}
+	for i, zf := range r.File {
+		if zf.Name != s.Files[i].Name || !zf.Modified.Equal(s.Created) {
+			t.Errorf("want %s modified at %v; got %s modified at %v", s.Files[i].Name, s.Created, zf.Name, zf.Modified)
+		}
+		rc, err := zf.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := ioutil.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(content) != s.Files[i].Content {
+			t.Errorf("want %q; got %q", s.Files[i].Content, content)
+		}
+	}
+}
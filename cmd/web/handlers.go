@@ -3,8 +3,12 @@ package main
 import (
 	"errors"
 	"fmt"
+	"mime"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
+	"time"
 
 	"yudhiesh/snippetbox/pkg/forms"
 	"yudhiesh/snippetbox/pkg/models"
@@ -12,25 +16,472 @@ import (
 
 // recoverPanic <-> logRequest <-> secureHeaders <-> servemux <-> application handler
 
+// The largest page size a client may ask for with the limit parameter
+const maxPageSize = 100
+
 func (app *Application) home(w http.ResponseWriter, r *http.Request) {
-	s, err := app.snippets.Latest()
+	q := r.URL.Query()
+
+	// Clients can ask for a different page size, within reason
+	limit := app.pageSize
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxPageSize {
+			app.clientError(w, http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	// Only one of the before and after cursors can be used at a time
+	var before, after *models.Cursor
+	var err error
+	switch {
+	case q.Get("before") != "" && q.Get("after") != "":
+		app.clientError(w, http.StatusBadRequest)
+		return
+	case q.Get("before") != "":
+		before, err = models.ParseCursor(q.Get("before"))
+	case q.Get("after") != "":
+		after, err = models.ParseCursor(q.Get("after"))
+	}
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	// Fetch one snippet more than is shown to find out if there is another
+	// page in the direction we are paging
+	s, err := app.snippets.Latest(r.Context(), before, after, limit+1)
 	if err != nil {
 		app.serverError(w, err)
 		return
 	}
 
-	app.render(w, r, "home.page.tmpl", &templateData{
+	var hasNewer, hasOlder bool
+	if after != nil {
+		hasNewer = len(s) > limit
+		if hasNewer {
+			s = s[1:]
+		}
+		hasOlder = true
+	} else {
+		hasOlder = len(s) > limit
+		if hasOlder {
+			s = s[:limit]
+		}
+		hasNewer = before != nil
+	}
+	if err = app.stars.Count(r.Context(), s...); err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	td := &templateData{Snippets: s}
+	if len(s) > 0 {
+		if hasNewer {
+			td.NewerPage = pageURL("after", models.CursorFor(s[0]), limit, app.pageSize)
+		}
+		if hasOlder {
+			td.OlderPage = pageURL("before", models.CursorFor(s[len(s)-1]), limit, app.pageSize)
+		}
+	}
+	app.render(w, r, "home.page.tmpl", td)
+}
+
+// Builds the link to the page of snippets on the given side of the cursor,
+// keeping a non-default page size
+func pageURL(direction string, c *models.Cursor, limit, defaultLimit int) string {
+	v := url.Values{}
+	v.Set(direction, c.String())
+	if limit != defaultLimit {
+		v.Set("limit", strconv.Itoa(limit))
+	}
+	return "/?" + v.Encode()
+}
+
+// The maximum number of snippets shown for a search or a tag
+const resultLimit = 50
+
+// Searches the titles and content of live snippets
+func (app *Application) searchSnippets(w http.ResponseWriter, r *http.Request) {
+	form := forms.New(r.URL.Query())
+	form.MaxLength("q", 100)
+
+	query := strings.TrimSpace(form.Get("q"))
+	if query == "" || !form.Valid() {
+		app.render(w, r, "search.page.tmpl", &templateData{Form: form})
+		return
+	}
+
+	s, err := app.snippets.Search(r.Context(), query, resultLimit)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	app.render(w, r, "search.page.tmpl", &templateData{
+		Form:     form,
+		Query:    query,
 		Snippets: s,
 	})
 }
 
+// Lists the live snippets with the given tag
+func (app *Application) listTag(w http.ResponseWriter, r *http.Request) {
+	tag := strings.ToLower(r.URL.Query().Get(":name"))
+	if !forms.TagRX.MatchString(tag) {
+		app.notFound(w)
+		return
+	}
+	s, err := app.snippets.ByTag(r.Context(), tag, resultLimit)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	app.render(w, r, "tag.page.tmpl", &templateData{Tag: tag, Snippets: s})
+}
+
 func (app *Application) showSnippet(w http.ResponseWriter, r *http.Request) {
+	s, ok := app.snippetFromURL(w, r)
+	if !ok || !app.unlocked(w, r, s) {
+		return
+	}
+	// Reading a burn-after-reading snippet has to be confirmed with a POST,
+	// so that link previews and crawlers following the link don't burn it
+	if s.BurnAfterReading {
+		app.render(w, r, "burn.page.tmpl", &templateData{Snippet: s})
+		return
+	}
+	app.countView(r, s)
+	app.renderSnippet(w, r, s, forms.New(nil))
+}
+
+// Renders the page of a snippet along with its lineage and comments. The form
+// is the comment form, which holds the errors of a comment that couldn't be
+// added.
+func (app *Application) renderSnippet(w http.ResponseWriter, r *http.Request, s *models.Snippet, form *forms.Form) {
+	parent, forks, err := app.lineage(r, s)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	comments, err := app.comments.BySnippet(r.Context(), s.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	if err = app.stars.Count(r.Context(), s); err != nil {
+		app.serverError(w, err)
+		return
+	}
+	// Only the owner is shown how often the snippet has been read
+	if s.UserID == app.session.GetInt(r, "authenticatedUserID") {
+		if err = app.loadViews(r.Context(), s); err != nil {
+			app.serverError(w, err)
+			return
+		}
+	}
+	var starred bool
+	if userID := app.session.GetInt(r, "authenticatedUserID"); userID != 0 {
+		starred, err = app.stars.IsStarred(r.Context(), s.ID, userID)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+	}
+	collected, collections, err := app.collectionsOf(r, s)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	app.render(w, r, "show.page.tmpl", &templateData{
+		Snippet:     s,
+		Parent:      parent,
+		Forks:       forks,
+		Comments:    comments,
+		Form:        form,
+		Starred:     starred,
+		Collected:   collected,
+		Collections: collections,
+	})
+}
+
+// Returns the snippet a snippet was forked from and its public forks. The
+// parent is only returned if the current user could find it anyway, so that a
+// fork doesn't give away the slug of an unlisted or private snippet, and it is
+// nil when it can no longer be viewed.
+func (app *Application) lineage(r *http.Request, s *models.Snippet) (*models.Snippet, []*models.Snippet, error) {
+	var parent *models.Snippet
+	if s.ForkedFrom != 0 {
+		p, err := app.snippets.Get(r.Context(), s.ForkedFrom)
+		switch {
+		case err == nil:
+			if p.Visibility == models.VisibilityPublic || p.UserID == app.session.GetInt(r, "authenticatedUserID") {
+				parent = p
+			}
+		case errors.Is(err, models.ErrNoRecord), errors.Is(err, models.ErrDeleted),
+			errors.Is(err, models.ErrExpired), errors.Is(err, models.ErrBurned):
+			// The parent is only mentioned by its ID
+		default:
+			return nil, nil, err
+		}
+	}
+	forks, err := app.snippets.Forks(r.Context(), s.ID, resultLimit)
+	if err != nil {
+		return nil, nil, err
+	}
+	return parent, forks, nil
+}
+
+// Copies a snippet into a new one owned by the current user and opens the copy
+// for editing. Protected snippets have to be unlocked first, and
+// burn-after-reading snippets can't be forked as that would keep them.
+func (app *Application) forkSnippet(w http.ResponseWriter, r *http.Request) {
+	s, ok := app.snippetFromURL(w, r)
+	if !ok {
+		return
+	}
+	if s.BurnAfterReading {
+		app.notFound(w)
+		return
+	}
+	if !app.isUnlocked(r, s) {
+		app.clientError(w, http.StatusForbidden)
+		return
+	}
+	userID := app.session.GetInt(r, "authenticatedUserID")
+	slug, err := app.snippets.Fork(r.Context(), s.ID, userID, app.defaultExpiryTime(time.Now()))
+	if err != nil {
+		app.snippetError(w, r, err)
+		return
+	}
+	app.session.Put(r, "flash", "Snippet forked! Make your changes below.")
+	http.Redirect(w, r, "/snippet/"+slug+"/edit", http.StatusSeeOther)
+}
+
+// Stars a snippet for the current user, or removes their star if they had
+// already starred it. Like forks, stars need the snippet to be readable, so
+// burn-after-reading snippets can't be starred.
+func (app *Application) toggleStar(w http.ResponseWriter, r *http.Request) {
+	s, ok := app.snippetFromURL(w, r)
+	if !ok {
+		return
+	}
+	if s.BurnAfterReading {
+		app.notFound(w)
+		return
+	}
+	if !app.isUnlocked(r, s) {
+		app.clientError(w, http.StatusForbidden)
+		return
+	}
+	starred, err := app.stars.Toggle(r.Context(), s.ID, app.session.GetInt(r, "authenticatedUserID"))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	if starred {
+		app.session.Put(r, "flash", "Snippet starred!")
+	} else {
+		app.session.Put(r, "flash", "Star removed.")
+	}
+	http.Redirect(w, r, "/snippet/"+s.Slug, http.StatusSeeOther)
+}
+
+// Lists the snippets the current user has starred, by their current titles
+func (app *Application) starredSnippets(w http.ResponseWriter, r *http.Request) {
+	s, err := app.stars.ByUser(r.Context(), app.session.GetInt(r, "authenticatedUserID"))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	if err = app.stars.Count(r.Context(), s...); err != nil {
+		app.serverError(w, err)
+		return
+	}
+	app.render(w, r, "starred.page.tmpl", &templateData{Snippets: s})
+}
+
+// Lists the collections of the current user along with the form to create
+// another, which makes new collections private unless chosen otherwise
+func (app *Application) userCollections(w http.ResponseWriter, r *http.Request) {
+	form := forms.New(url.Values{})
+	form.Set("visibility", models.VisibilityPrivate)
+	app.renderCollections(w, r, form)
+}
+
+// Renders the collections of the current user. The form is the form to create
+// a collection, which holds the errors of one that couldn't be created.
+func (app *Application) renderCollections(w http.ResponseWriter, r *http.Request, form *forms.Form) {
+	c, err := app.collections.ByUser(r.Context(), app.session.GetInt(r, "authenticatedUserID"))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	app.render(w, r, "collections.page.tmpl", &templateData{Collections: c, Form: form})
+}
+
+func (app *Application) createCollection(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	form := forms.New(r.PostForm)
+	validateCollectionForm(form)
+	if !form.Valid() {
+		app.renderCollections(w, r, form)
+		return
+	}
+	userID := app.session.GetInt(r, "authenticatedUserID")
+	id, err := app.collections.Insert(r.Context(), userID, form.Get("title"), form.Get("description"), form.Get("visibility"))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	app.session.Put(r, "flash", "Collection created!")
+	http.Redirect(w, r, fmt.Sprintf("/collection/%d", id), http.StatusSeeOther)
+}
+
+func (app *Application) showCollection(w http.ResponseWriter, r *http.Request) {
+	c, ok := app.collectionFromURL(w, r)
+	if !ok {
+		return
+	}
+	form := forms.New(url.Values{})
+	form.Set("title", c.Title)
+	form.Set("description", c.Description)
+	form.Set("visibility", c.Visibility)
+	app.renderCollection(w, r, c, form)
+}
+
+// Renders the page of a collection. The form is the form its owner edits it
+// with, which holds the errors of changes that couldn't be saved.
+func (app *Application) renderCollection(w http.ResponseWriter, r *http.Request, c *models.Collection, form *forms.Form) {
+	if err := app.stars.Count(r.Context(), c.Snippets...); err != nil {
+		app.serverError(w, err)
+		return
+	}
+	app.render(w, r, "collection.page.tmpl", &templateData{Collection: c, Form: form})
+}
+
+// Changes the title, description and visibility of a collection of the
+// current user
+func (app *Application) editCollection(w http.ResponseWriter, r *http.Request) {
+	c, ok := app.collectionFromURL(w, r)
+	if !ok {
+		return
+	}
+	if c.UserID != app.session.GetInt(r, "authenticatedUserID") {
+		app.clientError(w, http.StatusForbidden)
+		return
+	}
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	form := forms.New(r.PostForm)
+	validateCollectionForm(form)
+	if !form.Valid() {
+		app.renderCollection(w, r, c, form)
+		return
+	}
+	err = app.collections.Update(r.Context(), c.ID, form.Get("title"), form.Get("description"), form.Get("visibility"))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+	app.session.Put(r, "flash", "Collection updated.")
+	http.Redirect(w, r, fmt.Sprintf("/collection/%d", c.ID), http.StatusSeeOther)
+}
+
+// Deletes a collection of the current user, keeping the snippets in it
+func (app *Application) deleteCollection(w http.ResponseWriter, r *http.Request) {
+	c, ok := app.collectionFromURL(w, r)
+	if !ok {
+		return
+	}
+	if c.UserID != app.session.GetInt(r, "authenticatedUserID") {
+		app.clientError(w, http.StatusForbidden)
+		return
+	}
+	err := app.collections.Delete(r.Context(), c.ID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+	app.session.Put(r, "flash", "Collection deleted.")
+	http.Redirect(w, r, "/user/collections", http.StatusSeeOther)
+}
+
+// Adds a snippet to the end of the collection of the current user given in
+// the "collection" field. Like stars, burn-after-reading snippets can't be
+// collected.
+func (app *Application) collectSnippet(w http.ResponseWriter, r *http.Request) {
+	s, ok := app.snippetFromURL(w, r)
+	if !ok {
+		return
+	}
+	if s.BurnAfterReading {
+		app.notFound(w)
+		return
+	}
+	if !app.isUnlocked(r, s) {
+		app.clientError(w, http.StatusForbidden)
+		return
+	}
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	id, err := strconv.Atoi(r.PostForm.Get("collection"))
+	if err != nil || id < 1 {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	c, ok := app.ownedCollection(w, r, id)
+	if !ok {
+		return
+	}
+	err = app.collections.AddSnippet(r.Context(), c.ID, s.ID)
+	switch {
+	case errors.Is(err, models.ErrInCollection):
+		app.session.Put(r, "flash", fmt.Sprintf("This snippet is already in %s.", c.Title))
+	case err != nil:
+		app.serverError(w, err)
+		return
+	default:
+		app.session.Put(r, "flash", fmt.Sprintf("Added to %s!", c.Title))
+	}
+	http.Redirect(w, r, "/snippet/"+s.Slug, http.StatusSeeOther)
+}
+
+// Removes a snippet from a collection of the current user
+func (app *Application) uncollectSnippet(w http.ResponseWriter, r *http.Request) {
+	s, ok := app.snippetFromURL(w, r)
+	if !ok {
+		return
+	}
 	id, err := strconv.Atoi(r.URL.Query().Get(":id"))
 	if err != nil || id < 1 {
 		app.notFound(w)
 		return
 	}
-	s, err := app.snippets.Get(id)
+	c, ok := app.ownedCollection(w, r, id)
+	if !ok {
+		return
+	}
+	err = app.collections.RemoveSnippet(r.Context(), c.ID, s.ID)
 	if err != nil {
 		if errors.Is(err, models.ErrNoRecord) {
 			app.notFound(w)
@@ -39,9 +490,422 @@ func (app *Application) showSnippet(w http.ResponseWriter, r *http.Request) {
 		}
 		return
 	}
-	app.render(w, r, "show.page.tmpl", &templateData{
-		Snippet: s,
+	app.session.Put(r, "flash", fmt.Sprintf("Removed from %s.", c.Title))
+	http.Redirect(w, r, "/snippet/"+s.Slug, http.StatusSeeOther)
+}
+
+// Adds a comment by the current user to a snippet, anchored to the lines given
+// in the "lines" field if there are any. Comments can only be added where the
+// snippet can be read, so burn-after-reading snippets take none.
+func (app *Application) createComment(w http.ResponseWriter, r *http.Request) {
+	s, ok := app.snippetFromURL(w, r)
+	if !ok {
+		return
+	}
+	if s.BurnAfterReading {
+		app.notFound(w)
+		return
+	}
+	if !app.isUnlocked(r, s) {
+		app.clientError(w, http.StatusForbidden)
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	form := forms.New(r.PostForm)
+	c := commentFromForm(form, s)
+	if !form.Valid() {
+		app.renderSnippet(w, r, s, form)
+		return
+	}
+
+	userID := app.session.GetInt(r, "authenticatedUserID")
+	id, err := app.comments.Insert(r.Context(), s.ID, userID, c.Body, c.File, c.StartLine, c.EndLine)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	app.session.Put(r, "flash", "Comment added!")
+	http.Redirect(w, r, fmt.Sprintf("/snippet/%s#comment-%d", s.Slug, id), http.StatusSeeOther)
+}
+
+// Deletes a comment on a snippet owned by the current user
+func (app *Application) deleteComment(w http.ResponseWriter, r *http.Request) {
+	s, ok := app.snippetFromURL(w, r)
+	if !ok {
+		return
+	}
+	// Only the owner of a snippet moderates its comments
+	if s.UserID != app.session.GetInt(r, "authenticatedUserID") {
+		app.clientError(w, http.StatusForbidden)
+		return
+	}
+	id, err := strconv.Atoi(r.URL.Query().Get(":id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return
+	}
+	err = app.comments.Delete(r.Context(), id, s.ID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+	app.session.Put(r, "flash", "Comment deleted.")
+	http.Redirect(w, r, "/snippet/"+s.Slug+"#comments", http.StatusSeeOther)
+}
+
+// Burns a burn-after-reading snippet and shows it for the first and last time
+func (app *Application) burnSnippet(w http.ResponseWriter, r *http.Request) {
+	s, ok := app.snippetFromURL(w, r)
+	if !ok || !app.unlocked(w, r, s) {
+		return
+	}
+	s, err := app.snippets.Burn(r.Context(), s.Slug)
+	if err != nil {
+		app.snippetError(w, r, err)
+		return
+	}
+	// Nothing may keep a copy of the page once the snippet is gone
+	w.Header().Set("Cache-Control", "no-store")
+	app.render(w, r, "show.page.tmpl", &templateData{Snippet: s})
+}
+
+// Returns the snippet in the URL for the endpoints which serve its content
+// without a page around it. Protected snippets have to be unlocked in the
+// browser first. Burn-after-reading snippets can only be read through their
+// confirmation page, so they have no raw content.
+func (app *Application) plainSnippetFromURL(w http.ResponseWriter, r *http.Request) (*models.Snippet, bool) {
+	s, ok := app.snippetFromURL(w, r)
+	if !ok {
+		return nil, false
+	}
+	if s.BurnAfterReading {
+		app.notFound(w)
+		return nil, false
+	}
+	if !app.isUnlocked(r, s) {
+		app.clientError(w, http.StatusForbidden)
+		return nil, false
+	}
+	return s, true
+}
+
+// Writes the content of a snippet's file as plain text. The content is never
+// sniffed as HTML, as it is written by the users.
+func writeContent(w http.ResponseWriter, content string) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Write([]byte(content))
+}
+
+// Serves the content of the first file of a snippet as plain text, for
+// fetching with curl
+func (app *Application) rawSnippet(w http.ResponseWriter, r *http.Request) {
+	s, ok := app.plainSnippetFromURL(w, r)
+	if !ok {
+		return
+	}
+	writeContent(w, s.Content)
+}
+
+// Serves the content of one of the files of a snippet as plain text
+func (app *Application) rawSnippetFile(w http.ResponseWriter, r *http.Request) {
+	s, ok := app.plainSnippetFromURL(w, r)
+	if !ok {
+		return
+	}
+	f := fileNamed(s, r.URL.Query().Get(":file"))
+	if f == nil {
+		app.notFound(w)
+		return
+	}
+	writeContent(w, f.Content)
+}
+
+// Serves a snippet as a download. A snippet with a single file is downloaded
+// as that file, named after the snippet's title and language, and a snippet
+// with several files as a zip archive of them named after its title.
+func (app *Application) downloadSnippet(w http.ResponseWriter, r *http.Request) {
+	s, ok := app.plainSnippetFromURL(w, r)
+	if !ok {
+		return
+	}
+	if len(s.Files) <= 1 {
+		disposition := mime.FormatMediaType("attachment", map[string]string{"filename": downloadName(s)})
+		w.Header().Set("Content-Disposition", disposition)
+		writeContent(w, s.Content)
+		return
+	}
+
+	// The archive is written as it is made, so an error part way through
+	// can only be logged
+	disposition := mime.FormatMediaType("attachment", map[string]string{"filename": titleSlug(s.Title) + ".zip"})
+	w.Header().Set("Content-Disposition", disposition)
+	w.Header().Set("Content-Type", "application/zip")
+	if err := writeZip(w, s); err != nil {
+		app.errorLog.Println(err)
+	}
+}
+
+// The number of wrong passwords a client may try on a snippet within the
+// window before it has to wait
+const (
+	maxUnlockFailures   = 5
+	unlockFailureWindow = 15 * time.Minute
+)
+
+// Checks the password of a protected snippet and remembers in the session
+// that it was unlocked. Wrong passwords are throttled for each snippet and
+// client.
+func (app *Application) unlockSnippet(w http.ResponseWriter, r *http.Request) {
+	s, ok := app.snippetFromURL(w, r)
+	if !ok {
+		return
+	}
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	form := forms.New(r.PostForm)
+	form.Required("password")
+	if !form.Valid() {
+		app.render(w, r, "unlock.page.tmpl", &templateData{Form: form, Snippet: s})
+		return
+	}
+
+	// The attempt is counted as wrong until the password turns out to be
+	// right, so that guesses made at the same time are throttled too
+	key := s.Slug + " " + clientIP(r)
+	if !app.unlockThrottle.Allow(key) {
+		form.Errors.Add("generic", "Too many wrong passwords. Please try again later.")
+		app.renderStatus(w, r, http.StatusTooManyRequests, "unlock.page.tmpl", &templateData{Form: form, Snippet: s})
+		return
+	}
+
+	err = app.snippets.Unlock(r.Context(), s.ID, form.Get("password"))
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidCredentials) {
+			form.Errors.Add("password", "Password is incorrect")
+			app.render(w, r, "unlock.page.tmpl", &templateData{Form: form, Snippet: s})
+		} else {
+			app.unlockThrottle.Release(key)
+			app.serverError(w, err)
+		}
+		return
+	}
+	app.unlockThrottle.Reset(key)
+	app.rememberUnlocked(r, s.ID)
+	http.Redirect(w, r, "/snippet/"+s.Slug, http.StatusSeeOther)
+}
+
+func (app *Application) editSnippetForm(w http.ResponseWriter, r *http.Request) {
+	s, ok := app.snippetFromURL(w, r)
+	if !ok {
+		return
+	}
+	// Only the owner of a snippet is allowed to edit it
+	if s.UserID != app.session.GetInt(r, "authenticatedUserID") {
+		app.clientError(w, http.StatusForbidden)
+		return
+	}
+
+	// Pre-populate the form with the current version of the snippet
+	form := forms.New(url.Values{
+		"title":      []string{s.Title},
+		"visibility": []string{s.Visibility},
 	})
+	app.render(w, r, "edit.page.tmpl", &templateData{Form: form, Snippet: s, Files: s.Files})
+}
+
+func (app *Application) editSnippet(w http.ResponseWriter, r *http.Request) {
+	s, ok := app.snippetFromURL(w, r)
+	if !ok {
+		return
+	}
+	userID := app.session.GetInt(r, "authenticatedUserID")
+	if s.UserID != userID {
+		app.clientError(w, http.StatusForbidden)
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	form := forms.New(r.PostForm)
+	form.Required("title", "visibility")
+	form.MaxLength("title", 100)
+	form.PermittedValues("visibility", models.VisibilityPublic, models.VisibilityUnlisted, models.VisibilityPrivate)
+	files := filesFromForm(form)
+
+	if !form.Valid() {
+		app.render(w, r, "edit.page.tmpl", &templateData{Form: form, Snippet: s, Files: files})
+		return
+	}
+
+	completeFiles(files)
+	err = app.snippets.Update(r.Context(), s.ID, userID, form.Get("title"), form.Get("visibility"), files)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+	app.session.Put(r, "flash", "Snippet successfully updated!")
+	http.Redirect(w, r, "/snippet/"+s.Slug, http.StatusSeeOther)
+}
+
+// Lists every stored revision of a snippet
+func (app *Application) snippetHistory(w http.ResponseWriter, r *http.Request) {
+	s, ok := app.snippetFromURL(w, r)
+	if !ok {
+		return
+	}
+	// The history of a burn-after-reading snippet would let it be read
+	// without burning it
+	if s.BurnAfterReading {
+		app.notFound(w)
+		return
+	}
+	if !app.unlocked(w, r, s) {
+		return
+	}
+	revisions, err := app.snippets.Revisions(r.Context(), s.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	app.render(w, r, "history.page.tmpl", &templateData{Snippet: s, Revisions: revisions})
+}
+
+// Renders a past version of a snippet using the regular show page
+func (app *Application) showRevision(w http.ResponseWriter, r *http.Request) {
+	version, err := strconv.Atoi(r.URL.Query().Get(":version"))
+	if err != nil || version < 1 {
+		app.notFound(w)
+		return
+	}
+	s, ok := app.snippetFromURL(w, r)
+	if !ok {
+		return
+	}
+	if s.BurnAfterReading {
+		app.notFound(w)
+		return
+	}
+	if !app.unlocked(w, r, s) {
+		return
+	}
+	revisions, err := app.snippets.Revisions(r.Context(), s.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	for _, rev := range revisions {
+		if rev.Version != version {
+			continue
+		}
+		// Copy the snippet so that the title and files shown are those of
+		// the requested version
+		past := *s
+		past.Title = rev.Title
+		past.Content = rev.Content
+		past.Files = rev.Files
+		if len(rev.Files) > 0 {
+			past.Language = rev.Files[0].Language
+		}
+		app.render(w, r, "show.page.tmpl", &templateData{Snippet: &past, Revision: rev})
+		return
+	}
+	app.notFound(w)
+}
+
+// Soft-deletes a snippet owned by the current user
+func (app *Application) deleteSnippet(w http.ResponseWriter, r *http.Request) {
+	slug := r.URL.Query().Get(":slug")
+	err := app.snippets.Delete(r.Context(), slug, app.session.GetInt(r, "authenticatedUserID"))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+	app.session.Put(r, "flash", "Snippet deleted. You can restore it from your profile.")
+	http.Redirect(w, r, "/user/profile", http.StatusSeeOther)
+}
+
+// Restores a snippet the current user deleted within the restore window
+func (app *Application) restoreSnippet(w http.ResponseWriter, r *http.Request) {
+	slug := r.URL.Query().Get(":slug")
+	err := app.snippets.Restore(r.Context(), slug, app.session.GetInt(r, "authenticatedUserID"), app.restoreWindow)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+	app.session.Put(r, "flash", "Snippet successfully restored!")
+	http.Redirect(w, r, "/snippet/"+slug, http.StatusSeeOther)
+}
+
+// Moves the expiry time of a snippet owned by the current user, which brings
+// an expired snippet back until it is purged. Expired snippets can't be
+// fetched with snippetFromURL, so the snippet is looked up here.
+func (app *Application) renewSnippet(w http.ResponseWriter, r *http.Request) {
+	slug := r.URL.Query().Get(":slug")
+	userID := app.session.GetInt(r, "authenticatedUserID")
+	s, err := app.snippets.GetBySlug(r.Context(), slug)
+	if s != nil && s.UserID != userID {
+		app.notFound(w)
+		return
+	}
+	if err != nil && !errors.Is(err, models.ErrExpired) {
+		app.snippetError(w, r, err)
+		return
+	}
+
+	err = r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+	form := forms.New(r.PostForm)
+	form.Required("expires")
+	expires := app.expiryFromForm(form, time.Now())
+	// The errors are shown on a form of their own, as the snippet's page
+	// can't be shown once it has expired
+	if !form.Valid() {
+		app.render(w, r, "renew.page.tmpl", &templateData{Form: form, Snippet: s})
+		return
+	}
+
+	err = app.snippets.Renew(r.Context(), slug, userID, expires)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+	app.session.Put(r, "flash", "Snippet expiry updated!")
+	http.Redirect(w, r, "/snippet/"+slug, http.StatusSeeOther)
 }
 
 func (app *Application) createSnippet(w http.ResponseWriter, r *http.Request) {
@@ -55,20 +919,24 @@ func (app *Application) createSnippet(w http.ResponseWriter, r *http.Request) {
 	// Create a new forms.Form struct containing the POSTed data from the form,
 	// then use the validation methods to check the content.
 	form := forms.New(r.PostForm)
-	form.Required("title", "content", "expires")
-	form.MaxLength("title", 100)
-	form.PermittedValues("expires", "365", "7", "1")
+	expires, files := app.validateSnippetForm(form, time.Now())
 
 	// If the form is not valid then redisplay the create form page
 	if !form.Valid() {
-		app.render(w, r, "create.page.tmpl", &templateData{Form: form})
+		app.render(w, r, "create.page.tmpl", &templateData{Form: form, Files: files})
 		return
 	}
 
+	// The snippet is owned by the user who is currently logged in
+	userID := app.session.GetInt(r, "authenticatedUserID")
+
 	// Because the form data (with type url.Values) has been anonymously embedded
 	// in the form.Form struct, we can use the Get() method to retrieve
 	// the validated value for a particular form field.
-	id, err := app.snippets.Insert(form.Get("title"), form.Get("content"), form.Get("expires"))
+	tags := forms.SplitTags(form.Get("tags"))
+	burn := form.Get("burn") == "true"
+	completeFiles(files)
+	slug, err := app.snippets.Insert(r.Context(), userID, form.Get("title"), files, expires, form.Get("visibility"), burn, form.Get("password"), tags)
 	if err != nil {
 		app.serverError(w, err)
 		return
@@ -78,12 +946,28 @@ func (app *Application) createSnippet(w http.ResponseWriter, r *http.Request) {
 	app.session.Put(r, "flash", "Snippet successfully created!")
 
 	// Redirect the user to the relevant page for the snippet.
-	http.Redirect(w, r, fmt.Sprintf("/snippet/%d", id), http.StatusSeeOther)
+	http.Redirect(w, r, "/snippet/"+slug, http.StatusSeeOther)
+}
+
+// Checks the fields of the create form, adding any problem to the form's
+// errors, and returns the expiry time and files entered on it
+func (app *Application) validateSnippetForm(form *forms.Form, now time.Time) (time.Time, []*models.File) {
+	form.Required("title", "expires", "visibility")
+	form.MaxLength("title", 100)
+	form.PermittedValues("visibility", models.VisibilityPublic, models.VisibilityUnlisted, models.VisibilityPrivate)
+	form.PermittedValues("burn", "true")
+	// bcrypt ignores anything past the first 72 bytes of a password
+	form.MaxLength("password", 72)
+	form.ValidTags("tags", 5, 32)
+	return app.expiryFromForm(form, now), filesFromForm(form)
 }
 
 func (app *Application) createSnippetForm(w http.ResponseWriter, r *http.Request) {
+	form := forms.New(url.Values{})
+	form.Set("expires", app.defaultExpiry())
 	app.render(w, r, "create.page.tmpl", &templateData{
-		Form: forms.New(nil),
+		Form:  form,
+		Files: []*models.File{{}},
 	})
 }
 
@@ -113,7 +997,7 @@ func (app *Application) signupUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = app.users.Insert(form.Get("name"), form.Get("email"), form.Get("password"))
+	err = app.users.Insert(r.Context(), form.Get("name"), form.Get("email"), form.Get("password"))
 	if err != nil {
 		if errors.Is(err, models.ErrDuplicateEmail) {
 			form.Errors.Add("email", "Address is already in use")
@@ -140,7 +1024,7 @@ func (app *Application) loginUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	form := forms.New(r.PostForm)
-	id, err := app.users.Authenticate(form.Get("email"), form.Get("password"))
+	id, err := app.users.Authenticate(r.Context(), form.Get("email"), form.Get("password"))
 	if err != nil {
 		if errors.Is(err, models.ErrInvalidCredentials) {
 			form.Errors.Add("generic", "Email or Password is incorrect")
@@ -179,6 +1063,12 @@ func ping(w http.ResponseWriter, h *http.Request) {
 	w.Write([]byte("OK"))
 }
 
+// Metrics handler which returns the application metrics as JSON
+func metricsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Write([]byte(metrics.String()))
+}
+
 // Render the about page
 func (app *Application) about(w http.ResponseWriter, r *http.Request) {
 	app.render(w, r, "about.page.tmpl", nil)
@@ -186,12 +1076,133 @@ func (app *Application) about(w http.ResponseWriter, r *http.Request) {
 
 func (app *Application) profile(w http.ResponseWriter, r *http.Request) {
 	userID := app.session.GetInt(r, "authenticatedUserID")
-	user, err := app.users.Get(userID)
+	user, err := app.users.Get(r.Context(), userID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	// Fetch the snippets owned by the user for the "My snippets" listing
+	snippets, err := app.snippets.ByUser(r.Context(), userID)
 	if err != nil {
 		app.serverError(w, err)
 		return
 	}
-	app.render(w, r, "profile.page.tmpl", &templateData{User: user})
+	if err = app.loadViews(r.Context(), snippets...); err != nil {
+		app.serverError(w, err)
+		return
+	}
+	// Along with the ones that were deleted recently enough to be restored
+	deleted, err := app.snippets.Deleted(r.Context(), userID, app.restoreWindow)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	app.render(w, r, "profile.page.tmpl", &templateData{
+		User:     user,
+		Snippets: snippets,
+		Deleted:  deleted,
+	})
+}
+
+// Streams a zip archive of every snippet of the current user, as written by
+// writeExport. Expired snippets which haven't been purged yet are left out.
+func (app *Application) exportSnippets(w http.ResponseWriter, r *http.Request) {
+	snippets, err := app.snippets.ByUser(r.Context(), app.session.GetInt(r, "authenticatedUserID"))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	load := func(listed *models.Snippet) (*models.Snippet, error) {
+		s, err := app.snippets.Get(r.Context(), listed.ID)
+		if errors.Is(err, models.ErrExpired) || errors.Is(err, models.ErrDeleted) || errors.Is(err, models.ErrBurned) {
+			return nil, nil
+		}
+		return s, err
+	}
+
+	// The archive is written as it is made, so an error part way through
+	// can only be logged
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": "snippets.zip"}))
+	w.Header().Set("Content-Type", "application/zip")
+	if err := writeExport(w, snippets, load, time.Now()); err != nil {
+		app.errorLog.Println(err)
+	}
+}
+
+func (app *Application) importSnippetsForm(w http.ResponseWriter, r *http.Request) {
+	app.render(w, r, "import.page.tmpl", &templateData{Form: forms.New(nil)})
+}
+
+// Recreates the snippets of an archive made by exportSnippets for the current
+// user. Every snippet has to pass the checks of the create form, and nothing
+// is imported unless they all do, so that a fixed archive can be imported
+// again without making copies. Snippets which have expired since the export
+// are skipped rather than failing the import.
+func (app *Application) importSnippets(w http.ResponseWriter, r *http.Request) {
+	form := forms.New(nil)
+	file, header, err := r.FormFile("archive")
+	if err != nil {
+		form.Errors.Add("archive", "This field cannot be blank")
+		app.render(w, r, "import.page.tmpl", &templateData{Form: form})
+		return
+	}
+	defer file.Close()
+
+	now := time.Now()
+	entries, err := readImport(file, header.Size, now)
+	if err != nil {
+		if isImportError(err) {
+			form.Errors.Add("archive", err.Error())
+			app.render(w, r, "import.page.tmpl", &templateData{Form: form})
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+	expires := make([]time.Time, len(entries))
+	files := make([][]*models.File, len(entries))
+	valid := true
+	skipped := 0
+	for i, e := range entries {
+		if e.Skipped != "" {
+			skipped++
+			continue
+		}
+		expires[i], files[i] = app.validateSnippetForm(e.Form, now)
+		valid = valid && e.Form.Valid()
+	}
+	if !valid {
+		form.Errors.Add("archive", "Some of the snippets can't be imported")
+		app.render(w, r, "import.page.tmpl", &templateData{Form: form, Imports: entries})
+		return
+	}
+
+	userID := app.session.GetInt(r, "authenticatedUserID")
+	for i, e := range entries {
+		if e.Skipped != "" {
+			continue
+		}
+		completeFiles(files[i])
+		tags := forms.SplitTags(e.Form.Get("tags"))
+		burn := e.Form.Get("burn") == "true"
+		_, err := app.snippets.Insert(r.Context(), userID, e.Form.Get("title"), files[i], expires[i], e.Form.Get("visibility"), burn, "", tags)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+	}
+	flash := fmt.Sprintf("Imported %d snippets!", len(entries)-skipped)
+	if len(entries)-skipped == 1 {
+		flash = "Imported 1 snippet!"
+	}
+	switch {
+	case skipped == 1:
+		flash += " 1 snippet had expired and was skipped."
+	case skipped > 1:
+		flash += fmt.Sprintf(" %d snippets had expired and were skipped.", skipped)
+	}
+	app.session.Put(r, "flash", flash)
+	http.Redirect(w, r, "/user/profile", http.StatusSeeOther)
 }
 
 func (app *Application) changePassword(w http.ResponseWriter, r *http.Request) {
@@ -214,7 +1225,7 @@ func (app *Application) changePassword(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	userID := app.session.GetInt(r, "authenticatedUserID")
-	err = app.users.ChangePassword(userID, form.Get("currentPassword"), form.Get("newPassword"))
+	err = app.users.ChangePassword(r.Context(), userID, form.Get("currentPassword"), form.Get("newPassword"))
 	if err != nil {
 		if errors.Is(err, models.ErrInvalidCredentials) {
 			form.Errors.Add("currentPassword", "Current password is correct")
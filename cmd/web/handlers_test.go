@@ -1,10 +1,16 @@
 package main
 
 import (
+	"archive/zip"
 	"bytes"
+	"context"
 	"net/http"
 	"net/url"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestShowSnippet(t *testing.T) {
@@ -19,13 +25,30 @@ func TestShowSnippet(t *testing.T) {
 		wantCode int
 		wantBody []byte
 	}{
-		{"Valid ID", "/snippet/1", http.StatusOK, []byte("An old silent pond...")},
+		{"Valid slug", "/snippet/pond7kq2mzx4wbna", http.StatusOK, []byte("An old silent pond...")},
+		{"Forks", "/snippet/pond7kq2mzx4wbna", http.StatusOK, []byte("<a href='/snippet/many3fq7kd2hwpxa'>Hello service</a>")},
+		{"Forked", "/snippet/many3fq7kd2hwpxa", http.StatusOK, []byte("forked from <a href='/snippet/pond7kq2mzx4wbna'>#1</a>")},
+		{"Several files", "/snippet/many3fq7kd2hwpxa", http.StatusOK, []byte(`id="F2L1"`)},
+		{"Comment", "/snippet/pond7kq2mzx4wbna", http.StatusOK, []byte("<p>A lovely haiku</p>")},
+		{"Line comment", "/snippet/pond7kq2mzx4wbna", http.StatusOK, []byte("<a class='lines' href='#L1'>L1</a>")},
+		{"Stars", "/snippet/pond7kq2mzx4wbna", http.StatusOK, []byte("<span class='stars'>2 stars</span>")},
+		{"Author", "/snippet/pond7kq2mzx4wbna", http.StatusOK, []byte("by Alice")},
+		{"Legacy ID", "/snippet/1", http.StatusMovedPermanently, nil},
+		{"Private slug", "/snippet/frog3hd5ncq2wyta", http.StatusNotFound, nil},
+		{"Private legacy ID", "/snippet/6", http.StatusNotFound, nil},
+		{"Non-existent slug", "/snippet/nosuchsnippetxyz", http.StatusNotFound, nil},
 		{"Non-existent ID", "/snippet/2", http.StatusNotFound, nil},
+		{"Deleted slug", "/snippet/gone4ma7pqz2xkrb", http.StatusGone, []byte("This snippet has been deleted")},
+		{"Burned slug", "/snippet/ash2fz6rwkq4nbpe", http.StatusGone, []byte("This snippet has been burned")},
+		{"Deleted private slug", "/snippet/hush6vb3kqm8zdte", http.StatusNotFound, nil},
+		{"Deleted ID", "/snippet/3", http.StatusGone, []byte("This snippet has been deleted")},
+		{"Expired ID", "/snippet/4", http.StatusGone, []byte("This snippet has expired")},
+		{"Canceled Query", "/snippet/5", http.StatusServiceUnavailable, nil},
 		{"Negative ID", "/snippet/-1", http.StatusNotFound, nil},
 		{"Decimal ID", "/snippet/1.23", http.StatusNotFound, nil},
 		{"String ID", "/snippet/foo", http.StatusNotFound, nil},
 		{"Empty ID", "/snippet/", http.StatusNotFound, nil},
-		{"Trailing slash", "/snippet/1/", http.StatusNotFound, nil},
+		{"Trailing slash", "/snippet/pond7kq2mzx4wbna/", http.StatusNotFound, nil},
 	}
 
 	for _, tt := range tests {
@@ -42,6 +65,326 @@ func TestShowSnippet(t *testing.T) {
 
 }
 
+func TestLegacySnippetRedirect(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name         string
+		urlPath      string
+		wantLocation string
+	}{
+		{"Snippet", "/snippet/1", "/snippet/pond7kq2mzx4wbna"},
+		{"History", "/snippet/1/history", "/snippet/pond7kq2mzx4wbna/history"},
+		{"Past version", "/snippet/1/history/1", "/snippet/pond7kq2mzx4wbna/history/1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, headers, _ := ts.get(t, tt.urlPath)
+			if code != http.StatusMovedPermanently {
+				t.Errorf("want %d; got %d", http.StatusMovedPermanently, code)
+			}
+			if headers.Get("Location") != tt.wantLocation {
+				t.Errorf("want %q; got %q", tt.wantLocation, headers.Get("Location"))
+			}
+		})
+	}
+}
+
+func TestPrivateSnippet(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+
+	code, _, body := ts.get(t, "/snippet/frog3hd5ncq2wyta")
+	if code != http.StatusOK {
+		t.Errorf("want %d; got %d", http.StatusOK, code)
+	}
+	if !bytes.Contains(body, []byte("A frog jumps in...")) {
+		t.Errorf("want body to contain %q", "A frog jumps in...")
+	}
+}
+
+func TestBurnSnippet(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	// Viewing the link only shows the interstitial
+	code, _, body := ts.get(t, "/snippet/burn5tk3qv7dmxza")
+	if code != http.StatusOK {
+		t.Errorf("want %d; got %d", http.StatusOK, code)
+	}
+	if !bytes.Contains(body, []byte("This snippet can only be read once")) {
+		t.Errorf("want body to contain %q", "This snippet can only be read once")
+	}
+	if bytes.Contains(body, []byte("hunter2")) {
+		t.Errorf("want body not to contain %q", "hunter2")
+	}
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name      string
+		urlPath   string
+		csrfToken string
+		wantCode  int
+		wantBody  []byte
+	}{
+		{"Burn", "/snippet/burn5tk3qv7dmxza/burn", csrfToken, http.StatusOK, []byte("hunter2")},
+		{"Already burned", "/snippet/ash2fz6rwkq4nbpe/burn", csrfToken, http.StatusGone, []byte("This snippet has been burned")},
+		{"Not burn after reading", "/snippet/pond7kq2mzx4wbna/burn", csrfToken, http.StatusNotFound, nil},
+		{"Non-existent slug", "/snippet/nosuchsnippetxyz/burn", csrfToken, http.StatusNotFound, nil},
+		{"Invalid CSRF Token", "/snippet/burn5tk3qv7dmxza/burn", "wrongToken", http.StatusBadRequest, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("csrf_token", tt.csrfToken)
+
+			code, headers, body := ts.postForm(t, tt.urlPath, form)
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+			if code == http.StatusOK && headers.Get("Cache-Control") != "no-store" {
+				t.Errorf("want Cache-Control %q; got %q", "no-store", headers.Get("Cache-Control"))
+			}
+		})
+	}
+
+	// The history would reveal the content without burning it
+	for _, urlPath := range []string{"/snippet/burn5tk3qv7dmxza/history", "/snippet/burn5tk3qv7dmxza/history/1"} {
+		if code, _, _ := ts.get(t, urlPath); code != http.StatusNotFound {
+			t.Errorf("%s: want %d; got %d", urlPath, http.StatusNotFound, code)
+		}
+	}
+}
+
+func TestUnlockSnippet(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, _, body := ts.get(t, "/snippet/lock4wq7hn2cpzre")
+	if code != http.StatusOK {
+		t.Errorf("want %d; got %d", http.StatusOK, code)
+	}
+	if !bytes.Contains(body, []byte("protected by a password")) || bytes.Contains(body, []byte("The pond is frozen")) {
+		t.Errorf("want the unlock form without the content; got %s", body)
+	}
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name         string
+		password     string
+		wantCode     int
+		wantLocation string
+		wantBody     []byte
+	}{
+		{"Empty password", "", http.StatusOK, "", []byte("This field cannot be blank")},
+		{"Wrong password", "open sesame!", http.StatusOK, "", []byte("Password is incorrect")},
+		{"Right password", "open sesame", http.StatusSeeOther, "/snippet/lock4wq7hn2cpzre", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("password", tt.password)
+			form.Add("csrf_token", csrfToken)
+
+			code, headers, body := ts.postForm(t, "/snippet/lock4wq7hn2cpzre/unlock", form)
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+			if headers.Get("Location") != tt.wantLocation {
+				t.Errorf("want %q; got %q", tt.wantLocation, headers.Get("Location"))
+			}
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body %s to contain %q", body, tt.wantBody)
+			}
+		})
+	}
+
+	// The session remembers that the snippet was unlocked
+	_, _, body = ts.get(t, "/snippet/lock4wq7hn2cpzre")
+	if !bytes.Contains(body, []byte("The pond is frozen")) {
+		t.Errorf("want body to contain %q", "The pond is frozen")
+	}
+	_, _, body = ts.get(t, "/snippet/lock4wq7hn2cpzre/history")
+	if bytes.Contains(body, []byte("protected by a password")) {
+		t.Errorf("want the history without the unlock form")
+	}
+}
+
+func TestUnlockSnippetThrottle(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	_, _, body := ts.get(t, "/snippet/lock4wq7hn2cpzre")
+	form := url.Values{}
+	form.Add("password", "wrong password")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+
+	for i := 0; i < maxUnlockFailures; i++ {
+		if code, _, _ := ts.postForm(t, "/snippet/lock4wq7hn2cpzre/unlock", form); code != http.StatusOK {
+			t.Fatalf("attempt %d: want %d; got %d", i+1, http.StatusOK, code)
+		}
+	}
+
+	// Even the right password is refused until the failures expire
+	form.Set("password", "open sesame")
+	code, _, body := ts.postForm(t, "/snippet/lock4wq7hn2cpzre/unlock", form)
+	if code != http.StatusTooManyRequests {
+		t.Errorf("want %d; got %d", http.StatusTooManyRequests, code)
+	}
+	if !bytes.Contains(body, []byte("Too many wrong passwords")) {
+		t.Errorf("want body to contain %q", "Too many wrong passwords")
+	}
+}
+
+func TestUnlockSnippetConcurrent(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	_, _, body := ts.get(t, "/snippet/lock4wq7hn2cpzre")
+	form := url.Values{}
+	form.Add("password", "wrong password")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+
+	// Wrong guesses made at the same time are throttled like ones made one
+	// after the other
+	codes := make(chan int, 4*maxUnlockFailures)
+	var wg sync.WaitGroup
+	for i := 0; i < cap(codes); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rs, err := ts.Client().PostForm(ts.URL+"/snippet/lock4wq7hn2cpzre/unlock", form)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			rs.Body.Close()
+			codes <- rs.StatusCode
+		}()
+	}
+	wg.Wait()
+	close(codes)
+
+	checked := 0
+	for code := range codes {
+		if code == http.StatusOK {
+			checked++
+		}
+	}
+	if checked != maxUnlockFailures {
+		t.Errorf("want %d guesses checked; got %d", maxUnlockFailures, checked)
+	}
+}
+
+func TestUnlockSnippetOwner(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+
+	_, _, body := ts.get(t, "/snippet/lock4wq7hn2cpzre")
+	if !bytes.Contains(body, []byte("The pond is frozen")) {
+		t.Errorf("want the owner to see the content without unlocking it")
+	}
+}
+
+func TestRawSnippet(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name            string
+		urlPath         string
+		wantCode        int
+		wantBody        []byte
+		wantDisposition string
+	}{
+		{"Raw", "/snippet/pond7kq2mzx4wbna/raw", http.StatusOK, []byte("An old silent pond..."), ""},
+		{"Download", "/snippet/pond7kq2mzx4wbna/download", http.StatusOK, []byte("An old silent pond..."), "attachment; filename=an-old-silent-pond.txt"},
+		{"First file", "/snippet/many3fq7kd2hwpxa/raw", http.StatusOK, []byte("FROM golang:1.16\n"), ""},
+		{"File", "/snippet/many3fq7kd2hwpxa/raw/main.go", http.StatusOK, []byte("package main\n"), ""},
+		{"Non-existent file", "/snippet/many3fq7kd2hwpxa/raw/main.py", http.StatusNotFound, nil, ""},
+		{"File of protected", "/snippet/lock4wq7hn2cpzre/raw/file1", http.StatusForbidden, nil, ""},
+		{"Private", "/snippet/frog3hd5ncq2wyta/raw", http.StatusNotFound, nil, ""},
+		{"Burn after reading", "/snippet/burn5tk3qv7dmxza/raw", http.StatusNotFound, nil, ""},
+		{"Protected", "/snippet/lock4wq7hn2cpzre/download", http.StatusForbidden, nil, ""},
+		{"Deleted", "/snippet/gone4ma7pqz2xkrb/raw", http.StatusGone, nil, ""},
+		{"Non-existent slug", "/snippet/nosuchsnippetxyz/raw", http.StatusNotFound, nil, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, headers, body := ts.get(t, tt.urlPath)
+			if code != tt.wantCode {
+				t.Fatalf("want %d; got %d", tt.wantCode, code)
+			}
+			if code != http.StatusOK {
+				return
+			}
+			if !bytes.Equal(body, tt.wantBody) {
+				t.Errorf("want body %q; got %q", tt.wantBody, body)
+			}
+			if ct := headers.Get("Content-Type"); ct != "text/plain; charset=utf-8" {
+				t.Errorf("want plain text; got %q", ct)
+			}
+			if d := headers.Get("Content-Disposition"); d != tt.wantDisposition {
+				t.Errorf("want disposition %q; got %q", tt.wantDisposition, d)
+			}
+		})
+	}
+
+	// The legacy integer URLs redirect like the snippet page
+	code, headers, _ := ts.get(t, "/snippet/1/raw")
+	if code != http.StatusMovedPermanently || headers.Get("Location") != "/snippet/pond7kq2mzx4wbna/raw" {
+		t.Errorf("want %d to the slug; got %d to %q", http.StatusMovedPermanently, code, headers.Get("Location"))
+	}
+}
+
+func TestDownloadSnippetFiles(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, headers, body := ts.get(t, "/snippet/many3fq7kd2hwpxa/download")
+	if code != http.StatusOK {
+		t.Fatalf("want %d; got %d", http.StatusOK, code)
+	}
+	if ct := headers.Get("Content-Type"); ct != "application/zip" {
+		t.Errorf("want a zip archive; got %q", ct)
+	}
+	if d := headers.Get("Content-Disposition"); d != "attachment; filename=hello-service.zip" {
+		t.Errorf("want the archive named after the title; got %q", d)
+	}
+	r, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, f := range r.File {
+		names = append(names, f.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"Dockerfile", "main.go"}) {
+		t.Errorf("want both files in the archive; got %v", names)
+	}
+}
+
 func TestPing(t *testing.T) {
 	app := newTestApplication(t)
 	ts := newTestServer(t, app.routes())
@@ -143,3 +486,1514 @@ func TestCreateSnippetForm(t *testing.T) {
 
 	})
 }
+
+func TestProfile(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+
+	statusCode, _, body := ts.get(t, "/user/profile")
+	if statusCode != http.StatusOK {
+		t.Errorf("want %d; got %d", http.StatusOK, statusCode)
+	}
+	for _, want := range []string{"My Snippets", "An old silent pond", "<td>42</td>"} {
+		if !bytes.Contains(body, []byte(want)) {
+			t.Errorf("want body to contain %q", want)
+		}
+	}
+}
+
+func TestStarredSnippets(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, headers, _ := ts.get(t, "/user/starred")
+	if code != http.StatusSeeOther || headers.Get("Location") != "/user/login" {
+		t.Errorf("anonymous: want %d to /user/login; got %d to %q", http.StatusSeeOther, code, headers.Get("Location"))
+	}
+
+	ts.login(t)
+	code, _, body := ts.get(t, "/user/starred")
+	if code != http.StatusOK {
+		t.Errorf("want %d; got %d", http.StatusOK, code)
+	}
+	for _, want := range []string{"Starred Snippets", "<a href='/snippet/pond7kq2mzx4wbna'>An old silent pond</a>", "<td>2</td>"} {
+		if !bytes.Contains(body, []byte(want)) {
+			t.Errorf("want body to contain %q", want)
+		}
+	}
+}
+
+func TestToggleStar(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+	_, _, body := ts.get(t, "/snippet/pond7kq2mzx4wbna")
+	if !bytes.Contains(body, []byte("<button>Unstar</button>")) {
+		t.Error("want an Unstar button for the starred snippet")
+	}
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name         string
+		urlPath      string
+		wantCode     int
+		wantLocation string
+	}{
+		{"Unstar", "/snippet/pond7kq2mzx4wbna/star", http.StatusSeeOther, "/snippet/pond7kq2mzx4wbna"},
+		{"Star", "/snippet/many3fq7kd2hwpxa/star", http.StatusSeeOther, "/snippet/many3fq7kd2hwpxa"},
+		{"Burn after reading", "/snippet/burn5tk3qv7dmxza/star", http.StatusNotFound, ""},
+		{"Deleted", "/snippet/gone4ma7pqz2xkrb/star", http.StatusGone, ""},
+		{"Non-existent slug", "/snippet/nosuchsnippetxyz/star", http.StatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("csrf_token", csrfToken)
+
+			code, headers, _ := ts.postForm(t, tt.urlPath, form)
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+			if headers.Get("Location") != tt.wantLocation {
+				t.Errorf("want %q; got %q", tt.wantLocation, headers.Get("Location"))
+			}
+		})
+	}
+}
+
+func TestStarJourney(t *testing.T) {
+	app := newMemoryTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ctx := context.Background()
+	if err := app.users.Insert(ctx, "Alice", "alice@example.com", "validPa$$word"); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.users.Insert(ctx, "Bob", "bob@example.com", "validPa$$word"); err != nil {
+		t.Fatal(err)
+	}
+	slug, err := app.snippets.Insert(ctx, 1, "An old pond", oneFile("A frog jumps in", ""), nextWeek, "public", false, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	login := func(email string) string {
+		_, _, body := ts.get(t, "/user/login")
+		form := url.Values{}
+		form.Add("email", email)
+		form.Add("password", "validPa$$word")
+		form.Add("csrf_token", extractCSRFToken(t, body))
+		ts.postForm(t, "/user/login", form)
+		_, _, body = ts.get(t, "/snippet/"+slug)
+		return extractCSRFToken(t, body)
+	}
+
+	// Bob stars the snippet
+	csrfToken := login("bob@example.com")
+	form := url.Values{}
+	form.Add("csrf_token", csrfToken)
+	if code, _, _ := ts.postForm(t, "/snippet/"+slug+"/star", form); code != http.StatusSeeOther {
+		t.Fatalf("star: want %d; got %d", http.StatusSeeOther, code)
+	}
+	_, _, body := ts.get(t, "/snippet/"+slug)
+	for _, want := range []string{"1 star<", "<button>Unstar</button>"} {
+		if !bytes.Contains(body, []byte(want)) {
+			t.Errorf("show: want body to contain %q", want)
+		}
+	}
+
+	// Alice renames the snippet, which stays on Bob's starred page
+	ts.postForm(t, "/user/logout", form)
+	csrfToken = login("alice@example.com")
+	form = url.Values{}
+	form.Add("title", "A new pond")
+	form.Add("content", "A frog jumps in")
+	form.Add("visibility", "public")
+	form.Add("csrf_token", csrfToken)
+	if code, _, _ := ts.postForm(t, "/snippet/"+slug+"/edit", form); code != http.StatusSeeOther {
+		t.Fatalf("edit: want %d; got %d", http.StatusSeeOther, code)
+	}
+	form = url.Values{}
+	form.Add("csrf_token", csrfToken)
+	ts.postForm(t, "/user/logout", form)
+
+	csrfToken = login("bob@example.com")
+	_, _, body = ts.get(t, "/user/starred")
+	if !bytes.Contains(body, []byte("<a href='/snippet/"+slug+"'>A new pond</a>")) {
+		t.Errorf("starred: want the snippet listed under its new title; got %s", body)
+	}
+
+	// Starring again removes the star
+	form = url.Values{}
+	form.Add("csrf_token", csrfToken)
+	ts.postForm(t, "/snippet/"+slug+"/star", form)
+	_, _, body = ts.get(t, "/user/starred")
+	if bytes.Contains(body, []byte(slug)) {
+		t.Error("starred: want the snippet gone once unstarred")
+	}
+	_, _, body = ts.get(t, "/snippet/"+slug)
+	if !bytes.Contains(body, []byte("0 stars<")) {
+		t.Error("show: want 0 stars")
+	}
+}
+
+func TestEditSnippet(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+	_, _, body := ts.get(t, "/snippet/pond7kq2mzx4wbna/edit")
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name       string
+		urlPath    string
+		title      string
+		content    string
+		visibility string
+		wantCode   int
+		wantBody   []byte
+	}{
+		{"Valid submission", "/snippet/pond7kq2mzx4wbna/edit", "An old pond", "A frog jumps in", "unlisted", http.StatusSeeOther, nil},
+		{"Empty title", "/snippet/pond7kq2mzx4wbna/edit", "", "A frog jumps in", "public", http.StatusOK, []byte("This field cannot be blank")},
+		{"Empty content", "/snippet/pond7kq2mzx4wbna/edit", "An old pond", "", "public", http.StatusOK, []byte("This field cannot be blank")},
+		{"Invalid visibility", "/snippet/pond7kq2mzx4wbna/edit", "An old pond", "A frog jumps in", "secret", http.StatusOK, []byte("This field is invalid")},
+		{"Non-existent slug", "/snippet/nosuchsnippetxyz/edit", "An old pond", "A frog jumps in", "public", http.StatusNotFound, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("title", tt.title)
+			form.Add("content", tt.content)
+			form.Add("visibility", tt.visibility)
+			form.Add("csrf_token", csrfToken)
+
+			code, _, body := ts.postForm(t, tt.urlPath, form)
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body %s to contain %q", body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestSnippetHistory(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody []byte
+	}{
+		{"History", "/snippet/pond7kq2mzx4wbna/history", http.StatusOK, []byte("/snippet/pond7kq2mzx4wbna/history/1")},
+		{"Past version", "/snippet/pond7kq2mzx4wbna/history/1", http.StatusOK, []byte("You are viewing version 1")},
+		{"Non-existent version", "/snippet/pond7kq2mzx4wbna/history/2", http.StatusNotFound, nil},
+		{"Non-existent slug", "/snippet/nosuchsnippetxyz/history", http.StatusNotFound, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestDeleteSnippet(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+	_, _, body := ts.get(t, "/snippet/pond7kq2mzx4wbna")
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name         string
+		urlPath      string
+		wantCode     int
+		wantLocation string
+	}{
+		{"Delete", "/snippet/pond7kq2mzx4wbna/delete", http.StatusSeeOther, "/user/profile"},
+		{"Delete by ID", "/snippet/1/delete", http.StatusNotFound, ""},
+		{"Delete non-existent slug", "/snippet/nosuchsnippetxyz/delete", http.StatusNotFound, ""},
+		{"Restore", "/snippet/gone4ma7pqz2xkrb/restore", http.StatusSeeOther, "/snippet/gone4ma7pqz2xkrb"},
+		{"Restore non-existent slug", "/snippet/nosuchsnippetxyz/restore", http.StatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("csrf_token", csrfToken)
+
+			code, headers, _ := ts.postForm(t, tt.urlPath, form)
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+			if headers.Get("Location") != tt.wantLocation {
+				t.Errorf("want %q; got %q", tt.wantLocation, headers.Get("Location"))
+			}
+		})
+	}
+}
+
+func TestRenewSnippet(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+	_, _, body := ts.get(t, "/snippet/pond7kq2mzx4wbna")
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name         string
+		urlPath      string
+		expires      string
+		wantCode     int
+		wantLocation string
+		wantBody     []byte
+	}{
+		{"Renew", "/snippet/pond7kq2mzx4wbna/renew", "30d", http.StatusSeeOther, "/snippet/pond7kq2mzx4wbna", nil},
+		{"Never expire", "/snippet/pond7kq2mzx4wbna/renew", "never", http.StatusSeeOther, "/snippet/pond7kq2mzx4wbna", nil},
+		{"Invalid expiry", "/snippet/pond7kq2mzx4wbna/renew", "2", http.StatusOK, "", []byte("This field is invalid")},
+		{"Empty expiry", "/snippet/pond7kq2mzx4wbna/renew", "", http.StatusOK, "", []byte("This field cannot be blank")},
+		{"Somebody else's snippet", "/snippet/frog3hd5ncq2wyta/renew", "30d", http.StatusNotFound, "", nil},
+		{"Deleted snippet", "/snippet/gone4ma7pqz2xkrb/renew", "30d", http.StatusGone, "", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("expires", tt.expires)
+			form.Add("csrf_token", csrfToken)
+
+			code, headers, body := ts.postForm(t, tt.urlPath, form)
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+			if headers.Get("Location") != tt.wantLocation {
+				t.Errorf("want %q; got %q", tt.wantLocation, headers.Get("Location"))
+			}
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestForkSnippet(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+	_, _, body := ts.get(t, "/snippet/pond7kq2mzx4wbna")
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name         string
+		urlPath      string
+		wantCode     int
+		wantLocation string
+	}{
+		{"Fork", "/snippet/pond7kq2mzx4wbna/fork", http.StatusSeeOther, "/snippet/fork2snippetslug/edit"},
+		{"Own private snippet", "/snippet/frog3hd5ncq2wyta/fork", http.StatusSeeOther, "/snippet/fork2snippetslug/edit"},
+		{"Burn after reading", "/snippet/burn5tk3qv7dmxza/fork", http.StatusNotFound, ""},
+		{"Deleted", "/snippet/gone4ma7pqz2xkrb/fork", http.StatusGone, ""},
+		{"Non-existent slug", "/snippet/nosuchsnippetxyz/fork", http.StatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("csrf_token", csrfToken)
+
+			code, headers, _ := ts.postForm(t, tt.urlPath, form)
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+			if headers.Get("Location") != tt.wantLocation {
+				t.Errorf("want %q; got %q", tt.wantLocation, headers.Get("Location"))
+			}
+		})
+	}
+}
+
+func TestForkSnippetJourney(t *testing.T) {
+	app := newMemoryTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ctx := context.Background()
+	if err := app.users.Insert(ctx, "Alice", "alice@example.com", "validPa$$word"); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.users.Insert(ctx, "Bob", "bob@example.com", "validPa$$word"); err != nil {
+		t.Fatal(err)
+	}
+	public, err := app.snippets.Insert(ctx, 1, "An old pond", oneFile("A frog jumps in", ""), nextWeek, "public", false, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unlisted, err := app.snippets.Insert(ctx, 1, "Over the wintry forest", oneFile("Winds howl in rage", ""), nextWeek, "unlisted", false, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Bob forks both snippets and is taken to the edit page of each fork
+	_, _, body := ts.get(t, "/user/login")
+	form := url.Values{}
+	form.Add("email", "bob@example.com")
+	form.Add("password", "validPa$$word")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+	ts.postForm(t, "/user/login", form)
+
+	forks := map[string]string{}
+	for _, slug := range []string{public, unlisted} {
+		_, _, body = ts.get(t, "/snippet/"+slug)
+		form = url.Values{}
+		form.Add("csrf_token", extractCSRFToken(t, body))
+		code, headers, _ := ts.postForm(t, "/snippet/"+slug+"/fork", form)
+		location := headers.Get("Location")
+		if code != http.StatusSeeOther || !strings.HasSuffix(location, "/edit") {
+			t.Fatalf("fork: want %d to the edit page; got %d to %q", http.StatusSeeOther, code, location)
+		}
+		code, _, _ = ts.get(t, location)
+		if code != http.StatusOK {
+			t.Errorf("edit: want %d; got %d", http.StatusOK, code)
+		}
+		forks[slug] = strings.TrimSuffix(location, "/edit")
+	}
+
+	// The fork of the public snippet links back to it and is listed on it.
+	// The fork of the unlisted snippet doesn't give away its slug.
+	tests := []struct {
+		name     string
+		urlPath  string
+		want     []string
+		dontWant []string
+	}{
+		{"Fork", forks[public], []string{"forked from <a href='/snippet/" + public + "'>#1</a>"}, nil},
+		{"Parent", "/snippet/" + public, []string{"<h3>Forks</h3>", "href='" + forks[public] + "'"}, nil},
+		{"Fork of unlisted", forks[unlisted], []string{"forked from #2"}, []string{unlisted}},
+		{"Unlisted parent", "/snippet/" + unlisted, nil, []string{"<h3>Forks</h3>"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, body := ts.get(t, tt.urlPath)
+			for _, want := range tt.want {
+				if !bytes.Contains(body, []byte(want)) {
+					t.Errorf("want body to contain %q", want)
+				}
+			}
+			for _, dontWant := range tt.dontWant {
+				if bytes.Contains(body, []byte(dontWant)) {
+					t.Errorf("want body not to contain %q", dontWant)
+				}
+			}
+		})
+	}
+}
+
+func TestCreateComment(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+	_, _, body := ts.get(t, "/snippet/pond7kq2mzx4wbna")
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name         string
+		urlPath      string
+		body         string
+		lines        string
+		wantCode     int
+		wantLocation string
+		wantBody     []byte
+	}{
+		{"Comment", "/snippet/pond7kq2mzx4wbna/comments", "Lovely", "", http.StatusSeeOther, "/snippet/pond7kq2mzx4wbna#comment-3", nil},
+		{"Line comment", "/snippet/pond7kq2mzx4wbna/comments", "Lovely", "L1", http.StatusSeeOther, "/snippet/pond7kq2mzx4wbna#comment-3", nil},
+		{"Second file", "/snippet/many3fq7kd2hwpxa/comments", "Lovely", "#F2L1", http.StatusSeeOther, "/snippet/many3fq7kd2hwpxa#comment-3", nil},
+		{"Blank body", "/snippet/pond7kq2mzx4wbna/comments", " ", "", http.StatusOK, "", []byte("This field cannot be blank")},
+		{"Invalid lines", "/snippet/pond7kq2mzx4wbna/comments", "Lovely", "line 1", http.StatusOK, "", []byte("This field is invalid")},
+		{"Past the end", "/snippet/pond7kq2mzx4wbna/comments", "Lovely", "L1-L2", http.StatusOK, "", []byte("This file only has 1 lines")},
+		{"No such file", "/snippet/pond7kq2mzx4wbna/comments", "Lovely", "F2L1", http.StatusOK, "", []byte("This snippet only has 1 files")},
+		{"Burn after reading", "/snippet/burn5tk3qv7dmxza/comments", "Lovely", "", http.StatusNotFound, "", nil},
+		{"Deleted", "/snippet/gone4ma7pqz2xkrb/comments", "Lovely", "", http.StatusGone, "", nil},
+		{"Non-existent slug", "/snippet/nosuchsnippetxyz/comments", "Lovely", "", http.StatusNotFound, "", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("body", tt.body)
+			form.Add("lines", tt.lines)
+			form.Add("csrf_token", csrfToken)
+
+			code, headers, body := ts.postForm(t, tt.urlPath, form)
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+			if headers.Get("Location") != tt.wantLocation {
+				t.Errorf("want %q; got %q", tt.wantLocation, headers.Get("Location"))
+			}
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestDeleteComment(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+	_, _, body := ts.get(t, "/snippet/pond7kq2mzx4wbna")
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name         string
+		urlPath      string
+		wantCode     int
+		wantLocation string
+	}{
+		{"Delete", "/snippet/pond7kq2mzx4wbna/comments/1/delete", http.StatusSeeOther, "/snippet/pond7kq2mzx4wbna#comments"},
+		{"Other snippet", "/snippet/many3fq7kd2hwpxa/comments/1/delete", http.StatusNotFound, ""},
+		{"Non-existent comment", "/snippet/pond7kq2mzx4wbna/comments/99/delete", http.StatusNotFound, ""},
+		{"Invalid ID", "/snippet/pond7kq2mzx4wbna/comments/foo/delete", http.StatusNotFound, ""},
+		{"Non-existent slug", "/snippet/nosuchsnippetxyz/comments/1/delete", http.StatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("csrf_token", csrfToken)
+
+			code, headers, _ := ts.postForm(t, tt.urlPath, form)
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+			if headers.Get("Location") != tt.wantLocation {
+				t.Errorf("want %q; got %q", tt.wantLocation, headers.Get("Location"))
+			}
+		})
+	}
+}
+
+func TestCommentJourney(t *testing.T) {
+	app := newMemoryTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ctx := context.Background()
+	if err := app.users.Insert(ctx, "Alice", "alice@example.com", "validPa$$word"); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.users.Insert(ctx, "Bob", "bob@example.com", "validPa$$word"); err != nil {
+		t.Fatal(err)
+	}
+	slug, err := app.snippets.Insert(ctx, 1, "An old pond", oneFile("An old silent pond\nA frog jumps into the pond\nSplash! Silence again.\n", ""), nextWeek, "public", false, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	login := func(email string) string {
+		_, _, body := ts.get(t, "/user/login")
+		form := url.Values{}
+		form.Add("email", email)
+		form.Add("password", "validPa$$word")
+		form.Add("csrf_token", extractCSRFToken(t, body))
+		ts.postForm(t, "/user/login", form)
+		_, _, body = ts.get(t, "/snippet/"+slug)
+		return extractCSRFToken(t, body)
+	}
+
+	// Bob comments on the snippet and on its second line
+	csrfToken := login("bob@example.com")
+	for _, lines := range []string{"", "L2"} {
+		form := url.Values{}
+		form.Add("body", "Comment on "+lines)
+		form.Add("lines", lines)
+		form.Add("csrf_token", csrfToken)
+		code, _, _ := ts.postForm(t, "/snippet/"+slug+"/comments", form)
+		if code != http.StatusSeeOther {
+			t.Fatalf("comment: want %d; got %d", http.StatusSeeOther, code)
+		}
+	}
+
+	// The line comment is shown between the second and third lines, and only
+	// the owner can delete comments
+	_, _, body := ts.get(t, "/snippet/"+slug)
+	line2 := bytes.Index(body, []byte(`id="L2"`))
+	comment := bytes.Index(body, []byte("Comment on L2"))
+	line3 := bytes.Index(body, []byte(`id="L3"`))
+	if line2 < 0 || !(line2 < comment && comment < line3) {
+		t.Errorf("want the line comment between L2 and L3; got %d, %d, %d", line2, comment, line3)
+	}
+	if bytes.Contains(body, []byte("/comments/1/delete")) {
+		t.Error("want no delete button for Bob")
+	}
+	form := url.Values{}
+	form.Add("csrf_token", csrfToken)
+	code, _, _ := ts.postForm(t, "/snippet/"+slug+"/comments/1/delete", form)
+	if code != http.StatusForbidden {
+		t.Errorf("delete by Bob: want %d; got %d", http.StatusForbidden, code)
+	}
+
+	// Alice deletes the comment on her snippet
+	ts.postForm(t, "/user/logout", form)
+	csrfToken = login("alice@example.com")
+	form = url.Values{}
+	form.Add("csrf_token", csrfToken)
+	code, _, _ = ts.postForm(t, "/snippet/"+slug+"/comments/1/delete", form)
+	if code != http.StatusSeeOther {
+		t.Errorf("delete by Alice: want %d; got %d", http.StatusSeeOther, code)
+	}
+	_, _, body = ts.get(t, "/snippet/"+slug)
+	if bytes.Contains(body, []byte("Comment on </p>")) {
+		t.Error("want the deleted comment to be gone")
+	}
+	if !bytes.Contains(body, []byte("/comments/2/delete")) {
+		t.Error("want a delete button for Alice")
+	}
+}
+
+func TestHome(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody []byte
+	}{
+		{"First page", "/", http.StatusOK, []byte("An old silent pond")},
+		{"Stars", "/", http.StatusOK, []byte("<td>2</td>")},
+		{"Custom page size", "/?limit=5", http.StatusOK, []byte("An old silent pond")},
+		{"Older page", "/?before=1609459200,1", http.StatusOK, []byte("There's nothing to see here")},
+		{"Invalid cursor", "/?before=foo", http.StatusBadRequest, nil},
+		{"Both cursors", "/?before=1609459200,1&after=1609459200,1", http.StatusBadRequest, nil},
+		{"Zero page size", "/?limit=0", http.StatusBadRequest, nil},
+		{"Page size too large", "/?limit=1000", http.StatusBadRequest, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestSearchSnippets(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody []byte
+	}{
+		{"Empty query", "/snippet/search", http.StatusOK, []byte("<form action='/snippet/search'")},
+		{"Match", "/snippet/search?q=silent", http.StatusOK, []byte("An old <mark>silent</mark> pond")},
+		{"No match", "/snippet/search?q=frog", http.StatusOK, []byte("No snippets matched your search")},
+		{"Query too long", "/snippet/search?q=" + strings.Repeat("a", 101), http.StatusOK, []byte("This field is too long")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestCreateSnippet(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+	_, _, body := ts.get(t, "/snippet/create")
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name         string
+		title        string
+		content      string
+		expires      string
+		expiresAt    string
+		visibility   string
+		language     string
+		tags         string
+		wantCode     int
+		wantLocation string
+		wantBody     []byte
+	}{
+		{"Valid submission", "An old pond", "A frog jumps in", "7d", "", "public", "", "", http.StatusSeeOther, "/snippet/new2snippetslugx", nil},
+		{"Valid tags", "An old pond", "A frog jumps in", "7d", "", "public", "", "haiku, Poetry basho", http.StatusSeeOther, "/snippet/new2snippetslugx", nil},
+		{"Private", "An old pond", "A frog jumps in", "7d", "", "private", "", "", http.StatusSeeOther, "/snippet/new2snippetslugx", nil},
+		{"Empty title", "", "A frog jumps in", "7d", "", "public", "", "", http.StatusOK, "", []byte("This field cannot be blank")},
+		{"Invalid expiry", "An old pond", "A frog jumps in", "2", "", "public", "", "", http.StatusOK, "", []byte("This field is invalid")},
+		{"Never expires", "An old pond", "A frog jumps in", "never", "", "public", "", "", http.StatusSeeOther, "/snippet/new2snippetslugx", nil},
+		{"Custom expiry", "An old pond", "A frog jumps in", "custom", time.Now().UTC().Add(time.Hour).Format(customExpiryFormat), "public", "", "", http.StatusSeeOther, "/snippet/new2snippetslugx", nil},
+		{"Past custom expiry", "An old pond", "A frog jumps in", "custom", "2020-01-01T10:00", "public", "", "", http.StatusOK, "", []byte("This time has already passed")},
+		{"Empty custom expiry", "An old pond", "A frog jumps in", "custom", "", "public", "", "", http.StatusOK, "", []byte("This field cannot be blank")},
+		{"Language", "An old pond", "A frog jumps in", "7d", "", "public", "go", "", http.StatusSeeOther, "/snippet/new2snippetslugx", nil},
+		{"Invalid language", "An old pond", "A frog jumps in", "7d", "", "public", "cobol-ish", "", http.StatusOK, "", []byte("This field is invalid")},
+		{"Empty visibility", "An old pond", "A frog jumps in", "7d", "", "", "", "", http.StatusOK, "", []byte("This field cannot be blank")},
+		{"Invalid visibility", "An old pond", "A frog jumps in", "7d", "", "secret", "", "", http.StatusOK, "", []byte("This field is invalid")},
+		{"Too many tags", "An old pond", "A frog jumps in", "7d", "", "public", "", "a b c d e f", http.StatusOK, "", []byte("Too many tags (maximum is 5)")},
+		{"Tag too long", "An old pond", "A frog jumps in", "7d", "", "public", "", strings.Repeat("a", 33), http.StatusOK, "", []byte("is too long (maximum is 32 characters)")},
+		{"Invalid tag", "An old pond", "A frog jumps in", "7d", "", "public", "", "haiku <b>", http.StatusOK, "", []byte("contains invalid characters")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("title", tt.title)
+			form.Add("content", tt.content)
+			form.Add("expires", tt.expires)
+			form.Add("expires_at", tt.expiresAt)
+			form.Add("visibility", tt.visibility)
+			form.Add("language", tt.language)
+			form.Add("tags", tt.tags)
+			form.Add("csrf_token", csrfToken)
+
+			code, headers, body := ts.postForm(t, "/snippet/create", form)
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+			if headers.Get("Location") != tt.wantLocation {
+				t.Errorf("want %q; got %q", tt.wantLocation, headers.Get("Location"))
+			}
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body %s to contain %q", body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestListTag(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody []byte
+	}{
+		{"Tag with snippets", "/tag/haiku", http.StatusOK, []byte("An old silent pond")},
+		{"Upper case tag", "/tag/HAIKU", http.StatusOK, []byte("An old silent pond")},
+		{"Tag without snippets", "/tag/sql", http.StatusOK, []byte("There are no snippets with this tag")},
+		{"Invalid tag", "/tag/-foo", http.StatusNotFound, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestSnippetJourney(t *testing.T) {
+	app := newMemoryTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	// Sign up as a new user
+	_, _, body := ts.get(t, "/user/signup")
+	form := url.Values{}
+	form.Add("name", "Bob")
+	form.Add("email", "bob@example.com")
+	form.Add("password", "validPa$$word")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+	code, _, _ := ts.postForm(t, "/user/signup", form)
+	if code != http.StatusSeeOther {
+		t.Fatalf("signup: want %d; got %d", http.StatusSeeOther, code)
+	}
+
+	// Log in with the new account
+	_, _, body = ts.get(t, "/user/login")
+	form = url.Values{}
+	form.Add("email", "bob@example.com")
+	form.Add("password", "validPa$$word")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+	code, headers, _ := ts.postForm(t, "/user/login", form)
+	if code != http.StatusSeeOther || headers.Get("Location") != "/snippet/create" {
+		t.Fatalf("login: want %d to /snippet/create; got %d to %q", http.StatusSeeOther, code, headers.Get("Location"))
+	}
+
+	// Create a snippet
+	_, _, body = ts.get(t, "/snippet/create")
+	form = url.Values{}
+	form.Add("title", "An old pond")
+	form.Add("content", "A frog jumps in")
+	form.Add("expires", "7d")
+	form.Add("visibility", "public")
+	form.Add("tags", "haiku")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+	code, headers, _ = ts.postForm(t, "/snippet/create", form)
+	if code != http.StatusSeeOther {
+		t.Fatalf("create: want %d; got %d", http.StatusSeeOther, code)
+	}
+
+	// View it, along with the listings it should now appear in
+	for _, urlPath := range []string{headers.Get("Location"), "/", "/tag/haiku", "/user/profile"} {
+		code, _, body = ts.get(t, urlPath)
+		if code != http.StatusOK {
+			t.Errorf("%s: want %d; got %d", urlPath, http.StatusOK, code)
+		}
+		if !bytes.Contains(body, []byte("An old pond")) {
+			t.Errorf("%s: want body to contain %q", urlPath, "An old pond")
+		}
+	}
+}
+
+func TestUnlistedSnippetJourney(t *testing.T) {
+	app := newMemoryTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ctx := context.Background()
+	if err := app.users.Insert(ctx, "Alice", "alice@example.com", "pa$$word"); err != nil {
+		t.Fatal(err)
+	}
+	slug, err := app.snippets.Insert(ctx, 1, "An old pond", oneFile("A frog jumps in", ""), nextWeek, "unlisted", false, "", []string{"haiku"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Anyone with the link can view an unlisted snippet...
+	code, _, body := ts.get(t, "/snippet/"+slug)
+	if code != http.StatusOK || !bytes.Contains(body, []byte("An old pond")) {
+		t.Errorf("want %d with the snippet; got %d", http.StatusOK, code)
+	}
+
+	// ...but it isn't listed, nor found by its integer ID
+	for _, urlPath := range []string{"/", "/tag/haiku", "/snippet/search?q=frog"} {
+		_, _, body = ts.get(t, urlPath)
+		if bytes.Contains(body, []byte("An old pond")) {
+			t.Errorf("%s: want body not to contain %q", urlPath, "An old pond")
+		}
+	}
+	if code, _, _ = ts.get(t, "/snippet/1"); code != http.StatusNotFound {
+		t.Errorf("want %d; got %d", http.StatusNotFound, code)
+	}
+}
+
+func TestBurnSnippetJourney(t *testing.T) {
+	app := newMemoryTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	if err := app.users.Insert(context.Background(), "Alice", "alice@example.com", "validPa$$word"); err != nil {
+		t.Fatal(err)
+	}
+	_, _, body := ts.get(t, "/user/login")
+	form := url.Values{}
+	form.Add("email", "alice@example.com")
+	form.Add("password", "validPa$$word")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+	ts.postForm(t, "/user/login", form)
+
+	_, _, body = ts.get(t, "/snippet/create")
+	form = url.Values{}
+	form.Add("title", "Database password")
+	form.Add("content", "hunter2")
+	form.Add("expires", "1d")
+	form.Add("visibility", "public")
+	form.Add("burn", "true")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+	code, headers, _ := ts.postForm(t, "/snippet/create", form)
+	if code != http.StatusSeeOther {
+		t.Fatalf("create: want %d; got %d", http.StatusSeeOther, code)
+	}
+	snippetPath := headers.Get("Location")
+
+	// It isn't listed, even though it is public
+	for _, urlPath := range []string{"/", "/snippet/search?q=hunter2"} {
+		_, _, body = ts.get(t, urlPath)
+		if bytes.Contains(body, []byte("Database password")) {
+			t.Errorf("%s: want body not to contain %q", urlPath, "Database password")
+		}
+	}
+
+	// The first reader confirms and sees it...
+	_, _, body = ts.get(t, snippetPath)
+	form = url.Values{}
+	form.Add("csrf_token", extractCSRFToken(t, body))
+	code, _, body = ts.postForm(t, snippetPath+"/burn", form)
+	if code != http.StatusOK || !bytes.Contains(body, []byte("hunter2")) {
+		t.Fatalf("burn: want %d with the content; got %d", http.StatusOK, code)
+	}
+
+	// ...and nobody else can
+	code, _, body = ts.get(t, snippetPath)
+	if code != http.StatusGone || bytes.Contains(body, []byte("hunter2")) {
+		t.Errorf("want %d without the content; got %d", http.StatusGone, code)
+	}
+	code, _, _ = ts.postForm(t, snippetPath+"/burn", form)
+	if code != http.StatusGone {
+		t.Errorf("want %d; got %d", http.StatusGone, code)
+	}
+}
+
+func TestProtectedSnippetJourney(t *testing.T) {
+	app := newMemoryTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ctx := context.Background()
+	if err := app.users.Insert(ctx, "Alice", "alice@example.com", "validPa$$word"); err != nil {
+		t.Fatal(err)
+	}
+	first, err := app.snippets.Insert(ctx, 1, "First", oneFile("The first secret", ""), nextWeek, "public", false, "open sesame", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := app.snippets.Insert(ctx, 1, "Second", oneFile("The second secret", ""), nextWeek, "public", false, "open sesame", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Protected snippets aren't listed, even though they are public
+	_, _, body := ts.get(t, "/snippet/search?q=secret")
+	if bytes.Contains(body, []byte("First")) || bytes.Contains(body, []byte("Second")) {
+		t.Errorf("want protected snippets left out of the search")
+	}
+
+	_, _, body = ts.get(t, "/snippet/"+first)
+	form := url.Values{}
+	form.Add("password", "open sesame")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+	code, _, _ := ts.postForm(t, "/snippet/"+first+"/unlock", form)
+	if code != http.StatusSeeOther {
+		t.Fatalf("unlock: want %d; got %d", http.StatusSeeOther, code)
+	}
+
+	// Unlocking one snippet leaves the others locked
+	_, _, body = ts.get(t, "/snippet/"+first)
+	if !bytes.Contains(body, []byte("The first secret")) {
+		t.Errorf("want body to contain %q", "The first secret")
+	}
+	_, _, body = ts.get(t, "/snippet/"+second)
+	if bytes.Contains(body, []byte("The second secret")) {
+		t.Errorf("want body not to contain %q", "The second secret")
+	}
+}
+
+func TestRenewSnippetJourney(t *testing.T) {
+	app := newMemoryTestApplication(t)
+	app.maxExpiry = 30 * 24 * time.Hour
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ctx := context.Background()
+	if err := app.users.Insert(ctx, "Alice", "alice@example.com", "validPa$$word"); err != nil {
+		t.Fatal(err)
+	}
+	slug, err := app.snippets.Insert(ctx, 1, "An old pond", oneFile("A frog jumps in", ""), yesterday, "public", false, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, body := ts.get(t, "/user/login")
+	form := url.Values{}
+	form.Add("email", "alice@example.com")
+	form.Add("password", "validPa$$word")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+	ts.postForm(t, "/user/login", form)
+
+	// The expired snippet is gone, but its owner can renew it from their
+	// profile, within the maximum expiry
+	if code, _, _ := ts.get(t, "/snippet/"+slug); code != http.StatusGone {
+		t.Fatalf("want %d; got %d", http.StatusGone, code)
+	}
+	_, _, body = ts.get(t, "/user/profile")
+	if bytes.Contains(body, []byte("value='never'")) || bytes.Contains(body, []byte("value='365d'")) {
+		t.Errorf("want choices beyond the maximum left out")
+	}
+	csrfToken := extractCSRFToken(t, body)
+
+	// An invalid expiry is shown on the renew form, as the page of the
+	// expired snippet can't be
+	form = url.Values{}
+	form.Add("expires", "never")
+	form.Add("csrf_token", csrfToken)
+	code, _, body := ts.postForm(t, "/snippet/"+slug+"/renew", form)
+	if code != http.StatusOK || !bytes.Contains(body, []byte("This field is invalid")) {
+		t.Errorf("want %d with an error; got %d", http.StatusOK, code)
+	}
+	form.Set("expires", "custom")
+	form.Set("expires_at", time.Now().UTC().Add(-time.Hour).Format(customExpiryFormat))
+	code, _, body = ts.postForm(t, "/snippet/"+slug+"/renew", form)
+	if code != http.StatusOK || !bytes.Contains(body, []byte("This time has already passed")) {
+		t.Errorf("want %d with an error; got %d", http.StatusOK, code)
+	}
+	form.Del("expires_at")
+
+	form.Set("expires", "1h")
+	if code, _, _ := ts.postForm(t, "/snippet/"+slug+"/renew", form); code != http.StatusSeeOther {
+		t.Fatalf("renew: want %d; got %d", http.StatusSeeOther, code)
+	}
+	code, _, body = ts.get(t, "/snippet/"+slug)
+	if code != http.StatusOK || !bytes.Contains(body, []byte("Snippet expiry updated!")) {
+		t.Errorf("want %d with the flash; got %d", http.StatusOK, code)
+	}
+
+	// Custom times can't be later than the maximum either
+	_, _, body = ts.get(t, "/snippet/create")
+	form = url.Values{}
+	form.Add("title", "Over the wintry forest")
+	form.Add("content", "Winds howl in rage")
+	form.Add("expires", "custom")
+	form.Add("expires_at", time.Now().UTC().AddDate(0, 2, 0).Format(customExpiryFormat))
+	form.Add("visibility", "public")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+	code, _, body = ts.postForm(t, "/snippet/create", form)
+	if code != http.StatusOK || !bytes.Contains(body, []byte("This time is too far away")) {
+		t.Errorf("want %d with an error; got %d", http.StatusOK, code)
+	}
+}
+
+func TestHighlightedSnippetJourney(t *testing.T) {
+	app := newMemoryTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	if err := app.users.Insert(context.Background(), "Alice", "alice@example.com", "validPa$$word"); err != nil {
+		t.Fatal(err)
+	}
+	_, _, body := ts.get(t, "/user/login")
+	form := url.Values{}
+	form.Add("email", "alice@example.com")
+	form.Add("password", "validPa$$word")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+	ts.postForm(t, "/user/login", form)
+
+	// The language is detected when it isn't picked
+	_, _, body = ts.get(t, "/snippet/create")
+	form = url.Values{}
+	form.Add("title", "Hello")
+	form.Add("content", "package main\n\nimport \"fmt\"\n\nfunc main() {\n\tfmt.Println(\"<pond>\")\n}\n")
+	form.Add("expires", "1d")
+	form.Add("visibility", "public")
+	form.Add("language", "")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+	code, headers, _ := ts.postForm(t, "/snippet/create", form)
+	if code != http.StatusSeeOther {
+		t.Fatalf("create: want %d; got %d", http.StatusSeeOther, code)
+	}
+
+	_, _, body = ts.get(t, headers.Get("Location"))
+	for _, want := range []string{"<span class='language'>Go</span>", `<span class="kd">func</span>`, `id="L7"`, "&lt;pond&gt;"} {
+		if !bytes.Contains(body, []byte(want)) {
+			t.Errorf("want body to contain %q", want)
+		}
+	}
+}
+
+func TestMultiFileSnippetJourney(t *testing.T) {
+	app := newMemoryTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	if err := app.users.Insert(context.Background(), "Alice", "alice@example.com", "validPa$$word"); err != nil {
+		t.Fatal(err)
+	}
+	_, _, body := ts.get(t, "/user/login")
+	form := url.Values{}
+	form.Add("email", "alice@example.com")
+	form.Add("password", "validPa$$word")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+	ts.postForm(t, "/user/login", form)
+
+	// Each file repeats the name, content and language fields
+	_, _, body = ts.get(t, "/snippet/create")
+	form = url.Values{}
+	form.Add("title", "Hello service")
+	for _, f := range [][3]string{
+		{"Dockerfile", "FROM golang:1.16\n", ""},
+		{"", "package main\n", "go"},
+		{"notes.md", "# An old pond\n", ""},
+	} {
+		form.Add("filename", f[0])
+		form.Add("content", f[1])
+		form.Add("language", f[2])
+	}
+	form.Add("expires", "1d")
+	form.Add("visibility", "public")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+	code, headers, _ := ts.postForm(t, "/snippet/create", form)
+	if code != http.StatusSeeOther {
+		t.Fatalf("create: want %d; got %d", http.StatusSeeOther, code)
+	}
+	location := headers.Get("Location")
+
+	// Every file is shown with its own line numbers and raw link
+	_, _, body = ts.get(t, location)
+	for _, want := range []string{"<strong>Dockerfile</strong>", "<strong>file2.go</strong>", location + "/raw/notes.md", `id="L1"`, `id="F2L1"`, "<h1>An old pond</h1>"} {
+		if !bytes.Contains(body, []byte(want)) {
+			t.Errorf("want body to contain %q", want)
+		}
+	}
+
+	code, _, body = ts.get(t, location+"/raw/file2.go")
+	if code != http.StatusOK || string(body) != "package main\n" {
+		t.Errorf("raw: want %d with the second file; got %d with %q", http.StatusOK, code, body)
+	}
+
+	// An invalid file keeps the files entered on the redisplayed form
+	_, _, body = ts.get(t, "/snippet/create")
+	form.Set("csrf_token", extractCSRFToken(t, body))
+	form["filename"][2] = "Dockerfile"
+	code, _, body = ts.postForm(t, "/snippet/create", form)
+	if code != http.StatusOK {
+		t.Fatalf("invalid create: want %d; got %d", http.StatusOK, code)
+	}
+	for _, want := range []string{"This name is used by another file", "# An old pond", "package main"} {
+		if !bytes.Contains(body, []byte(want)) {
+			t.Errorf("want body to contain %q", want)
+		}
+	}
+
+	// The edit form holds every file, any of which can be changed or removed
+	_, _, body = ts.get(t, location+"/edit")
+	for _, want := range []string{"value='Dockerfile'", "value='file2.go'", "value='notes.md'", "# An old pond"} {
+		if !bytes.Contains(body, []byte(want)) {
+			t.Errorf("edit form: want body to contain %q", want)
+		}
+	}
+	form = url.Values{}
+	form.Add("title", "Hello service")
+	form.Add("filename", "Dockerfile")
+	form.Add("content", "FROM golang:1.17\n")
+	form.Add("language", "")
+	form.Add("filename", "server.go")
+	form.Add("content", "package server\n")
+	form.Add("language", "go")
+	form.Add("visibility", "public")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+	if code, _, _ = ts.postForm(t, location+"/edit", form); code != http.StatusSeeOther {
+		t.Fatalf("edit: want %d; got %d", http.StatusSeeOther, code)
+	}
+	_, _, body = ts.get(t, location)
+	if !bytes.Contains(body, []byte("<strong>server.go</strong>")) || bytes.Contains(body, []byte("notes.md")) {
+		t.Errorf("show: want the edited files; got %s", body)
+	}
+
+	// The history keeps every file of the earlier version
+	_, _, body = ts.get(t, location+"/history/1")
+	for _, want := range []string{"<strong>file2.go</strong>", "<h1>An old pond</h1>"} {
+		if !bytes.Contains(body, []byte(want)) {
+			t.Errorf("revision: want body to contain %q", want)
+		}
+	}
+}
+
+func TestMarkdownSnippet(t *testing.T) {
+	app := newMemoryTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ctx := context.Background()
+	if err := app.users.Insert(ctx, "Alice", "alice@example.com", "pa$$word"); err != nil {
+		t.Fatal(err)
+	}
+	content := "# An old pond\n\n<script>alert(1)</script>\n"
+	notes, err := app.snippets.Insert(ctx, 1, "Notes", oneFile(content, "markdown"), nextWeek, "public", false, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, err := app.snippets.Insert(ctx, 1, "Code", oneFile(content, "plaintext"), nextWeek, "public", false, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		slug     string
+		want     []string
+		dontWant []string
+	}{
+		{"Markdown", notes, []string{"<h1>An old pond</h1>", "View source", `id="L3"`}, []string{"<script>alert"}},
+		{"Other content", code, []string{"# An old pond", "&lt;script&gt;"}, []string{"<h1>An old pond", "View source", "<script>alert"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, body := ts.get(t, "/snippet/"+tt.slug)
+			for _, want := range tt.want {
+				if !bytes.Contains(body, []byte(want)) {
+					t.Errorf("want body to contain %q", want)
+				}
+			}
+			for _, dontWant := range tt.dontWant {
+				if bytes.Contains(body, []byte(dontWant)) {
+					t.Errorf("want body not to contain %q", dontWant)
+				}
+			}
+		})
+	}
+}
+
+func TestShowCollection(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody []byte
+	}{
+		{"Public", "/collection/1", http.StatusOK, []byte("<h2>Haiku</h2>")},
+		{"Snippet", "/collection/1", http.StatusOK, []byte("<a href='/snippet/pond7kq2mzx4wbna'>An old silent pond</a>")},
+		{"Private of another user", "/collection/2", http.StatusNotFound, nil},
+		{"Non-existent", "/collection/99", http.StatusNotFound, nil},
+		{"Negative ID", "/collection/-1", http.StatusNotFound, nil},
+		{"String ID", "/collection/foo", http.StatusNotFound, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+
+	// Private snippets in the collection are only listed for their owner,
+	// who can also edit the collection
+	_, _, body := ts.get(t, "/collection/1")
+	for _, unwanted := range []string{"A frog jumps in", "Edit Collection"} {
+		if bytes.Contains(body, []byte(unwanted)) {
+			t.Errorf("anonymous: want body not to contain %q", unwanted)
+		}
+	}
+	ts.login(t)
+	_, _, body = ts.get(t, "/collection/1")
+	for _, want := range []string{"A frog jumps in", "Edit Collection"} {
+		if !bytes.Contains(body, []byte(want)) {
+			t.Errorf("owner: want body to contain %q", want)
+		}
+	}
+}
+
+func TestUserCollections(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, headers, _ := ts.get(t, "/user/collections")
+	if code != http.StatusSeeOther || headers.Get("Location") != "/user/login" {
+		t.Errorf("anonymous: want %d to /user/login; got %d to %q", http.StatusSeeOther, code, headers.Get("Location"))
+	}
+
+	ts.login(t)
+	code, _, body := ts.get(t, "/user/collections")
+	if code != http.StatusOK {
+		t.Errorf("want %d; got %d", http.StatusOK, code)
+	}
+	for _, want := range []string{"<a href='/collection/1'>Haiku</a>", "value='private' checked"} {
+		if !bytes.Contains(body, []byte(want)) {
+			t.Errorf("want body to contain %q", want)
+		}
+	}
+}
+
+func TestCreateCollection(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+	_, _, body := ts.get(t, "/user/collections")
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name         string
+		title        string
+		description  string
+		visibility   string
+		wantCode     int
+		wantLocation string
+		wantBody     []byte
+	}{
+		{"Valid", "k8s recipes", "Things that worked", "public", http.StatusSeeOther, "/collection/3", nil},
+		{"No description", "Onboarding", "", "private", http.StatusSeeOther, "/collection/3", nil},
+		{"Empty title", "", "", "private", http.StatusOK, "", []byte("This field cannot be blank")},
+		{"Long title", strings.Repeat("a", 101), "", "private", http.StatusOK, "", []byte("This field is too long")},
+		{"Long description", "Onboarding", strings.Repeat("a", 1001), "private", http.StatusOK, "", []byte("This field is too long")},
+		{"Unlisted", "Onboarding", "", "unlisted", http.StatusOK, "", []byte("This field is invalid")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("title", tt.title)
+			form.Add("description", tt.description)
+			form.Add("visibility", tt.visibility)
+			form.Add("csrf_token", csrfToken)
+
+			code, headers, body := ts.postForm(t, "/collection/create", form)
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+			if headers.Get("Location") != tt.wantLocation {
+				t.Errorf("want %q; got %q", tt.wantLocation, headers.Get("Location"))
+			}
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestEditCollection(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+	_, _, body := ts.get(t, "/collection/1")
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name         string
+		urlPath      string
+		title        string
+		wantCode     int
+		wantLocation string
+	}{
+		{"Valid", "/collection/1/edit", "Haiku and tanka", http.StatusSeeOther, "/collection/1"},
+		{"Empty title", "/collection/1/edit", "", http.StatusOK, ""},
+		{"Private of another user", "/collection/2/edit", "Mine now", http.StatusNotFound, ""},
+		{"Non-existent", "/collection/99/edit", "Haiku", http.StatusNotFound, ""},
+		{"Delete", "/collection/1/delete", "", http.StatusSeeOther, "/user/collections"},
+		{"Delete another user's", "/collection/2/delete", "", http.StatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("title", tt.title)
+			form.Add("visibility", "public")
+			form.Add("csrf_token", csrfToken)
+
+			code, headers, _ := ts.postForm(t, tt.urlPath, form)
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+			if headers.Get("Location") != tt.wantLocation {
+				t.Errorf("want %q; got %q", tt.wantLocation, headers.Get("Location"))
+			}
+		})
+	}
+}
+
+func TestCollectSnippet(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+	_, _, body := ts.get(t, "/snippet/pond7kq2mzx4wbna")
+	for _, want := range []string{"In <a href='/collection/1'>Haiku</a>", "/snippet/pond7kq2mzx4wbna/collections/1/delete"} {
+		if !bytes.Contains(body, []byte(want)) {
+			t.Errorf("want body to contain %q", want)
+		}
+	}
+	_, _, body = ts.get(t, "/snippet/many3fq7kd2hwpxa")
+	if !bytes.Contains(body, []byte("<option value='1'>Haiku</option>")) {
+		t.Error("want the collection offered for a snippet which isn't in it")
+	}
+	csrfToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name         string
+		urlPath      string
+		collection   string
+		wantCode     int
+		wantLocation string
+	}{
+		{"Add", "/snippet/many3fq7kd2hwpxa/collections", "1", http.StatusSeeOther, "/snippet/many3fq7kd2hwpxa"},
+		{"Already in it", "/snippet/pond7kq2mzx4wbna/collections", "1", http.StatusSeeOther, "/snippet/pond7kq2mzx4wbna"},
+		{"Another user's", "/snippet/many3fq7kd2hwpxa/collections", "2", http.StatusNotFound, ""},
+		{"Non-existent collection", "/snippet/many3fq7kd2hwpxa/collections", "99", http.StatusNotFound, ""},
+		{"Invalid collection", "/snippet/many3fq7kd2hwpxa/collections", "foo", http.StatusBadRequest, ""},
+		{"Burn after reading", "/snippet/burn5tk3qv7dmxza/collections", "1", http.StatusNotFound, ""},
+		{"Deleted", "/snippet/gone4ma7pqz2xkrb/collections", "1", http.StatusGone, ""},
+		{"Remove", "/snippet/pond7kq2mzx4wbna/collections/1/delete", "", http.StatusSeeOther, "/snippet/pond7kq2mzx4wbna"},
+		{"Remove what isn't in it", "/snippet/many3fq7kd2hwpxa/collections/1/delete", "", http.StatusNotFound, ""},
+		{"Remove from another user's", "/snippet/pond7kq2mzx4wbna/collections/2/delete", "", http.StatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("collection", tt.collection)
+			form.Add("csrf_token", csrfToken)
+
+			code, headers, _ := ts.postForm(t, tt.urlPath, form)
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+			if headers.Get("Location") != tt.wantLocation {
+				t.Errorf("want %q; got %q", tt.wantLocation, headers.Get("Location"))
+			}
+		})
+	}
+}
+
+func TestCollectionJourney(t *testing.T) {
+	app := newMemoryTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ctx := context.Background()
+	if err := app.users.Insert(ctx, "Alice", "alice@example.com", "validPa$$word"); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.users.Insert(ctx, "Bob", "bob@example.com", "validPa$$word"); err != nil {
+		t.Fatal(err)
+	}
+	var slugs []string
+	for _, title := range []string{"Deployments", "Services", "Ingress"} {
+		slug, err := app.snippets.Insert(ctx, 1, title, oneFile("kind: "+title, ""), nextWeek, "public", false, "", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		slugs = append(slugs, slug)
+	}
+	login := func(email string) string {
+		_, _, body := ts.get(t, "/user/login")
+		form := url.Values{}
+		form.Add("email", email)
+		form.Add("password", "validPa$$word")
+		form.Add("csrf_token", extractCSRFToken(t, body))
+		ts.postForm(t, "/user/login", form)
+		_, _, body = ts.get(t, "/user/collections")
+		return extractCSRFToken(t, body)
+	}
+
+	// Alice creates a public collection
+	csrfToken := login("alice@example.com")
+	form := url.Values{}
+	form.Add("title", "k8s recipes")
+	form.Add("description", "Manifests that worked")
+	form.Add("visibility", "public")
+	form.Add("csrf_token", csrfToken)
+	code, headers, _ := ts.postForm(t, "/collection/create", form)
+	if code != http.StatusSeeOther {
+		t.Fatalf("create: want %d; got %d", http.StatusSeeOther, code)
+	}
+	collection := headers.Get("Location")
+
+	// and adds the snippets to it out of order
+	id := strings.TrimPrefix(collection, "/collection/")
+	for _, i := range []int{2, 0, 1} {
+		form = url.Values{}
+		form.Add("collection", id)
+		form.Add("csrf_token", csrfToken)
+		if code, _, _ := ts.postForm(t, "/snippet/"+slugs[i]+"/collections", form); code != http.StatusSeeOther {
+			t.Fatalf("add: want %d; got %d", http.StatusSeeOther, code)
+		}
+	}
+	_, _, body := ts.get(t, "/snippet/"+slugs[0])
+	if !bytes.Contains(body, []byte("In <a href='"+collection+"'>k8s recipes</a>")) {
+		t.Error("show: want the snippet shown as in the collection")
+	}
+
+	// Bob sees the snippets in the order they were added
+	ts.postForm(t, "/user/logout", url.Values{"csrf_token": {csrfToken}})
+	csrfToken = login("bob@example.com")
+	_, _, body = ts.get(t, collection)
+	ingress, deployments, services := bytes.Index(body, []byte(">Ingress<")), bytes.Index(body, []byte(">Deployments<")), bytes.Index(body, []byte(">Services<"))
+	if ingress < 0 || !(ingress < deployments && deployments < services) {
+		t.Errorf("collection: want Ingress, Deployments and Services in order; got offsets %d, %d and %d", ingress, deployments, services)
+	}
+	form = url.Values{}
+	form.Add("title", "Mine now")
+	form.Add("visibility", "public")
+	form.Add("csrf_token", csrfToken)
+	if code, _, _ := ts.postForm(t, collection+"/edit", form); code != http.StatusForbidden {
+		t.Errorf("edit by Bob: want %d; got %d", http.StatusForbidden, code)
+	}
+
+	// Alice removes a snippet and makes the collection private, hiding it
+	// from Bob
+	ts.postForm(t, "/user/logout", url.Values{"csrf_token": {csrfToken}})
+	csrfToken = login("alice@example.com")
+	form = url.Values{}
+	form.Add("csrf_token", csrfToken)
+	if code, _, _ := ts.postForm(t, "/snippet/"+slugs[1]+"/collections/"+id+"/delete", form); code != http.StatusSeeOther {
+		t.Fatalf("remove: want %d; got %d", http.StatusSeeOther, code)
+	}
+	form = url.Values{}
+	form.Add("title", "k8s recipes")
+	form.Add("visibility", "private")
+	form.Add("csrf_token", csrfToken)
+	if code, _, _ := ts.postForm(t, collection+"/edit", form); code != http.StatusSeeOther {
+		t.Fatalf("edit: want %d; got %d", http.StatusSeeOther, code)
+	}
+	_, _, body = ts.get(t, collection)
+	if bytes.Contains(body, []byte(">Services<")) || !bytes.Contains(body, []byte("by Alice, private")) {
+		t.Error("collection: want Services removed and the collection private")
+	}
+	ts.postForm(t, "/user/logout", url.Values{"csrf_token": {csrfToken}})
+	login("bob@example.com")
+	if code, _, _ := ts.get(t, collection); code != http.StatusNotFound {
+		t.Errorf("private collection for Bob: want %d; got %d", http.StatusNotFound, code)
+	}
+}
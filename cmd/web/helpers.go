@@ -2,16 +2,31 @@ package main
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"runtime/debug"
+	"strconv"
+	"strings"
 	"time"
 
+	"yudhiesh/snippetbox/pkg/forms"
+	"yudhiesh/snippetbox/pkg/models"
+
 	"github.com/justinas/nosurf"
 )
 
 // Writes an error message and stack trace to the errorLog
 func (app *Application) serverError(w http.ResponseWriter, err error) {
+	// A query abandoned because the client went away or the query deadline
+	// passed isn't a bug, so there's no stack trace. The client gets a 503 in
+	// case it is still listening.
+	if errors.Is(err, models.ErrCanceled) {
+		app.errorLog.Output(2, err.Error())
+		app.clientError(w, http.StatusServiceUnavailable)
+		return
+	}
 	// debug.Stack() is used to get a stack trace for the current goroutine and
 	// append it to log message
 	trace := fmt.Sprintf("%s\n%s", err.Error(), debug.Stack())
@@ -39,6 +54,117 @@ func (app *Application) notFound(w http.ResponseWriter) {
 	app.clientError(w, http.StatusNotFound)
 }
 
+// Sends the response for an error returned while fetching a snippet. Snippets
+// that were deleted or have expired get a 410 Gone tombstone page, unknown
+// snippets a 404 and anything else is treated as a server error.
+func (app *Application) snippetError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, models.ErrNoRecord):
+		app.notFound(w)
+	case errors.Is(err, models.ErrDeleted):
+		app.gone(w, r, "This snippet has been deleted by its owner.")
+	case errors.Is(err, models.ErrExpired):
+		app.gone(w, r, "This snippet has expired.")
+	case errors.Is(err, models.ErrBurned):
+		app.gone(w, r, "This snippet has been burned. It could only be read once.")
+	default:
+		app.serverError(w, err)
+	}
+}
+
+// Fetches the snippet named by the :slug URL parameter and checks that the
+// current user may view it, sending the error response and returning false
+// if not. A snippet which can no longer be viewed is only reported as gone to
+// users who could have viewed it, and as not found to everybody else.
+// Snippets used to be addressed by their integer ID, so a GET of a public
+// snippet by its ID is redirected to the same page under its slug. Other
+// snippets aren't, as that would let their slugs be found by counting.
+func (app *Application) snippetFromURL(w http.ResponseWriter, r *http.Request) (*models.Snippet, bool) {
+	slug := r.URL.Query().Get(":slug")
+	s, err := app.snippets.GetBySlug(r.Context(), slug)
+	if errors.Is(err, models.ErrNoRecord) {
+		if id, convErr := strconv.Atoi(slug); convErr == nil && id > 0 && r.Method == http.MethodGet {
+			s, err = app.snippets.Get(r.Context(), id)
+			switch {
+			case s != nil && s.Visibility != models.VisibilityPublic:
+				s, err = nil, models.ErrNoRecord
+			case err == nil:
+				path := strings.Replace(r.URL.Path, "/snippet/"+slug, "/snippet/"+s.Slug, 1)
+				http.Redirect(w, r, path, http.StatusMovedPermanently)
+				return nil, false
+			}
+		}
+	}
+	// The snippet is also returned for the errors of snippets which can no
+	// longer be viewed, so this is checked first
+	if s != nil && !s.VisibleTo(app.session.GetInt(r, "authenticatedUserID")) {
+		app.notFound(w)
+		return nil, false
+	}
+	if err != nil {
+		app.snippetError(w, r, err)
+		return nil, false
+	}
+	return s, true
+}
+
+// The most snippets remembered as unlocked by a session, which keeps the
+// session cookie small
+const maxUnlockedSnippets = 50
+
+// Renders the unlock form in place of a protected snippet, unless the current
+// user owns the snippet or has unlocked it earlier in the session. Returns true
+// if the snippet may be shown.
+func (app *Application) unlocked(w http.ResponseWriter, r *http.Request, s *models.Snippet) bool {
+	if app.isUnlocked(r, s) {
+		return true
+	}
+	app.render(w, r, "unlock.page.tmpl", &templateData{Form: forms.New(nil), Snippet: s})
+	return false
+}
+
+// Reports whether the current user may read a snippet without giving its
+// password, because it has none, they own it or they unlocked it earlier in
+// the session
+func (app *Application) isUnlocked(r *http.Request, s *models.Snippet) bool {
+	if !s.Protected || s.UserID == app.session.GetInt(r, "authenticatedUserID") {
+		return true
+	}
+	ids, _ := app.session.Get(r, "unlockedSnippets").([]int)
+	for _, id := range ids {
+		if id == s.ID {
+			return true
+		}
+	}
+	return false
+}
+
+// Remembers in the session that the snippet with the given ID was unlocked,
+// forgetting the oldest snippet if there are too many
+func (app *Application) rememberUnlocked(r *http.Request, id int) {
+	ids, _ := app.session.Get(r, "unlockedSnippets").([]int)
+	ids = append(ids, id)
+	if len(ids) > maxUnlockedSnippets {
+		ids = ids[len(ids)-maxUnlockedSnippets:]
+	}
+	app.session.Put(r, "unlockedSnippets", ids)
+}
+
+// Returns the IP address of the client, without the port
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// Renders the tombstone page with a 410 Gone status and the reason the
+// snippet is no longer available
+func (app *Application) gone(w http.ResponseWriter, r *http.Request, reason string) {
+	app.renderStatus(w, r, http.StatusGone, "gone.page.tmpl", &templateData{Tombstone: reason})
+}
+
 // Add the year to the templateData
 func (app *Application) addDefaultData(td *templateData, r *http.Request) *templateData {
 	if td == nil {
@@ -46,13 +172,25 @@ func (app *Application) addDefaultData(td *templateData, r *http.Request) *templ
 	}
 	td.CSRFToken = nosurf.Token(r)
 	td.CurrentYear = time.Now().Year()
+	td.ExpiryChoices = app.expiryChoices()
+	td.Languages = languages
 	td.Flash = app.session.PopString(r, "flash")
 	td.IsAuthenticated = app.isAuthenticated(r)
+	if td.IsAuthenticated {
+		td.AuthenticatedUserID = app.session.GetInt(r, "authenticatedUserID")
+	}
 	return td
 }
 
 // Helper function to render the html templates
 func (app *Application) render(w http.ResponseWriter, r *http.Request, name string, td *templateData) {
+	app.renderStatus(w, r, http.StatusOK, name, td)
+}
+
+// Renders a template like render, but with the given status code. The status
+// is only written once the template has executed, so that a template error
+// still sends a 500.
+func (app *Application) renderStatus(w http.ResponseWriter, r *http.Request, status int, name string, td *templateData) {
 	ts, ok := app.templateCache[name]
 	if !ok {
 		app.serverError(w, fmt.Errorf("The template %s does not exist", name))
@@ -74,6 +212,7 @@ func (app *Application) render(w http.ResponseWriter, r *http.Request, name stri
 	// Write the contents of the buffer to the http.ResponseWriter. Again, this
 	// is another time where we pass our http.ResponseWriter to a function that
 	// takes an io.Writer.
+	w.WriteHeader(status)
 	buf.WriteTo(w)
 }
 
@@ -85,3 +224,25 @@ func (app *Application) isAuthenticated(r *http.Request) bool {
 	}
 	return isAuthenticated
 }
+
+// Returns the file name a snippet is downloaded as, made of the words of its
+// title and the file extension of its language, such as an-old-pond.go
+func downloadName(s *models.Snippet) string {
+	return titleSlug(s.Title) + fileExtension(s.Language)
+}
+
+// Returns the lower case words of a title joined by dashes, or "snippet" if it
+// has none
+func titleSlug(title string) string {
+	words := strings.FieldsFunc(strings.ToLower(title), func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9')
+	})
+	name := strings.Join(words, "-")
+	if len(name) > 64 {
+		name = strings.TrimRight(name[:64], "-")
+	}
+	if name == "" {
+		name = "snippet"
+	}
+	return name
+}
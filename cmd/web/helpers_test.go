@@ -0,0 +1,55 @@
+package main
+
+import (
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"yudhiesh/snippetbox/pkg/models"
+)
+
+func TestDownloadName(t *testing.T) {
+	tests := []struct {
+		name     string
+		title    string
+		language string
+		want     string
+	}{
+		{"Words", "An old silent pond", "plaintext", "an-old-silent-pond.txt"},
+		{"Punctuation", "  Hello, World! (v2) ", "go", "hello-world-v2.go"},
+		{"No words", "¡¿?!", "python", "snippet.py"},
+		{"Long title", strings.Repeat("pond ", 20), "", strings.TrimSuffix(strings.Repeat("pond-", 13), "-") + ".txt"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &models.Snippet{Title: tt.title, Language: tt.language}
+			if got := downloadName(s); got != tt.want {
+				t.Errorf("want %q; got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestGone(t *testing.T) {
+	app := newTestApplication(t)
+	handler := app.session.Enable(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		app.gone(w, r, "deleted")
+	}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/snippet/gone4ma7pqz2xkrb", nil))
+	if rr.Code != http.StatusGone {
+		t.Errorf("want %d; got %d", http.StatusGone, rr.Code)
+	}
+
+	// A template which fails to execute still sends a 500
+	app.templateCache["gone.page.tmpl"] = template.Must(template.New("gone.page.tmpl").Parse(`{{template "missing"}}`))
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/snippet/gone4ma7pqz2xkrb", nil))
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("broken template: want %d; got %d", http.StatusInternalServerError, rr.Code)
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"strings"
+
+	"github.com/alecthomas/chroma"
+	"github.com/alecthomas/chroma/formatters/html"
+	"github.com/alecthomas/chroma/lexers"
+	"github.com/alecthomas/chroma/styles"
+)
+
+// A language which can be picked on the create form. The value is the name
+// of the chroma lexer used to highlight it.
+type language struct {
+	Value string
+	Label string
+}
+
+// The languages offered on the create form, after the choice to detect the
+// language from the content. Other languages can still be detected.
+var languages = []language{
+	{"plaintext", "Plain text"},
+	{"bash", "Bash"},
+	{"c", "C"},
+	{"cpp", "C++"},
+	{"csharp", "C#"},
+	{"css", "CSS"},
+	{"diff", "Diff"},
+	{"go", "Go"},
+	{"html", "HTML"},
+	{"java", "Java"},
+	{"javascript", "JavaScript"},
+	{"json", "JSON"},
+	{"markdown", "Markdown"},
+	{"php", "PHP"},
+	{"python", "Python"},
+	{"ruby", "Ruby"},
+	{"rust", "Rust"},
+	{"sql", "SQL"},
+	{"typescript", "TypeScript"},
+	{"yaml", "YAML"},
+}
+
+// Returns the values of the languages offered on the create form
+func languageValues() []string {
+	values := make([]string, len(languages))
+	for i, l := range languages {
+		values[i] = l.Value
+	}
+	return values
+}
+
+// Guesses the language of a file from its name, such as main.go or
+// Dockerfile, and then from its content, falling back to plain text when no
+// lexer recognises either. Few lexers can recognise content, so scripts are
+// also recognised by the interpreter named in their #! line.
+func detectLanguage(name, content string) string {
+	var l chroma.Lexer
+	if name != "" {
+		l = lexers.Match(name)
+	}
+	if l == nil {
+		l = lexers.Analyse(content)
+	}
+	if l == nil {
+		l = interpreterLexer(content)
+	}
+	if l == nil {
+		return "plaintext"
+	}
+	// Languages offered on the create form are named as they are there, so
+	// that Markdown is "markdown" rather than the lexer's first alias "md"
+	for _, offered := range languages {
+		if lexers.Get(offered.Value) == l {
+			return offered.Value
+		}
+	}
+	config := l.Config()
+	if len(config.Aliases) > 0 {
+		return config.Aliases[0]
+	}
+	return strings.ToLower(config.Name)
+}
+
+// Returns the lexer for the interpreter named in the #! line of a script, such
+// as python for #!/usr/bin/env python3, or nil if there is none
+func interpreterLexer(content string) chroma.Lexer {
+	if !strings.HasPrefix(content, "#!") {
+		return nil
+	}
+	line := strings.SplitN(content[2:], "\n", 2)[0]
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return nil
+	}
+	interpreter := fields[0][strings.LastIndex(fields[0], "/")+1:]
+	if interpreter == "env" && len(fields) > 1 {
+		interpreter = fields[1]
+	}
+	// Drop the version, as in python3 or ruby2.7
+	interpreter = strings.TrimRight(interpreter, "0123456789.")
+	return lexers.Get(interpreter)
+}
+
+// Returns the lexer for a language, or nil if there is none. Snippets created
+// before languages were added have no language and are shown as plain text.
+func lexer(language string) chroma.Lexer {
+	if language == "" {
+		return nil
+	}
+	return lexers.Get(language)
+}
+
+// Returns the file extension used for a language, taken from the file name
+// patterns of its lexer, or .txt if it has none
+func fileExtension(language string) string {
+	if l := lexer(language); l != nil {
+		for _, pattern := range l.Config().Filenames {
+			ext := strings.TrimPrefix(pattern, "*")
+			if strings.HasPrefix(ext, ".") && !strings.ContainsAny(ext, "*?[") {
+				return ext
+			}
+		}
+	}
+	return ".txt"
+}
+
+// Returns the display name of a language, or the empty string for plain text
+func languageName(language string) string {
+	l := lexer(language)
+	if l == nil || l == lexers.Fallback {
+		return ""
+	}
+	return l.Config().Name
+}
+
+// The highlighting is rendered with CSS classes so that the colours come
+// from the stylesheet generated from this style, highlight.css
+var highlightStyle = styles.GitHub
+
+// Returns a formatter which numbers every line, starting from base, and gives
+// it an id made of the prefix and the line number, so that the lines can be
+// linked to as #L10, and ranges as #L10-L20
+func highlightFormatter(prefix string, base int) *html.Formatter {
+	return html.New(
+		html.WithClasses(true),
+		html.WithLineNumbers(true),
+		html.BaseLineNumber(base),
+		html.LinkableLineNumbers(true, prefix),
+		html.TabWidth(4),
+	)
+}
+
+// Returns the prefix of the ids of the lines of a snippet's file. The lines
+// of the first file are L1, L2 and so on, and those of the second file F2L1,
+// F2L2, so that links to snippets with a single file stay the same.
+func linePrefix(file int) string {
+	if file == 0 {
+		return "L"
+	}
+	return fmt.Sprintf("F%dL", file+1)
+}
+
+// Returns the content as syntax highlighted HTML with numbered lines whose ids
+// start with the prefix. The content is escaped by the formatter so the result
+// is safe to render.
+func highlight(content, language, prefix string) template.HTML {
+	l := lexer(language)
+	if l == nil {
+		l = lexers.Fallback
+	}
+	var b strings.Builder
+	iterator, err := chroma.Coalesce(l).Tokenise(nil, content)
+	if err == nil {
+		err = highlightFormatter(prefix, 1).Format(&b, highlightStyle, iterator)
+	}
+	if err != nil {
+		// Template functions can't return errors, so the content is shown
+		// without highlighting instead
+		return template.HTML("<pre><code>" + template.HTMLEscapeString(content) + "</code></pre>")
+	}
+	return template.HTML(b.String())
+}
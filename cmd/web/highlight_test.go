@@ -0,0 +1,94 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLanguages(t *testing.T) {
+	for _, l := range languages {
+		if lexer(l.Value) == nil {
+			t.Errorf("want a lexer for %q", l.Value)
+		}
+	}
+}
+
+func TestDetectLanguage(t *testing.T) {
+	tests := []struct {
+		name     string
+		filename string
+		content  string
+		want     string
+	}{
+		{"Shell script", "", "#!/bin/sh\necho 'An old silent pond'\n", "bash"},
+		{"Interpreter", "", "#!/usr/bin/env python3\nprint('An old silent pond')\n", "python"},
+		{"Unknown interpreter", "", "#!/usr/bin/env pond\nAn old silent pond\n", "plaintext"},
+		{"Go", "", "package main\n\nimport \"fmt\"\n\nfunc main() {\n\tfmt.Println(\"An old silent pond\")\n}\n", "go"},
+		{"Prose", "", "An old silent pond...", "plaintext"},
+		{"File name", "Dockerfile", "FROM golang:1.16\n", "docker"},
+		{"File name before content", "pond.md", "#!/bin/sh\necho 'An old silent pond'\n", "markdown"},
+		{"Unknown file name", "pond.haiku", "#!/bin/sh\necho 'An old silent pond'\n", "bash"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := detectLanguage(tt.filename, tt.content); got != tt.want {
+				t.Errorf("want %q; got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		language string
+		prefix   string
+		want     []string
+		dontWant []string
+	}{
+		{"Go", "func main() {\n}\n", "go", "L", []string{`<span class="kd">func</span>`, `id="L1"`, `id="L2"`, `href="#L2"`}, []string{`id="L3"`}},
+		{"Second file", "func main() {\n}\n", "go", "F2L", []string{`id="F2L1"`, `href="#F2L2"`}, []string{`id="L1"`}},
+		{"Plain text", "<b>An old silent pond</b>", "plaintext", "L", []string{"&lt;b&gt;An old silent pond&lt;/b&gt;", `id="L1"`}, []string{"<b>"}},
+		{"No language", "<b>An old silent pond</b>", "", "L", []string{"&lt;b&gt;"}, []string{"<b>"}},
+		{"Unknown language", "<b>An old silent pond</b>", "cobol-ish", "L", []string{"&lt;b&gt;"}, []string{"<b>"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := string(highlight(tt.content, tt.language, tt.prefix))
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("want %q to contain %q", got, want)
+				}
+			}
+			for _, dontWant := range tt.dontWant {
+				if strings.Contains(got, dontWant) {
+					t.Errorf("want %q not to contain %q", got, dontWant)
+				}
+			}
+		})
+	}
+}
+
+func TestFileExtension(t *testing.T) {
+	tests := []struct {
+		language string
+		want     string
+	}{
+		{"go", ".go"},
+		{"python", ".py"},
+		{"markdown", ".md"},
+		{"plaintext", ".txt"},
+		{"", ".txt"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.language, func(t *testing.T) {
+			if got := fileExtension(tt.language); got != tt.want {
+				t.Errorf("want %q; got %q", tt.want, got)
+			}
+		})
+	}
+}
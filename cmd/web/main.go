@@ -1,17 +1,24 @@
 package main
 
 import (
+	"context"
 	"crypto/tls"
 	"database/sql"
+	"errors"
 	"flag"
+	"fmt"
 	"html/template"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"yudhiesh/snippetbox/pkg/models"
+	"yudhiesh/snippetbox/pkg/models/memory"
 	"yudhiesh/snippetbox/pkg/models/mysql"
+	"yudhiesh/snippetbox/pkg/models/sqlite"
 
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/golangcollege/sessions"
@@ -26,15 +33,55 @@ const contextKeyIsAuthenticated = contextKey("isAuthenticated")
 // Make snippets and user take in generic types/interfaces instead of concrete types of
 // *mysql.SnippetMode and *mysql.UserModel
 type snippets interface {
-	Insert(string, string, string) (int, error)
-	Get(int) (*models.Snippet, error)
-	Latest() ([]*models.Snippet, error)
+	Insert(context.Context, int, string, []*models.File, time.Time, string, bool, string, []string) (string, error)
+	Get(context.Context, int) (*models.Snippet, error)
+	GetBySlug(context.Context, string) (*models.Snippet, error)
+	Burn(context.Context, string) (*models.Snippet, error)
+	Unlock(context.Context, int, string) error
+	Latest(context.Context, *models.Cursor, *models.Cursor, int) ([]*models.Snippet, error)
+	Search(context.Context, string, int) ([]*models.Snippet, error)
+	ByTag(context.Context, string, int) ([]*models.Snippet, error)
+	ByUser(context.Context, int) ([]*models.Snippet, error)
+	Fork(context.Context, int, int, time.Time) (string, error)
+	Forks(context.Context, int, int) ([]*models.Snippet, error)
+	Update(context.Context, int, int, string, string, []*models.File) error
+	Renew(context.Context, string, int, time.Time) error
+	Revisions(context.Context, int) ([]*models.Revision, error)
+	Delete(context.Context, string, int) error
+	Restore(context.Context, string, int, time.Duration) error
+	Deleted(context.Context, int, time.Duration) ([]*models.Snippet, error)
+	PurgeExpired(context.Context, int) (int, error)
+}
+type comments interface {
+	Insert(context.Context, int, int, string, int, int, int) (int, error)
+	BySnippet(context.Context, int) ([]*models.Comment, error)
+	Delete(context.Context, int, int) error
+}
+type stars interface {
+	Toggle(context.Context, int, int) (bool, error)
+	IsStarred(context.Context, int, int) (bool, error)
+	Count(context.Context, ...*models.Snippet) error
+	ByUser(context.Context, int) ([]*models.Snippet, error)
+}
+type views interface {
+	Add(context.Context, map[int]int) error
+	Count(context.Context, ...*models.Snippet) error
+}
+type collections interface {
+	Insert(context.Context, int, string, string, string) (int, error)
+	Get(context.Context, int) (*models.Collection, error)
+	ByUser(context.Context, int) ([]*models.Collection, error)
+	Containing(context.Context, int, int) ([]*models.Collection, error)
+	Update(context.Context, int, string, string, string) error
+	Delete(context.Context, int) error
+	AddSnippet(context.Context, int, int) error
+	RemoveSnippet(context.Context, int, int) error
 }
 type users interface {
-	Insert(string, string, string) error
-	Authenticate(string, string) (int, error)
-	Get(int) (*models.User, error)
-	ChangePassword(int, string, string) error
+	Insert(context.Context, string, string, string) error
+	Authenticate(context.Context, string, string) (int, error)
+	Get(context.Context, int) (*models.User, error)
+	ChangePassword(context.Context, int, string, string) error
 }
 
 // Define an Application struct to hold the Application-wide dependencies for
@@ -46,18 +93,40 @@ type Application struct {
 	infoLog       *log.Logger
 	session       *sessions.Session
 	snippets      snippets
+	comments      comments
+	stars         stars
+	views         views
+	collections   collections
 	templateCache map[string]*template.Template
 	users         users
 	debug         bool
+	pageSize      int
+	restoreWindow time.Duration
+	// The longest a snippet can be kept for, or no limit if zero
+	maxExpiry time.Duration
+	// Limits the wrong passwords tried on a protected snippet by each client
+	unlockThrottle *throttle
+	// Counts the views of snippets until they are written to views
+	viewCounter *viewCounter
 }
 
 func main() {
 	// This parses the command-line flag.
 	// This needs to be called before using the flag variables such as addr
 	addr := flag.String("addr", ":4000", "HTTP network address")
-	dsn := flag.String("dsn", "web:password@/snippetbox?parseTime=true", "MySQL data source name")
+	backend := flag.String("db", "mysql", "Storage backend (mysql, sqlite or memory)")
+	dsn := flag.String("dsn", "", "Data source name (defaults to web:password@/snippetbox?parseTime=true for mysql and snippetbox.db for sqlite)")
 	secret := flag.String("secret", "s6Ndh+pPbnzHbS*+9Pk8qGWhTzbpa@ge", "Secret key")
 	debug := flag.Bool("debug", false, "Enable debug mode")
+	pageSize := flag.Int("page-size", 10, "Number of snippets listed per page")
+	restoreWindow := flag.Duration("restore-window", 7*24*time.Hour, "How long deleted snippets can be restored for")
+	maxExpiry := flag.Duration("max-expiry", 0, "Longest time a snippet can be kept for (0 allows snippets which never expire)")
+	purgeInterval := flag.Duration("purge-interval", time.Hour, "How often expired snippets are deleted (0 disables purging)")
+	purgeBatchSize := flag.Int("purge-batch-size", 500, "Maximum number of expired snippets deleted by each statement")
+	queryTimeout := flag.Duration("query-timeout", 5*time.Second, "Deadline for the database queries of each model call (0 disables it)")
+	viewFlushInterval := flag.Duration("view-flush-interval", 30*time.Second, "How often the views of snippets counted in memory are written to the database")
+	autoMigrate := flag.Bool("auto-migrate", false, "Apply pending schema migrations before starting the server")
+	metricsAddr := flag.String("metrics-addr", "", "HTTP network address serving /metrics, which shouldn't be reachable by the public (disabled if empty)")
 
 	flag.Parse()
 
@@ -72,14 +141,57 @@ func main() {
 	// file name and line number.
 	errorLog := log.New(os.Stderr, "ERROR\t", log.Ldate|log.Ltime|log.Lshortfile)
 
+	// Listings page with a keyset cursor which only advances past a snippet
+	if *pageSize < 1 {
+		errorLog.Fatal("-page-size must be at least 1")
+	}
+	// Views are always counted, so they have to be written at some point
+	if *viewFlushInterval <= 0 {
+		errorLog.Fatal("-view-flush-interval must be positive")
+	}
+
+	// `snippetbox [flags] migrate up|down [n]|status|baseline version`
+	// manages the schema instead of starting the server
+	if flag.Arg(0) == "migrate" {
+		migrator, err := newMigrator(*backend, *dsn)
+		if err != nil {
+			errorLog.Fatal(err)
+		}
+		err = runMigrate(migrator, flag.Args()[1:], os.Stdout)
+		migrator.DB.Close()
+		if err != nil {
+			errorLog.Fatal(err)
+		}
+		return
+	}
+
+	// The memory backend starts empty every time so has nothing to migrate
+	if *autoMigrate && *backend != "memory" {
+		migrator, err := newMigrator(*backend, *dsn)
+		if err != nil {
+			errorLog.Fatal(err)
+		}
+		applied, err := migrator.Up()
+		migrator.DB.Close()
+		if err != nil {
+			errorLog.Fatal(err)
+		}
+		for _, migration := range applied {
+			infoLog.Printf("Applied migration %d_%s", migration.Version, migration.Name)
+		}
+	}
+
 	// Connect to the DB
-	db, err := openDB(*dsn)
+	store, err := openModels(*backend, *dsn, *queryTimeout)
 	if err != nil {
 		errorLog.Fatal(err)
 	}
 
-	// Closes the connection pool before the main function finishes
-	defer db.Close()
+	// Closes the connection pool before the main function finishes. The
+	// memory backend has no connection pool.
+	if store.db != nil {
+		defer store.db.Close()
+	}
 
 	templateCache, err := newTemplateCache("./ui/html/")
 	if err != nil {
@@ -93,13 +205,22 @@ func main() {
 	session.Secure = true
 
 	app := &Application{
-		errorLog:      errorLog,
-		infoLog:       infoLog,
-		session:       session,
-		snippets:      &mysql.SnippetModel{DB: db},
-		templateCache: templateCache,
-		users:         &mysql.UserModel{DB: db},
-		debug:         *debug,
+		errorLog:       errorLog,
+		infoLog:        infoLog,
+		session:        session,
+		snippets:       store.snippets,
+		comments:       store.comments,
+		stars:          store.stars,
+		views:          store.views,
+		collections:    store.collections,
+		templateCache:  templateCache,
+		users:          store.users,
+		debug:          *debug,
+		pageSize:       *pageSize,
+		restoreWindow:  *restoreWindow,
+		maxExpiry:      *maxExpiry,
+		unlockThrottle: newThrottle(maxUnlockFailures, unlockFailureWindow),
+		viewCounter:    newViewCounter(store.views, *viewFlushInterval, errorLog),
 	}
 
 	// tls.Config struct holds the non-default TLS setting we want the server to
@@ -130,10 +251,152 @@ func main() {
 		WriteTimeout: 10 * time.Second,
 	}
 
+	// The metrics are served over plain HTTP on their own address, if any,
+	// which is expected to be reachable only from inside the network
+	var metricsSrv *http.Server
+	if *metricsAddr != "" {
+		metricsSrv = &http.Server{
+			Addr:         *metricsAddr,
+			ErrorLog:     errorLog,
+			Handler:      app.metricsRoutes(),
+			IdleTimeout:  time.Minute,
+			ReadTimeout:  5 * time.Second,
+			WriteTimeout: 10 * time.Second,
+		}
+		go func() {
+			infoLog.Printf("Serving metrics on %s", *metricsAddr)
+			err := metricsSrv.ListenAndServe()
+			if !errors.Is(err, http.ErrServerClosed) {
+				errorLog.Fatal(err)
+			}
+		}()
+	}
+
+	// Expired snippets are deleted in the background while the server runs
+	var p *purger
+	if *purgeInterval > 0 {
+		p = &purger{
+			snippets:  store.snippets,
+			interval:  *purgeInterval,
+			batchSize: *purgeBatchSize,
+			errorLog:  errorLog,
+			infoLog:   infoLog,
+		}
+		p.Start()
+	}
+	app.viewCounter.Start()
+
+	// Shut the server down gracefully on SIGINT or SIGTERM, letting requests
+	// in flight finish first. Shutdown makes ListenAndServeTLS return
+	// straight away, so main waits for the result on shutdownError.
+	shutdownError := make(chan error)
+	go func() {
+		quit := make(chan os.Signal, 1)
+		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+		sig := <-quit
+		infoLog.Printf("Shutting down server (%s)", sig)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if metricsSrv != nil {
+			if err := metricsSrv.Shutdown(ctx); err != nil {
+				errorLog.Print(err)
+			}
+		}
+		shutdownError <- srv.Shutdown(ctx)
+	}()
+
 	infoLog.Printf("Starting server on %s", *addr)
 	// ListenAndServeTLS() is used to start the HTTPS server
 	err = srv.ListenAndServeTLS("./tls/cert.pem", "./tls/key.pem")
-	errorLog.Fatal(err)
+	if !errors.Is(err, http.ErrServerClosed) {
+		errorLog.Fatal(err)
+	}
+	if err = <-shutdownError; err != nil {
+		errorLog.Fatal(err)
+	}
+
+	// Stop the purger and write the pending views before the deferred
+	// db.Close runs
+	if p != nil {
+		p.Stop()
+	}
+	app.viewCounter.Stop()
+	infoLog.Print("Server stopped")
+}
+
+// The data source used by each storage backend when -dsn isn't given
+var defaultDSNs = map[string]string{
+	"mysql":  "web:password@/snippetbox?parseTime=true",
+	"sqlite": "snippetbox.db",
+}
+
+// The models of a storage backend, which share its database. The memory
+// backend doesn't use a *sql.DB so db is nil for it.
+type storage struct {
+	db          *sql.DB
+	snippets    snippets
+	comments    comments
+	stars       stars
+	views       views
+	collections collections
+	users       users
+}
+
+// Opens the database of the storage backend selected with the -db flag and
+// returns it along with the models built on top of it, which give each call
+// timeout to run its queries
+func openModels(backend, dsn string, timeout time.Duration) (*storage, error) {
+	if dsn == "" {
+		dsn = defaultDSNs[backend]
+	}
+	switch backend {
+	case "mysql":
+		db, err := openDB(dsn)
+		if err != nil {
+			return nil, err
+		}
+		return &storage{
+			db:          db,
+			snippets:    &mysql.SnippetModel{DB: db, Timeout: timeout},
+			comments:    &mysql.CommentModel{DB: db, Timeout: timeout},
+			stars:       &mysql.StarModel{DB: db, Timeout: timeout},
+			views:       &mysql.ViewModel{DB: db, Timeout: timeout},
+			collections: &mysql.CollectionModel{DB: db, Timeout: timeout},
+			users:       &mysql.UserModel{DB: db, Timeout: timeout},
+		}, nil
+	case "sqlite":
+		db, err := sqlite.Open(dsn)
+		if err != nil {
+			return nil, err
+		}
+		if err := createSQLiteSchema(db); err != nil {
+			db.Close()
+			return nil, err
+		}
+		return &storage{
+			db:          db,
+			snippets:    &sqlite.SnippetModel{DB: db, Timeout: timeout},
+			comments:    &sqlite.CommentModel{DB: db, Timeout: timeout},
+			stars:       &sqlite.StarModel{DB: db, Timeout: timeout},
+			views:       &sqlite.ViewModel{DB: db, Timeout: timeout},
+			collections: &sqlite.CollectionModel{DB: db, Timeout: timeout},
+			users:       &sqlite.UserModel{DB: db, Timeout: timeout},
+		}, nil
+	case "memory":
+		// Everything is lost when the server stops
+		db := memory.New()
+		return &storage{
+			snippets:    &memory.SnippetModel{DB: db},
+			comments:    &memory.CommentModel{DB: db},
+			stars:       &memory.StarModel{DB: db},
+			views:       &memory.ViewModel{DB: db},
+			collections: &memory.CollectionModel{DB: db},
+			users:       &memory.UserModel{DB: db},
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", backend)
+	}
 }
 
 // Returns a sql.DB connection pool for a given DSN
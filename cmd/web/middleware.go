@@ -60,6 +60,17 @@ func neuter(next http.Handler) http.Handler {
 	})
 }
 
+// Limits the body of every request to n bytes, so that nothing reading it,
+// the CSRF check included, reads more
+func limitBody(n int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r.Body = http.MaxBytesReader(w, r.Body, n)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 func (app *Application) logRequest(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		app.infoLog.Printf("%s - %s %s %s", r.RemoteAddr, r.Proto, r.Method, r.URL.RequestURI())
@@ -113,14 +124,15 @@ func (app *Application) authenticate(next http.Handler) http.Handler {
 		// Fetch the details from the current user in the database
 		// If not matching record or the user is not active(deactivated their
 		// account) then remove the authenticatedID value from their session
-		user, err := app.users.Get(app.session.GetInt(r, "authenticatedUserID"))
-		if errors.Is(err, models.ErrNoRecord) || !user.Active {
+		user, err := app.users.Get(r.Context(), app.session.GetInt(r, "authenticatedUserID"))
+		if err != nil && !errors.Is(err, models.ErrNoRecord) {
+			app.serverError(w, err)
+			return
+		}
+		if err != nil || !user.Active {
 			app.session.Remove(r, "authenticatedUserID")
 			next.ServeHTTP(w, r)
 			return
-		} else if err != nil {
-			app.serverError(w, err)
-			return
 		}
 
 		// Otherwise we know the user is authenticated and active
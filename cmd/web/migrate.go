@@ -0,0 +1,164 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"yudhiesh/snippetbox/pkg/migrate"
+	"yudhiesh/snippetbox/pkg/models/mysql"
+	"yudhiesh/snippetbox/pkg/models/sqlite"
+
+	mysqldriver "github.com/go-sql-driver/mysql"
+)
+
+var errMigrateUsage = errors.New("usage: migrate up | down [n] | status | baseline version")
+
+// Returns a Migrator for the database of the given storage backend. It opens
+// its own connection pool, which the caller has to close.
+func newMigrator(backend, dsn string) (*migrate.Migrator, error) {
+	if dsn == "" {
+		dsn = defaultDSNs[backend]
+	}
+	switch backend {
+	case "mysql":
+		// A migration is made up of several statements which are sent to
+		// MySQL in one go, which the driver only allows when asked to
+		cfg, err := mysqldriver.ParseDSN(dsn)
+		if err != nil {
+			return nil, err
+		}
+		cfg.MultiStatements = true
+		cfg.ParseTime = true
+		db, err := openDB(cfg.FormatDSN())
+		if err != nil {
+			return nil, err
+		}
+		m, err := migrate.New(db, mysql.Migrations)
+		if err != nil {
+			db.Close()
+			return nil, err
+		}
+		return m, nil
+	case "sqlite":
+		db, err := sqlite.Open(dsn)
+		if err != nil {
+			return nil, err
+		}
+		m, err := migrate.New(db, sqlite.Migrations)
+		if err != nil {
+			db.Close()
+			return nil, err
+		}
+		return m, nil
+	case "memory":
+		return nil, errors.New("the memory backend has no schema to migrate")
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", backend)
+	}
+}
+
+// Applies the migrations to an SQLite database which has no tables yet, so
+// that a new database file can be used straight away without -auto-migrate.
+// Existing databases are left for the migrate subcommand to upgrade.
+func createSQLiteSchema(db *sql.DB) error {
+	var tables int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table'`).Scan(&tables); err != nil {
+		return err
+	}
+	if tables > 0 {
+		return nil
+	}
+	m, err := migrate.New(db, sqlite.Migrations)
+	if err != nil {
+		return err
+	}
+	_, err = m.Up()
+	return err
+}
+
+// Runs the migrate subcommand given by args and writes what it did to w
+func runMigrate(m *migrate.Migrator, args []string, w io.Writer) error {
+	if len(args) == 0 {
+		return errMigrateUsage
+	}
+	switch args[0] {
+	case "up":
+		if len(args) != 1 {
+			return errMigrateUsage
+		}
+		applied, err := m.Up()
+		for _, migration := range applied {
+			fmt.Fprintf(w, "Applied %d_%s\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			fmt.Fprintln(w, "No migrations to apply")
+		}
+	case "down":
+		// Only the most recent migration is rolled back unless told otherwise
+		n := 1
+		if len(args) > 2 {
+			return errMigrateUsage
+		}
+		if len(args) == 2 {
+			var err error
+			n, err = strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				return errMigrateUsage
+			}
+		}
+		rolledBack, err := m.Down(n)
+		for _, migration := range rolledBack {
+			fmt.Fprintf(w, "Rolled back %d_%s\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(rolledBack) == 0 {
+			fmt.Fprintln(w, "No migrations to roll back")
+		}
+	case "baseline":
+		// The version the existing schema is at has to be given, as marking
+		// too many migrations applied would skip them for good
+		if len(args) != 2 {
+			return errMigrateUsage
+		}
+		version, err := strconv.Atoi(args[1])
+		if err != nil || version < 1 {
+			return errMigrateUsage
+		}
+		recorded, err := m.Baseline(version)
+		for _, migration := range recorded {
+			fmt.Fprintf(w, "Marked %d_%s as applied\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(recorded) == 0 {
+			fmt.Fprintln(w, "No migrations to mark as applied")
+		}
+	case "status":
+		if len(args) != 1 {
+			return errMigrateUsage
+		}
+		statuses, err := m.Status()
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			applied := "pending"
+			if !s.Pending() {
+				applied = s.Applied.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(w, "%04d_%-30s %s\n", s.Version, s.Name, applied)
+		}
+	default:
+		return errMigrateUsage
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunMigrate(t *testing.T) {
+	m, err := newMigrator("sqlite", filepath.Join(t.TempDir(), "test_snippetbox.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.DB.Close()
+
+	tests := []struct {
+		name       string
+		args       []string
+		wantOutput string
+		wantError  bool
+	}{
+		{"Up", []string{"up"}, "Applied 1_create_users\n", false},
+		{"Up Again", []string{"up"}, "No migrations to apply\n", false},
+		{"Down", []string{"down"}, "Rolled back 17_add_revision_files\n", false},
+		{"Status", []string{"status"}, "pending", false},
+		{"Down Many", []string{"down", "16"}, "Rolled back 1_create_users\n", false},
+		{"Baseline", []string{"baseline", "2"}, "Marked 2_create_snippets as applied\n", false},
+		{"Baseline Again", []string{"baseline", "2"}, "No migrations to mark as applied\n", false},
+		{"Unknown Baseline", []string{"baseline", "99"}, "", true},
+		{"Baseline Without Version", []string{"baseline"}, "", true},
+		{"Invalid Count", []string{"down", "zero"}, "", true},
+		{"Unknown Command", []string{"sideways"}, "", true},
+		{"No Command", nil, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			err := runMigrate(m, tt.args, &out)
+			if (err != nil) != tt.wantError {
+				t.Fatalf("want error %t; got %v", tt.wantError, err)
+			}
+			if !strings.Contains(out.String(), tt.wantOutput) {
+				t.Errorf("want output to contain %q; got %q", tt.wantOutput, out.String())
+			}
+		})
+	}
+}
+
+func TestNewMigratorMemory(t *testing.T) {
+	if _, err := newMigrator("memory", ""); err == nil {
+		t.Error("want error for the memory backend")
+	}
+}
+
+func TestOpenModelsNewSQLite(t *testing.T) {
+	store, err := openModels("sqlite", filepath.Join(t.TempDir(), "new.db"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.db.Close()
+
+	app := newTestApplication(t)
+	app.snippets = store.snippets
+	app.comments = store.comments
+	app.stars = store.stars
+	app.views = store.views
+	app.collections = store.collections
+	app.users = store.users
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	if code, _, _ := ts.get(t, "/"); code != http.StatusOK {
+		t.Errorf("want %d; got %d", http.StatusOK, code)
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"log"
+	"sync"
+	"time"
+
+	"yudhiesh/snippetbox/pkg/models"
+)
+
+// Application metrics, served as JSON by the metrics handler. They are kept
+// out of the default expvar handler, which would also publish the command
+// line and with it the session secret.
+var metrics = new(expvar.Map).Init()
+
+var (
+	// The number of expired snippets deleted by the purger
+	snippetsPurged = new(expvar.Int)
+	// The number of times the purger has run, and of those runs which failed
+	purgeRuns   = new(expvar.Int)
+	purgeErrors = new(expvar.Int)
+	// When the purger last finished a run
+	lastPurge = new(expvar.String)
+)
+
+func init() {
+	metrics.Set("snippets_purged", snippetsPurged)
+	metrics.Set("purge_runs", purgeRuns)
+	metrics.Set("purge_errors", purgeErrors)
+	metrics.Set("last_purge", lastPurge)
+}
+
+// The purger periodically deletes expired snippets in the background
+type purger struct {
+	snippets  snippets
+	interval  time.Duration
+	batchSize int
+	errorLog  *log.Logger
+	infoLog   *log.Logger
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// Starts purging immediately and then once every interval until Stop is called
+func (p *purger) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	p.cancel = cancel
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+
+		ticker := time.NewTicker(p.interval)
+		defer ticker.Stop()
+		for {
+			p.purge(ctx)
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Stops the purger, canceling the query of a run in progress, and waits for
+// it to return
+func (p *purger) Stop() {
+	p.cancel()
+	p.wg.Wait()
+}
+
+// Deletes expired snippets one batch at a time until there are none left or
+// the purger is stopped. Batches keep each statement short so that other
+// queries aren't held up behind one large delete.
+func (p *purger) purge(ctx context.Context) {
+	purgeRuns.Add(1)
+	total := 0
+	defer func() {
+		lastPurge.Set(time.Now().UTC().Format(time.RFC3339))
+		if total > 0 {
+			p.infoLog.Printf("Purged %d expired snippets", total)
+		}
+	}()
+
+	for {
+		n, err := p.snippets.PurgeExpired(ctx, p.batchSize)
+		if errors.Is(err, models.ErrCanceled) {
+			return
+		}
+		if err != nil {
+			purgeErrors.Add(1)
+			p.errorLog.Printf("purging expired snippets: %s", err)
+			return
+		}
+		total += n
+		snippetsPurged.Add(int64(n))
+		if n < p.batchSize {
+			return
+		}
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestPurger(t *testing.T) {
+	app := newMemoryTestApplication(t)
+	ctx := context.Background()
+	if err := app.users.Insert(ctx, "Alice", "alice@example.com", "pa$$word"); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 5; i++ {
+		if _, err := app.snippets.Insert(ctx, 1, "Expired", oneFile("Expired", ""), yesterday, "public", false, "", nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	slug, err := app.snippets.Insert(ctx, 1, "Live", oneFile("Live", ""), nextWeek, "public", false, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	before := snippetsPurged.Value()
+	p := &purger{
+		snippets:  app.snippets,
+		interval:  time.Hour,
+		batchSize: 2,
+		errorLog:  app.errorLog,
+		infoLog:   app.infoLog,
+	}
+	// A run keeps deleting batches until none are left
+	p.purge(ctx)
+
+	if purged := snippetsPurged.Value() - before; purged != 5 {
+		t.Errorf("want 5 purged; got %d", purged)
+	}
+	if _, err := app.snippets.GetBySlug(ctx, slug); err != nil {
+		t.Errorf("want live snippet kept; got %v", err)
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	app := newTestApplication(t)
+
+	// The metrics aren't served by the public routes
+	public := newTestServer(t, app.routes())
+	defer public.Close()
+	if statusCode, _, _ := public.get(t, "/metrics"); statusCode != http.StatusNotFound {
+		t.Errorf("public: want %d; got %d", http.StatusNotFound, statusCode)
+	}
+
+	ts := newTestServer(t, app.metricsRoutes())
+	defer ts.Close()
+
+	statusCode, header, body := ts.get(t, "/metrics")
+	if statusCode != http.StatusOK {
+		t.Errorf("want %d; got %d", http.StatusOK, statusCode)
+	}
+	if contentType := header.Get("Content-Type"); contentType != "application/json; charset=utf-8" {
+		t.Errorf("want JSON content type; got %q", contentType)
+	}
+
+	var m map[string]interface{}
+	if err := json.NewDecoder(bytes.NewReader(body)).Decode(&m); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := m["snippets_purged"]; !ok {
+		t.Errorf("want snippets_purged in %s", body)
+	}
+}
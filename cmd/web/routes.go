@@ -25,13 +25,45 @@ func (app *Application) routes() http.Handler {
 	mux.Get("/user/profile", dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.profile))
 	mux.Get("/snippet/create", dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.createSnippetForm))
 	mux.Post("/snippet/create", dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.createSnippet))
-	mux.Get("/snippet/:id", dynamicMiddleware.ThenFunc(app.showSnippet))
+	mux.Get("/snippet/search", dynamicMiddleware.ThenFunc(app.searchSnippets))
+	mux.Get("/snippet/:slug", dynamicMiddleware.ThenFunc(app.showSnippet))
+	mux.Post("/snippet/:slug/burn", dynamicMiddleware.ThenFunc(app.burnSnippet))
+	mux.Post("/snippet/:slug/unlock", dynamicMiddleware.ThenFunc(app.unlockSnippet))
+	mux.Get("/snippet/:slug/raw", dynamicMiddleware.ThenFunc(app.rawSnippet))
+	mux.Get("/snippet/:slug/raw/:file", dynamicMiddleware.ThenFunc(app.rawSnippetFile))
+	mux.Get("/snippet/:slug/download", dynamicMiddleware.ThenFunc(app.downloadSnippet))
+	mux.Get("/snippet/:slug/edit", dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.editSnippetForm))
+	mux.Post("/snippet/:slug/edit", dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.editSnippet))
+	mux.Post("/snippet/:slug/delete", dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.deleteSnippet))
+	mux.Post("/snippet/:slug/restore", dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.restoreSnippet))
+	mux.Post("/snippet/:slug/comments", dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.createComment))
+	mux.Post("/snippet/:slug/comments/:id/delete", dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.deleteComment))
+	mux.Post("/snippet/:slug/collections", dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.collectSnippet))
+	mux.Post("/snippet/:slug/collections/:id/delete", dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.uncollectSnippet))
+	mux.Post("/snippet/:slug/star", dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.toggleStar))
+	mux.Post("/snippet/:slug/fork", dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.forkSnippet))
+	mux.Post("/snippet/:slug/renew", dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.renewSnippet))
+	mux.Get("/snippet/:slug/history", dynamicMiddleware.ThenFunc(app.snippetHistory))
+	mux.Get("/snippet/:slug/history/:version", dynamicMiddleware.ThenFunc(app.showRevision))
+
+	mux.Get("/tag/:name", dynamicMiddleware.ThenFunc(app.listTag))
+
+	mux.Post("/collection/create", dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.createCollection))
+	mux.Get("/collection/:id", dynamicMiddleware.ThenFunc(app.showCollection))
+	mux.Post("/collection/:id/edit", dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.editCollection))
+	mux.Post("/collection/:id/delete", dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.deleteCollection))
 
 	mux.Get("/user/signup", dynamicMiddleware.ThenFunc(app.signupUserForm))
 	mux.Post("/user/signup", dynamicMiddleware.ThenFunc(app.signupUser))
 	mux.Get("/user/login", dynamicMiddleware.ThenFunc(app.loginUserForm))
 	mux.Post("/user/login", dynamicMiddleware.ThenFunc(app.loginUser))
 	mux.Post("/user/logout", dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.logoutUser))
+	mux.Get("/user/collections", dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.userCollections))
+	mux.Get("/user/starred", dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.starredSnippets))
+	mux.Get("/user/export", dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.exportSnippets))
+	mux.Get("/user/import", dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.importSnippetsForm))
+	// The body is limited before the CSRF check reads the uploaded archive
+	mux.Post("/user/import", alice.New(limitBody(maxImportSize)).Extend(dynamicMiddleware).Append(app.requireAuthentication).ThenFunc(app.importSnippets))
 	mux.Get("/user/change-password", dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.changePasswordForm))
 	mux.Post("/user/change-password", dynamicMiddleware.Append(app.requireAuthentication).ThenFunc(app.changePassword))
 
@@ -45,3 +77,12 @@ func (app *Application) routes() http.Handler {
 
 	return standardMiddleware.Then(mux)
 }
+
+// The routes of the metrics listener, which is kept apart from the public
+// routes so that the metrics are only served where -metrics-addr says
+func (app *Application) metricsRoutes() http.Handler {
+	mux := pat.New()
+	mux.Get("/metrics", http.HandlerFunc(metricsHandler))
+
+	return alice.New(app.recoverPanic, app.logRequest).Then(mux)
+}
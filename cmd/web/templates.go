@@ -1,12 +1,22 @@
 package main
 
 import (
+	"bytes"
 	"html/template"
 	"path/filepath"
+	"regexp"
+	"strings"
 	"time"
+	"unicode"
 
 	"yudhiesh/snippetbox/pkg/forms"
 	"yudhiesh/snippetbox/pkg/models"
+
+	"github.com/alecthomas/chroma/formatters/html"
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	highlighting "github.com/yuin/goldmark-highlighting"
+	"github.com/yuin/goldmark/extension"
 )
 
 // Define a templateData type to act as the holding structure for
@@ -14,14 +24,34 @@ import (
 // At the moment it only contains one field, but we'll add more
 // to it as the build progresses.
 type templateData struct {
-	CSRFToken       string
-	CurrentYear     int
-	Flash           string
-	Form            *forms.Form
-	IsAuthenticated bool
-	Snippet         *models.Snippet
-	Snippets        []*models.Snippet
-	User            *models.User
+	AuthenticatedUserID int
+	Collected           []*models.Collection
+	Collection          *models.Collection
+	Collections         []*models.Collection
+	Comments            []*models.Comment
+	CSRFToken           string
+	CurrentYear         int
+	Deleted             []*models.Snippet
+	ExpiryChoices       []expiryChoice
+	Flash               string
+	Files               []*models.File
+	Form                *forms.Form
+	Forks               []*models.Snippet
+	Imports             []*importEntry
+	IsAuthenticated     bool
+	Languages           []language
+	NewerPage           string
+	OlderPage           string
+	Parent              *models.Snippet
+	Query               string
+	Revision            *models.Revision
+	Revisions           []*models.Revision
+	Snippet             *models.Snippet
+	Snippets            []*models.Snippet
+	Starred             bool
+	Tag                 string
+	Tombstone           string
+	User                *models.User
 }
 
 // Returns a human readable formatted string of the time.Time object
@@ -34,13 +64,138 @@ func humanDate(t time.Time) string {
 	return t.UTC().Format("02 Jan 2006 at 15:04")
 }
 
+// Returns the expiry time of a snippet like humanDate, or "Never" for snippets
+// which never expire
+func expiryDate(t time.Time) string {
+	if t.IsZero() {
+		return "Never"
+	}
+	return humanDate(t)
+}
+
+// The number of characters shown in a search result excerpt, and how many of
+// them come before the first match
+const (
+	excerptLength  = 200
+	excerptContext = 40
+)
+
+// Returns a short extract of the content around the first match of any of the
+// words in the query, with every match wrapped in a <mark> element. The
+// content is escaped so the result is safe to render as HTML.
+func excerpt(content, query string) template.HTML {
+	runes := []rune(content)
+
+	// Lower case rune by rune so that indexes line up with the original
+	lower := make([]rune, len(runes))
+	for i, r := range runes {
+		lower[i] = unicode.ToLower(r)
+	}
+
+	// Mark every rune which is part of a match for one of the query terms
+	marked := make([]bool, len(runes))
+	first := -1
+	for _, term := range strings.Fields(strings.ToLower(query)) {
+		t := []rune(strings.Trim(term, `+-*"()<>~`))
+		if len(t) == 0 {
+			continue
+		}
+		for i := 0; i+len(t) <= len(lower); i++ {
+			if string(lower[i:i+len(t)]) != string(t) {
+				continue
+			}
+			for j := i; j < i+len(t); j++ {
+				marked[j] = true
+			}
+			if first == -1 || i < first {
+				first = i
+			}
+		}
+	}
+
+	start := 0
+	if first > excerptContext {
+		start = first - excerptContext
+	}
+	end := start + excerptLength
+	if end > len(runes) {
+		end = len(runes)
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("&hellip;")
+	}
+	for i := start; i < end; {
+		j := i
+		for j < end && marked[j] == marked[i] {
+			j++
+		}
+		text := template.HTMLEscapeString(string(runes[i:j]))
+		if marked[i] {
+			b.WriteString("<mark>" + text + "</mark>")
+		} else {
+			b.WriteString(text)
+		}
+		i = j
+	}
+	if end < len(runes) {
+		b.WriteString("&hellip;")
+	}
+	return template.HTML(b.String())
+}
+
+// Converts Markdown to HTML with the GitHub flavoured extensions. Fenced code
+// blocks are highlighted with the same classes as code snippets, so they are
+// coloured by highlight.css.
+var markdownRenderer = goldmark.New(
+	goldmark.WithExtensions(
+		extension.GFM,
+		highlighting.NewHighlighting(
+			highlighting.WithStyle(highlightStyle.Name),
+			highlighting.WithFormatOptions(html.WithClasses(true)),
+		),
+	),
+)
+
+// The elements and attributes which rendered Markdown may use. It allows what
+// Markdown itself produces, minus scripts, event handlers and dangerous URLs,
+// plus the classes of highlighted code.
+var markdownPolicy = func() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^[a-z0-9 ]+$`)).OnElements("pre", "code", "span")
+	return p
+}()
+
+// Renders Markdown content as sanitized HTML. Markdown is trusted no more
+// than any other content: raw HTML is left out by the renderer and the
+// result is sanitized as well.
+func markdown(content string) template.HTML {
+	var b bytes.Buffer
+	if err := markdownRenderer.Convert([]byte(content), &b); err != nil {
+		// Template functions can't return errors, so the source is shown
+		// instead
+		return template.HTML("<pre>" + template.HTMLEscapeString(content) + "</pre>")
+	}
+	return template.HTML(markdownPolicy.SanitizeBytes(b.Bytes()))
+}
+
 // Initialize a template.FuncMap object and store it in a global variable
 // Acts as a lookup between the names of our custom template functions and the
 // functions themselves
 // NOTE: Custom template functions like humanDate must return a single
 // value(excluding the error)!
 var functions = template.FuncMap{
-	"humanDate": humanDate,
+	"humanDate":    humanDate,
+	"expiryDate":   expiryDate,
+	"excerpt":      excerpt,
+	"highlight":    highlight,
+	"markdown":     markdown,
+	"languageName": languageName,
+	"linePrefix":   linePrefix,
+	"fileErrorKey": fileErrorKey,
+	"annotate":     annotate,
+	"lineRange":    lineRange,
 }
 
 func newTemplateCache(dir string) (map[string]*template.Template, error) {
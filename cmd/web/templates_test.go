@@ -1,6 +1,7 @@
 package main
 
 import (
+	"strings"
 	"testing"
 	"time"
 )
@@ -40,3 +41,102 @@ func TestHumanDate(t *testing.T) {
 	}
 
 }
+
+func TestExcerpt(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		query   string
+		want    string
+	}{
+		{
+			name:    "Single match",
+			content: "An old silent pond",
+			query:   "silent",
+			want:    "An old <mark>silent</mark> pond",
+		},
+		{
+			name:    "Case insensitive",
+			content: "An old silent pond",
+			query:   "OLD Pond",
+			want:    "An <mark>old</mark> silent <mark>pond</mark>",
+		},
+		{
+			name:    "No match",
+			content: "An old silent pond",
+			query:   "frog",
+			want:    "An old silent pond",
+		},
+		{
+			name:    "Escaped",
+			content: "<b>frog</b>",
+			query:   "frog",
+			want:    "&lt;b&gt;<mark>frog</mark>&lt;/b&gt;",
+		},
+		{
+			name:    "Truncated",
+			content: strings.Repeat("a ", 50) + "frog" + strings.Repeat(" b", 150),
+			query:   "frog",
+			want:    "&hellip;" + strings.Repeat("a ", 20) + "<mark>frog</mark>" + strings.Repeat(" b", 78) + "&hellip;",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := string(excerpt(tt.content, tt.query))
+			if got != tt.want {
+				t.Errorf("Want %q; Got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestMarkdown(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		want     []string
+		dontWant []string
+	}{
+		{
+			name:    "Formatting",
+			content: "# An old pond\n\nA *frog* jumps in\n\n- splash\n",
+			want:    []string{"<h1>An old pond</h1>", "<em>frog</em>", "<li>splash</li>"},
+		},
+		{
+			name:     "Script",
+			content:  "Before\n\n<script>alert(1)</script>\n\nAfter",
+			want:     []string{"<p>Before</p>", "<p>After</p>"},
+			dontWant: []string{"<script", "alert(1)"},
+		},
+		{
+			name:     "Event handler",
+			content:  `<img src="pond.png" onerror="alert(1)">` + "\n\n" + `An <b onclick="alert(1)">old</b> pond`,
+			dontWant: []string{"onerror", "onclick"},
+		},
+		{
+			name:     "JavaScript link",
+			content:  "[pond](javascript:alert(1))",
+			dontWant: []string{"javascript:"},
+		},
+		{
+			name:    "Fenced code",
+			content: "```go\nfunc main() {}\n```\n",
+			want:    []string{`<pre class="chroma">`, `<span class="kd">func</span>`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := string(markdown(tt.content))
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("want %q to contain %q", got, want)
+				}
+			}
+			for _, dontWant := range tt.dontWant {
+				if strings.Contains(got, dontWant) {
+					t.Errorf("want %q not to contain %q", got, dontWant)
+				}
+			}
+		})
+	}
+}
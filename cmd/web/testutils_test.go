@@ -1,9 +1,11 @@
 package main
 
 import (
+	"bytes"
 	"html"
 	"io/ioutil"
 	"log"
+	"mime/multipart"
 	"net/http"
 	"net/http/cookiejar"
 	"net/http/httptest"
@@ -11,6 +13,8 @@ import (
 	"regexp"
 	"testing"
 	"time"
+	"yudhiesh/snippetbox/pkg/models"
+	"yudhiesh/snippetbox/pkg/models/memory"
 	"yudhiesh/snippetbox/pkg/models/mock"
 
 	"github.com/golangcollege/sessions"
@@ -28,18 +32,41 @@ func newTestApplication(t *testing.T) *Application {
 	session.Lifetime = 12 * time.Hour
 	session.Secure = true
 
+	views := &mock.ViewModel{}
 	return &Application{
 		// Logger is needed by every middleware
 		// Without these two there would be a panic
-		errorLog:      log.New(ioutil.Discard, "", 0),
-		infoLog:       log.New(ioutil.Discard, "", 0),
-		session:       session,
-		snippets:      &mock.SnippetModel{},
-		templateCache: templateCache,
-		users:         &mock.UserModel{},
+		errorLog:       log.New(ioutil.Discard, "", 0),
+		infoLog:        log.New(ioutil.Discard, "", 0),
+		session:        session,
+		pageSize:       10,
+		snippets:       &mock.SnippetModel{},
+		comments:       &mock.CommentModel{},
+		stars:          &mock.StarModel{},
+		views:          views,
+		collections:    &mock.CollectionModel{},
+		templateCache:  templateCache,
+		users:          &mock.UserModel{},
+		unlockThrottle: newThrottle(maxUnlockFailures, unlockFailureWindow),
+		viewCounter:    newViewCounter(views, time.Minute, log.New(ioutil.Discard, "", 0)),
 	}
 }
 
+// Creates a new test Application backed by the in-memory models instead of
+// the mocks, for tests which need realistic behaviour across several requests
+func newMemoryTestApplication(t *testing.T) *Application {
+	app := newTestApplication(t)
+	db := memory.New()
+	app.snippets = &memory.SnippetModel{DB: db}
+	app.comments = &memory.CommentModel{DB: db}
+	app.stars = &memory.StarModel{DB: db}
+	app.views = &memory.ViewModel{DB: db}
+	app.collections = &memory.CollectionModel{DB: db}
+	app.viewCounter = newViewCounter(app.views, time.Minute, app.errorLog)
+	app.users = &memory.UserModel{DB: db}
+	return app
+}
+
 // Custom testServer which anonymously embeds a httptest.Server instance
 type testServer struct {
 	*httptest.Server
@@ -82,6 +109,26 @@ func (ts *testServer) get(t *testing.T, urlPath string) (int, http.Header, []byt
 	return rs.StatusCode, rs.Header, body
 }
 
+// Makes a GET request like get, but as a client with the given user agent
+// instead of the Go HTTP client, which counts as a bot
+func (ts *testServer) getAs(t *testing.T, urlPath, userAgent string) (int, http.Header, []byte) {
+	req, err := http.NewRequest(http.MethodGet, ts.URL+urlPath, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("User-Agent", userAgent)
+	rs, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rs.Body.Close()
+	body, err := ioutil.ReadAll(rs.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rs.StatusCode, rs.Header, body
+}
+
 // Regex to capture the CSRF token value from the HTML for our user signup page
 var csrfTokenRX = regexp.MustCompile(`<input type='hidden' name='csrf_token' value='(.+)'>`)
 
@@ -121,3 +168,61 @@ func (ts *testServer) postForm(t *testing.T, urlPath string, form url.Values) (i
 	// Return the response status, headers and body.
 	return rs.StatusCode, rs.Header, body
 }
+
+// Sends a multipart POST request to the test server holding the form values
+// and a file with the given content in field
+func (ts *testServer) postFile(t *testing.T, urlPath string, form url.Values, field, filename string, content []byte) (int, http.Header, []byte) {
+	var b bytes.Buffer
+	mw := multipart.NewWriter(&b)
+	for key, values := range form {
+		for _, v := range values {
+			if err := mw.WriteField(key, v); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	fw, err := mw.CreateFormFile(field, filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fw.Write(content); err != nil {
+		t.Fatal(err)
+	}
+	if err := mw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	rs, err := ts.Client().Post(ts.URL+urlPath, mw.FormDataContentType(), &b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rs.Body.Close()
+	body, err := ioutil.ReadAll(rs.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rs.StatusCode, rs.Header, body
+}
+
+// Logs the test client in as the mock user so that requests to routes which
+// require authentication succeed
+func (ts *testServer) login(t *testing.T) {
+	_, _, body := ts.get(t, "/user/login")
+	csrfToken := extractCSRFToken(t, body)
+	form := url.Values{}
+	form.Add("email", "alice@example.com")
+	form.Add("password", "")
+	form.Add("csrf_token", csrfToken)
+	ts.postForm(t, "/user/login", form)
+}
+
+// Expiry times for snippets inserted by the tests
+var (
+	nextWeek  = time.Now().AddDate(0, 0, 7)
+	yesterday = time.Now().AddDate(0, 0, -1)
+)
+
+// Returns the files of a snippet holding a single file
+func oneFile(content, language string) []*models.File {
+	return []*models.File{{Name: "file1", Language: language, Content: content}}
+}
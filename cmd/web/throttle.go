@@ -0,0 +1,98 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// A throttle limits how many times something may fail for each key, such as
+// the attempts of a client to unlock a snippet. Every attempt counts as a
+// failure from the moment it is allowed until it is released or the key is
+// reset, so that attempts made at the same time can't all be allowed before
+// any of them fails. Once a key has max attempts within the window, further
+// attempts are refused until the oldest falls out of the window. It is safe
+// for concurrent use.
+type throttle struct {
+	max    int
+	window time.Duration
+	now    func() time.Time
+
+	mu        sync.Mutex
+	failures  map[string][]time.Time
+	lastSweep time.Time
+}
+
+// Returns a throttle allowing max failures for each key within the window
+func newThrottle(max int, window time.Duration) *throttle {
+	return &throttle{
+		max:      max,
+		window:   window,
+		now:      time.Now,
+		failures: map[string][]time.Time{},
+	}
+}
+
+// Reports whether another attempt may be made for the key, and if so records
+// it as a failure until it is released or the key is reset
+func (t *throttle) Allow(key string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	times := t.recent(key, now)
+	if len(times) >= t.max {
+		return false
+	}
+	t.failures[key] = append(times, now)
+
+	// Keys which stopped failing are dropped once per window, so that the
+	// map doesn't grow forever
+	if now.Sub(t.lastSweep) >= t.window {
+		for k := range t.failures {
+			t.recent(k, now)
+		}
+		t.lastSweep = now
+	}
+	return true
+}
+
+// Forgets an allowed attempt for the key which turned out not to count, such
+// as one which couldn't be checked
+func (t *throttle) Release(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	times := t.recent(key, t.now())
+	if len(times) == 0 {
+		return
+	}
+	t.failures[key] = times[:len(times)-1]
+	if len(times) == 1 {
+		delete(t.failures, key)
+	}
+}
+
+// Forgets the failures of the key, after a successful attempt
+func (t *throttle) Reset(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.failures, key)
+}
+
+// Returns the failures of the key within the window, dropping the older ones.
+// The caller must hold the lock.
+func (t *throttle) recent(key string, now time.Time) []time.Time {
+	times := t.failures[key]
+	i := 0
+	for i < len(times) && now.Sub(times[i]) >= t.window {
+		i++
+	}
+	times = times[i:]
+	if len(times) == 0 {
+		delete(t.failures, key)
+	} else {
+		t.failures[key] = times
+	}
+	return times
+}
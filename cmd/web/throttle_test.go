@@ -0,0 +1,77 @@
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestThrottle(t *testing.T) {
+	now := time.Date(2021, 1, 1, 10, 0, 0, 0, time.UTC)
+	th := newThrottle(3, time.Minute)
+	th.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		if !th.Allow("a") {
+			t.Fatalf("want attempt %d allowed", i+1)
+		}
+		now = now.Add(10 * time.Second)
+	}
+	if th.Allow("a") {
+		t.Error("want attempt refused after 3 failures")
+	}
+	if !th.Allow("b") {
+		t.Error("want other keys unaffected")
+	}
+
+	// The first failure leaves the window a minute after it was made
+	now = now.Add(30 * time.Second)
+	if !th.Allow("a") {
+		t.Error("want attempt allowed once a failure left the window")
+	}
+	if th.Allow("a") {
+		t.Error("want the allowed attempt counted as a failure")
+	}
+
+	// Released attempts don't count
+	th.Release("a")
+	if !th.Allow("a") {
+		t.Error("want attempt allowed after a release")
+	}
+
+	th.Reset("a")
+	if !th.Allow("a") {
+		t.Error("want attempt allowed after a reset")
+	}
+
+	// Old keys are swept away by later attempts
+	th.Allow("c")
+	now = now.Add(2 * time.Minute)
+	th.Allow("d")
+	if _, ok := th.failures["c"]; ok {
+		t.Error("want stale key to be swept")
+	}
+}
+
+func TestThrottleConcurrent(t *testing.T) {
+	th := newThrottle(5, time.Minute)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	allowed := 0
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if th.Allow("a") {
+				mu.Lock()
+				allowed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if allowed != 5 {
+		t.Errorf("want 5 attempts allowed; got %d", allowed)
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"log"
+	"net/http"
+	"regexp"
+	"sync"
+	"time"
+
+	"yudhiesh/snippetbox/pkg/models"
+)
+
+var (
+	// The number of views counted, and of the batches written to the
+	// database along with those which failed
+	viewsCounted    = new(expvar.Int)
+	viewFlushes     = new(expvar.Int)
+	viewFlushErrors = new(expvar.Int)
+)
+
+func init() {
+	metrics.Set("views_counted", viewsCounted)
+	metrics.Set("view_flushes", viewFlushes)
+	metrics.Set("view_flush_errors", viewFlushErrors)
+}
+
+// The user agents of crawlers, link previews, monitoring and command line
+// clients, none of which are people reading a snippet
+var botRX = regexp.MustCompile(`(?i)bot|crawl|spider|slurp|preview|facebookexternalhit|monitor|curl|wget|python|go-http-client|headless`)
+
+// Reports whether a request was made by a bot rather than a browser. Clients
+// which don't say what they are count as bots.
+func isBot(userAgent string) bool {
+	return userAgent == "" || botRX.MatchString(userAgent)
+}
+
+// A viewCounter counts the views of snippets in memory and adds them to the
+// database in a single batch every interval, so that reading a snippet
+// doesn't write to the database. A batch which can't be written is kept for
+// the next one. It is safe for concurrent use.
+type viewCounter struct {
+	views    views
+	interval time.Duration
+	errorLog *log.Logger
+
+	mu      sync.Mutex
+	pending map[int]int
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// Returns a counter writing the views to the view model every interval once
+// it is started
+func newViewCounter(views views, interval time.Duration, errorLog *log.Logger) *viewCounter {
+	return &viewCounter{
+		views:    views,
+		interval: interval,
+		errorLog: errorLog,
+		pending:  map[int]int{},
+	}
+}
+
+// Counts a view of the snippet with the given ID
+func (c *viewCounter) Record(id int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.pending[id]++
+	viewsCounted.Add(1)
+}
+
+// Returns the views of the snippet with the given ID which haven't been
+// written yet
+func (c *viewCounter) Pending(id int) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.pending[id]
+}
+
+// Writes the views every interval until Stop is called
+func (c *viewCounter) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	c.cancel = cancel
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+
+		ticker := time.NewTicker(c.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				c.flush(ctx)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Stops the counter, canceling a write in progress, and then writes the views
+// which are still pending so that none are lost when the server stops
+func (c *viewCounter) Stop() {
+	c.cancel()
+	c.wg.Wait()
+	c.flush(context.Background())
+}
+
+// Writes the pending views to the database as a single batch. If that fails
+// they are counted again, to be written with the next batch.
+func (c *viewCounter) flush(ctx context.Context) {
+	c.mu.Lock()
+	batch := c.pending
+	c.pending = map[int]int{}
+	c.mu.Unlock()
+	if len(batch) == 0 {
+		return
+	}
+
+	viewFlushes.Add(1)
+	err := c.views.Add(ctx, batch)
+	if err == nil {
+		return
+	}
+	c.mu.Lock()
+	for id, n := range batch {
+		c.pending[id] += n
+	}
+	c.mu.Unlock()
+	if !errors.Is(err, models.ErrCanceled) {
+		viewFlushErrors.Add(1)
+		c.errorLog.Printf("writing view counts: %s", err)
+	}
+}
+
+// The most snippets remembered as viewed by a session, which keeps the
+// session cookie small
+const maxViewedSnippets = 50
+
+// Counts a view of a snippet, unless it was made by a bot, by the owner of the
+// snippet or by a session which has viewed the snippet before
+func (app *Application) countView(r *http.Request, s *models.Snippet) {
+	if isBot(r.UserAgent()) || s.UserID == app.session.GetInt(r, "authenticatedUserID") {
+		return
+	}
+	ids, _ := app.session.Get(r, "viewedSnippets").([]int)
+	for _, id := range ids {
+		if id == s.ID {
+			return
+		}
+	}
+	ids = append(ids, s.ID)
+	if len(ids) > maxViewedSnippets {
+		ids = ids[len(ids)-maxViewedSnippets:]
+	}
+	app.session.Put(r, "viewedSnippets", ids)
+	app.viewCounter.Record(s.ID)
+}
+
+// Fills in the Views field of each snippet, including the views which haven't
+// been written yet
+func (app *Application) loadViews(ctx context.Context, snippets ...*models.Snippet) error {
+	if err := app.views.Count(ctx, snippets...); err != nil {
+		return err
+	}
+	for _, s := range snippets {
+		s.Views += app.viewCounter.Pending(s.ID)
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"testing"
+	"time"
+
+	"yudhiesh/snippetbox/pkg/models"
+)
+
+// A browser, whose views are counted
+const browserAgent = "Mozilla/5.0 (X11; Linux x86_64; rv:109.0) Gecko/20100101 Firefox/115.0"
+
+func TestIsBot(t *testing.T) {
+	tests := []struct {
+		name      string
+		userAgent string
+		want      bool
+	}{
+		{"Browser", browserAgent, false},
+		{"Empty", "", true},
+		{"Googlebot", "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)", true},
+		{"Link preview", "Slackbot-LinkExpanding 1.0 (+https://api.slack.com/robots)", true},
+		{"curl", "curl/8.4.0", true},
+		{"Go", "Go-http-client/1.1", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isBot(tt.userAgent); got != tt.want {
+				t.Errorf("want %t; got %t", tt.want, got)
+			}
+		})
+	}
+}
+
+// A view model whose writes fail until it is told otherwise
+type flakyViews struct {
+	fail  bool
+	added map[int]int
+}
+
+func (v *flakyViews) Add(ctx context.Context, views map[int]int) error {
+	if v.fail {
+		return errors.New("database is down")
+	}
+	for id, n := range views {
+		v.added[id] += n
+	}
+	return nil
+}
+
+func (v *flakyViews) Count(ctx context.Context, snippets ...*models.Snippet) error {
+	for _, s := range snippets {
+		s.Views = v.added[s.ID]
+	}
+	return nil
+}
+
+func TestViewCounter(t *testing.T) {
+	app := newTestApplication(t)
+	views := &flakyViews{fail: true, added: map[int]int{}}
+	c := newViewCounter(views, time.Hour, app.errorLog)
+	ctx := context.Background()
+
+	c.Record(1)
+	c.Record(1)
+	c.Record(2)
+	if got := c.Pending(1); got != 2 {
+		t.Errorf("want 2 views pending; got %d", got)
+	}
+
+	// A batch which fails is kept for the next one
+	before := viewFlushErrors.Value()
+	c.flush(ctx)
+	if got := viewFlushErrors.Value() - before; got != 1 {
+		t.Errorf("want 1 flush error; got %d", got)
+	}
+	c.Record(1)
+	if got := c.Pending(1); got != 3 {
+		t.Errorf("want 3 views pending after failed flush; got %d", got)
+	}
+
+	views.fail = false
+	c.flush(ctx)
+	if views.added[1] != 3 || views.added[2] != 1 {
+		t.Errorf("want 3 and 1 views written; got %v", views.added)
+	}
+	if got := c.Pending(1); got != 0 {
+		t.Errorf("want no views pending after flush; got %d", got)
+	}
+
+	// Stopping writes the views which are still pending
+	c.Start()
+	c.Record(2)
+	c.Stop()
+	if views.added[2] != 2 {
+		t.Errorf("want 2 views written on stop; got %d", views.added[2])
+	}
+}
+
+func TestViewJourney(t *testing.T) {
+	app := newMemoryTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ctx := context.Background()
+	if err := app.users.Insert(ctx, "Alice", "alice@example.com", "validPa$$word"); err != nil {
+		t.Fatal(err)
+	}
+	slug, err := app.snippets.Insert(ctx, 1, "An old pond", oneFile("A frog jumps in", ""), nextWeek, "public", false, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := app.snippets.GetBySlug(ctx, slug)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A reader is counted once however often they come back, and bots
+	// aren't counted at all
+	for i := 0; i < 3; i++ {
+		if code, _, _ := ts.getAs(t, "/snippet/"+slug, browserAgent); code != http.StatusOK {
+			t.Fatalf("show: want %d; got %d", http.StatusOK, code)
+		}
+	}
+	ts.get(t, "/snippet/"+slug)
+	if got := app.viewCounter.Pending(s.ID); got != 1 {
+		t.Errorf("want 1 view pending; got %d", got)
+	}
+	_, _, body := ts.getAs(t, "/snippet/"+slug, browserAgent)
+	if bytes.Contains(body, []byte("class='views'")) {
+		t.Error("want views hidden from readers")
+	}
+
+	// Another reader is counted too, and the views are written in a batch
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts.Client().Jar = jar
+	ts.getAs(t, "/snippet/"+slug, browserAgent)
+	app.viewCounter.flush(ctx)
+	if got := app.viewCounter.Pending(s.ID); got != 0 {
+		t.Errorf("want no views pending after flush; got %d", got)
+	}
+
+	// Alice sees the views, and isn't counted herself
+	_, _, body = ts.get(t, "/user/login")
+	form := url.Values{}
+	form.Add("email", "alice@example.com")
+	form.Add("password", "validPa$$word")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+	ts.postForm(t, "/user/login", form)
+	ts.getAs(t, "/snippet/"+slug, browserAgent)
+	_, _, body = ts.getAs(t, "/snippet/"+slug, browserAgent)
+	if !bytes.Contains(body, []byte("<span class='views'>2 views</span>")) {
+		t.Error("show: want body to contain 2 views")
+	}
+	_, _, body = ts.get(t, "/user/profile")
+	if !bytes.Contains(body, []byte("<td>2</td>")) {
+		t.Error("profile: want body to contain 2 views")
+	}
+}
@@ -3,10 +3,15 @@ module yudhiesh/snippetbox
 go 1.16
 
 require (
-	github.com/bmizerany/pat v0.0.0-20170815010413-6226ea591a40 // indirect
+	github.com/alecthomas/chroma v0.10.0
+	github.com/bmizerany/pat v0.0.0-20170815010413-6226ea591a40
 	github.com/go-sql-driver/mysql v1.5.0
-	github.com/golangcollege/sessions v1.2.0 // indirect
+	github.com/golangcollege/sessions v1.2.0
 	github.com/justinas/alice v1.2.0
-	github.com/justinas/nosurf v1.1.1 // indirect
-	golang.org/x/crypto v0.0.0-20210322153248-0c34fe9e7dc2 // indirect
+	github.com/justinas/nosurf v1.1.1
+	github.com/microcosm-cc/bluemonday v1.0.18
+	github.com/yuin/goldmark v1.4.8
+	github.com/yuin/goldmark-highlighting v0.0.0-20220208100518-594be1970594
+	golang.org/x/crypto v0.0.0-20210322153248-0c34fe9e7dc2
+	modernc.org/sqlite v1.14.8
 )
@@ -5,6 +5,7 @@ import (
 	"net/url"
 	"regexp"
 	"strings"
+	"unicode"
 	"unicode/utf8"
 )
 
@@ -20,6 +21,10 @@ type Form struct {
 var EmailRX = regexp.MustCompile(
 	"^[a-zA-Z0-9.!#$%&'*+\\/=?^_`{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$")
 
+// Tags may contain lower case letters, digits and the characters '+', '.',
+// '-' and '_', and must start with a letter or digit
+var TagRX = regexp.MustCompile(`^[a-z0-9][a-z0-9+._-]*$`)
+
 // Initializes a new Form struct
 func New(data url.Values) *Form {
 	return &Form{
@@ -83,6 +88,44 @@ func (f *Form) PermittedValues(field string, opts ...string) {
 	f.Errors.Add(field, "This field is invalid")
 }
 
+// Splits a comma or whitespace separated list of tags. Tags are lower cased
+// and duplicates are removed, keeping the order they were given in.
+func SplitTags(value string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(value), func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	})
+	tags := []string{}
+	seen := map[string]bool{}
+	for _, tag := range fields {
+		if seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		tags = append(tags, tag)
+	}
+	return tags
+}
+
+// Check that the field holds at most max tags, each of which is at most
+// maxLength characters long and only uses the characters allowed by TagRX
+func (f *Form) ValidTags(field string, max, maxLength int) {
+	tags := SplitTags(f.Get(field))
+	if len(tags) > max {
+		f.Errors.Add(field, fmt.Sprintf("Too many tags (maximum is %d)", max))
+		return
+	}
+	for _, tag := range tags {
+		if utf8.RuneCountInString(tag) > maxLength {
+			f.Errors.Add(field, fmt.Sprintf("The tag %q is too long (maximum is %d characters)", tag, maxLength))
+			return
+		}
+		if !TagRX.MatchString(tag) {
+			f.Errors.Add(field, fmt.Sprintf("The tag %q contains invalid characters", tag))
+			return
+		}
+	}
+}
+
 // Check that there are no errors in the form
 func (f *Form) Valid() bool {
 	return len(f.Errors) == 0
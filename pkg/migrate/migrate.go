@@ -0,0 +1,273 @@
+// Package migrate applies versioned schema migrations to a database and
+// records which ones have been applied in the schema_migrations table.
+//
+// Migrations are read from pairs of files named
+// <version>_<name>.up.sql and <version>_<name>.down.sql, where the version is
+// a positive number. They are applied in ascending order of version.
+package migrate
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// A Migration changes the schema from the previous version to Version
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status reports whether a migration has been applied and when
+type Status struct {
+	Migration
+	Applied time.Time
+}
+
+// Pending reports whether the migration is yet to be applied
+func (s Status) Pending() bool {
+	return s.Applied.IsZero()
+}
+
+var ErrInvalidFile = errors.New("migrate: invalid migration file")
+
+var fileRX = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Reads every migration in the root directory of fsys, ordered by version
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		matches := fileRX.FindStringSubmatch(entry.Name())
+		if matches == nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidFile, entry.Name())
+		}
+		version, err := strconv.Atoi(matches[1])
+		if err != nil || version < 1 {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidFile, entry.Name())
+		}
+		script, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: matches[2]}
+			byVersion[version] = m
+		}
+		if m.Name != matches[2] {
+			return nil, fmt.Errorf("%w: %s reuses version %d", ErrInvalidFile, entry.Name(), version)
+		}
+		if matches[3] == "up" {
+			m.Up = string(script)
+		} else {
+			m.Down = string(script)
+		}
+	}
+
+	migrations := []Migration{}
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("%w: version %d needs both an up and a down file", ErrInvalidFile, m.Version)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// Migrator applies migrations to a database. The statements in a migration
+// are executed together, so the connection must accept several statements in
+// a single Exec call. Each migration is run in the same transaction as the
+// change to schema_migrations which records it, so in SQLite a migration
+// which fails leaves nothing behind. MySQL commits each schema change as it
+// is made, so a failed migration there may have to be tidied up by hand.
+type Migrator struct {
+	DB         *sql.DB
+	Migrations []Migration
+}
+
+// Returns a Migrator for the migrations in the root directory of fsys
+func New(db *sql.DB, fsys fs.FS) (*Migrator, error) {
+	migrations, err := Load(fsys)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{DB: db, Migrations: migrations}, nil
+}
+
+// Creates the table used to record the applied migrations. The statement is
+// understood by both MySQL and SQLite.
+func (m *Migrator) init() error {
+	_, err := m.DB.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER NOT NULL PRIMARY KEY,
+		applied DATETIME NOT NULL
+	)`)
+	return err
+}
+
+// Returns the time each applied migration was applied, keyed by version
+func (m *Migrator) applied() (map[int]time.Time, error) {
+	if err := m.init(); err != nil {
+		return nil, err
+	}
+	rows, err := m.DB.Query(`SELECT version, applied FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int]time.Time{}
+	for rows.Next() {
+		var version int
+		var t time.Time
+		if err := rows.Scan(&version, &t); err != nil {
+			return nil, err
+		}
+		applied[version] = t
+	}
+	return applied, rows.Err()
+}
+
+// Returns the status of every migration, ordered by version
+func (m *Migrator) Status() ([]Status, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]Status, 0, len(m.Migrations))
+	for _, migration := range m.Migrations {
+		statuses = append(statuses, Status{Migration: migration, Applied: applied[migration.Version]})
+	}
+	return statuses, nil
+}
+
+// Applies every pending migration in order and returns the ones applied
+func (m *Migrator) Up() ([]Migration, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	done := []Migration{}
+	for _, migration := range m.Migrations {
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+		if err := m.apply(migration.Up, migration.Version); err != nil {
+			return done, fmt.Errorf("migrate: applying %d_%s: %w", migration.Version, migration.Name, err)
+		}
+		done = append(done, migration)
+	}
+	return done, nil
+}
+
+// Records every pending migration up to and including the given version as
+// applied without running it, and returns the ones recorded. This adopts a
+// database whose schema was created before it was managed by migrations.
+func (m *Migrator) Baseline(version int) ([]Migration, error) {
+	known := false
+	for _, migration := range m.Migrations {
+		known = known || migration.Version == version
+	}
+	if !known {
+		return nil, fmt.Errorf("migrate: no migration has version %d", version)
+	}
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	done := []Migration{}
+	for _, migration := range m.Migrations {
+		if migration.Version > version {
+			break
+		}
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+		if err := m.apply("", migration.Version); err != nil {
+			return done, fmt.Errorf("migrate: recording %d_%s: %w", migration.Version, migration.Name, err)
+		}
+		done = append(done, migration)
+	}
+	return done, nil
+}
+
+// Runs the script of a migration, if any, and records the migration as
+// applied in a single transaction
+func (m *Migrator) apply(script string, version int) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	if script != "" {
+		if _, err := tx.Exec(script); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	// The time is formatted so that it is stored the same way by MySQL and
+	// SQLite
+	_, err = tx.Exec(`INSERT INTO schema_migrations (version, applied) VALUES(?, ?)`,
+		version, time.Now().UTC().Format("2006-01-02 15:04:05"))
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// Rolls back the n most recently applied migrations, newest first, and
+// returns the ones rolled back
+func (m *Migrator) Down(n int) ([]Migration, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	done := []Migration{}
+	for i := len(m.Migrations) - 1; i >= 0 && len(done) < n; i-- {
+		migration := m.Migrations[i]
+		if _, ok := applied[migration.Version]; !ok {
+			continue
+		}
+		if err := m.revert(migration); err != nil {
+			return done, fmt.Errorf("migrate: rolling back %d_%s: %w", migration.Version, migration.Name, err)
+		}
+		done = append(done, migration)
+	}
+	return done, nil
+}
+
+// Runs the down script of a migration and removes its record in a single
+// transaction
+func (m *Migrator) revert(migration Migration) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(migration.Down); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM schema_migrations WHERE version = ?`, migration.Version); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,222 @@
+package migrate
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	_ "modernc.org/sqlite"
+)
+
+var testMigrations = fstest.MapFS{
+	"0001_create_users.up.sql":      {Data: []byte("CREATE TABLE users (id INTEGER PRIMARY KEY);")},
+	"0001_create_users.down.sql":    {Data: []byte("DROP TABLE users;")},
+	"0002_add_user_name.up.sql":     {Data: []byte("ALTER TABLE users ADD COLUMN name TEXT;")},
+	"0002_add_user_name.down.sql":   {Data: []byte("ALTER TABLE users DROP COLUMN name;")},
+	"0010_create_snippets.up.sql":   {Data: []byte("CREATE TABLE snippets (id INTEGER PRIMARY KEY);\nCREATE INDEX idx_snippets ON snippets(id);")},
+	"0010_create_snippets.down.sql": {Data: []byte("DROP TABLE snippets;")},
+}
+
+func newTestMigrator(t *testing.T) *Migrator {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	m, err := New(db, testMigrations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+// Returns the versions of the given migrations
+func versions(migrations []Migration) []int {
+	v := []int{}
+	for _, m := range migrations {
+		v = append(v, m.Version)
+	}
+	return v
+}
+
+func equal(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name      string
+		fsys      fstest.MapFS
+		wantNames []string
+		wantError error
+	}{
+		{
+			name:      "Ordered By Version",
+			fsys:      testMigrations,
+			wantNames: []string{"create_users", "add_user_name", "create_snippets"},
+		},
+		{
+			name:      "Invalid Name",
+			fsys:      fstest.MapFS{"create_users.sql": {}},
+			wantError: ErrInvalidFile,
+		},
+		{
+			name: "Missing Down",
+			fsys: fstest.MapFS{
+				"0001_create_users.up.sql": {Data: []byte("CREATE TABLE users (id INTEGER);")},
+			},
+			wantError: ErrInvalidFile,
+		},
+		{
+			name: "Reused Version",
+			fsys: fstest.MapFS{
+				"0001_create_users.up.sql":    {Data: []byte("CREATE TABLE users (id INTEGER);")},
+				"0001_create_users.down.sql":  {Data: []byte("DROP TABLE users;")},
+				"0001_create_things.up.sql":   {Data: []byte("CREATE TABLE things (id INTEGER);")},
+				"0001_create_things.down.sql": {Data: []byte("DROP TABLE things;")},
+			},
+			wantError: ErrInvalidFile,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			migrations, err := Load(tt.fsys)
+			if !errors.Is(err, tt.wantError) {
+				t.Fatalf("want %v; got %v", tt.wantError, err)
+			}
+			if len(migrations) != len(tt.wantNames) {
+				t.Fatalf("want %d migrations; got %d", len(tt.wantNames), len(migrations))
+			}
+			for i, m := range migrations {
+				if m.Name != tt.wantNames[i] {
+					t.Errorf("want %q; got %q", tt.wantNames[i], m.Name)
+				}
+			}
+		})
+	}
+}
+
+func TestMigrator(t *testing.T) {
+	m := newTestMigrator(t)
+
+	applied, err := m.Up()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{1, 2, 10}; !equal(versions(applied), want) {
+		t.Errorf("want %v applied; got %v", want, versions(applied))
+	}
+
+	// Applying again is a no-op
+	applied, err = m.Up()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != 0 {
+		t.Errorf("want nothing applied; got %v", versions(applied))
+	}
+
+	if _, err := m.DB.Exec("INSERT INTO users (id, name) VALUES (1, 'Alice')"); err != nil {
+		t.Fatal(err)
+	}
+
+	rolledBack, err := m.Down(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{10, 2}; !equal(versions(rolledBack), want) {
+		t.Errorf("want %v rolled back; got %v", want, versions(rolledBack))
+	}
+
+	statuses, err := m.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range statuses {
+		if want := s.Version != 1; s.Pending() != want {
+			t.Errorf("want version %d pending %t; got %t", s.Version, want, s.Pending())
+		}
+	}
+
+	// The table created by the first migration is still there
+	var count int
+	if err := m.DB.QueryRow("SELECT COUNT(*) FROM users").Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Errorf("want 1 user; got %d", count)
+	}
+}
+
+func TestMigratorBaseline(t *testing.T) {
+	m := newTestMigrator(t)
+
+	// The database was created before it was managed by migrations
+	if _, err := m.DB.Exec("CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT)"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Baseline(3); err == nil {
+		t.Error("want error for a version without a migration")
+	}
+	recorded, err := m.Baseline(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{1, 2}; !equal(versions(recorded), want) {
+		t.Errorf("want %v recorded; got %v", want, versions(recorded))
+	}
+
+	applied, err := m.Up()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{10}; !equal(versions(applied), want) {
+		t.Errorf("want %v applied; got %v", want, versions(applied))
+	}
+}
+
+func TestMigratorFailedMigration(t *testing.T) {
+	m := newTestMigrator(t)
+	m.Migrations = append(m.Migrations, Migration{
+		Version: 11,
+		Name:    "broken",
+		Up:      "CREATE TABLE tags (id INTEGER PRIMARY KEY);\nALTER TABLE nowhere ADD COLUMN name TEXT;",
+		Down:    "DROP TABLE tags;",
+	})
+
+	applied, err := m.Up()
+	if err == nil {
+		t.Fatal("want error for the broken migration")
+	}
+	if want := []int{1, 2, 10}; !equal(versions(applied), want) {
+		t.Errorf("want %v applied; got %v", want, versions(applied))
+	}
+
+	// Neither the first statement nor the record of the migration is kept
+	var count int
+	if err := m.DB.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE name = 'tags'").Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 0 {
+		t.Error("want the tags table rolled back")
+	}
+	statuses, err := m.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := statuses[len(statuses)-1]; !s.Pending() {
+		t.Errorf("want version %d pending", s.Version)
+	}
+}
@@ -0,0 +1,206 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"yudhiesh/snippetbox/pkg/models"
+)
+
+type CollectionModel struct {
+	DB *DB
+}
+
+// Returns a copy of a stored collection, without its snippets, along with the
+// name of its owner
+func (db *DB) collection(stored *models.Collection) *models.Collection {
+	c := *stored
+	if u, ok := db.users[c.UserID]; ok {
+		c.Owner = u.Name
+	}
+	return &c
+}
+
+// Returns ids without the given ID, keeping the others in order
+func removeID(ids []int, id int) []int {
+	kept := []int{}
+	for _, other := range ids {
+		if other != id {
+			kept = append(kept, other)
+		}
+	}
+	return kept
+}
+
+// Creates an empty collection and returns its ID
+func (m *CollectionModel) Insert(ctx context.Context, userID int, title, description, visibility string) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, models.ContextError(ctx, err)
+	}
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	// Like the foreign key of the collections table
+	if _, ok := m.DB.users[userID]; !ok {
+		return 0, fmt.Errorf("memory: user %d does not exist", userID)
+	}
+	m.DB.lastCollectionID++
+	id := m.DB.lastCollectionID
+	m.DB.collections[id] = &models.Collection{
+		ID:          id,
+		UserID:      userID,
+		Title:       title,
+		Description: description,
+		Visibility:  visibility,
+		Created:     now(),
+	}
+	return id, nil
+}
+
+// Returns a collection along with its live snippets, in the order they were
+// added
+func (m *CollectionModel) Get(ctx context.Context, id int) (*models.Collection, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, models.ContextError(ctx, err)
+	}
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	stored, ok := m.DB.collections[id]
+	if !ok {
+		return nil, models.ErrNoRecord
+	}
+	c := m.DB.collection(stored)
+	c.Snippets = []*models.Snippet{}
+	t := now()
+	for _, snippetID := range m.DB.collectionSnippets[id] {
+		if s, ok := m.DB.snippets[snippetID]; ok && live(s, t) {
+			c.Snippets = append(c.Snippets, m.DB.snippet(s))
+		}
+	}
+	return c, nil
+}
+
+// Returns the collections owned by a user, most recently created first. Their
+// snippets aren't loaded.
+func (m *CollectionModel) ByUser(ctx context.Context, userID int) ([]*models.Collection, error) {
+	return m.filter(ctx, func(c *models.Collection) bool {
+		return c.UserID == userID
+	})
+}
+
+// Returns the collections owned by a user which hold a snippet, most recently
+// created first. Their snippets aren't loaded.
+func (m *CollectionModel) Containing(ctx context.Context, userID, snippetID int) ([]*models.Collection, error) {
+	return m.filter(ctx, func(c *models.Collection) bool {
+		if c.UserID != userID {
+			return false
+		}
+		for _, id := range m.DB.collectionSnippets[c.ID] {
+			if id == snippetID {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// Returns the collections matching keep, most recently created first. Keep is
+// called with the lock held.
+func (m *CollectionModel) filter(ctx context.Context, keep func(*models.Collection) bool) ([]*models.Collection, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, models.ContextError(ctx, err)
+	}
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	collections := []*models.Collection{}
+	for _, c := range m.DB.collections {
+		if keep(c) {
+			collections = append(collections, m.DB.collection(c))
+		}
+	}
+	// Like ORDER BY c.created DESC, c.id DESC
+	sort.Slice(collections, func(i, j int) bool {
+		a, b := collections[i], collections[j]
+		if !a.Created.Equal(b.Created) {
+			return a.Created.After(b.Created)
+		}
+		return a.ID > b.ID
+	})
+	return collections, nil
+}
+
+// Changes the title, description and visibility of a collection
+func (m *CollectionModel) Update(ctx context.Context, id int, title, description, visibility string) error {
+	if err := ctx.Err(); err != nil {
+		return models.ContextError(ctx, err)
+	}
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	c, ok := m.DB.collections[id]
+	if !ok {
+		return models.ErrNoRecord
+	}
+	c.Title, c.Description, c.Visibility = title, description, visibility
+	return nil
+}
+
+// Deletes a collection. The snippets in it are kept.
+func (m *CollectionModel) Delete(ctx context.Context, id int) error {
+	if err := ctx.Err(); err != nil {
+		return models.ContextError(ctx, err)
+	}
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	if _, ok := m.DB.collections[id]; !ok {
+		return models.ErrNoRecord
+	}
+	delete(m.DB.collections, id)
+	delete(m.DB.collectionSnippets, id)
+	return nil
+}
+
+// Adds a snippet to the end of a collection. It returns ErrInCollection if
+// the snippet is already in it.
+func (m *CollectionModel) AddSnippet(ctx context.Context, id, snippetID int) error {
+	if err := ctx.Err(); err != nil {
+		return models.ContextError(ctx, err)
+	}
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	// Like the foreign keys of the collection_snippets table
+	if _, ok := m.DB.collections[id]; !ok {
+		return fmt.Errorf("memory: collection %d does not exist", id)
+	}
+	if _, ok := m.DB.snippets[snippetID]; !ok {
+		return fmt.Errorf("memory: snippet %d does not exist", snippetID)
+	}
+	for _, other := range m.DB.collectionSnippets[id] {
+		if other == snippetID {
+			return models.ErrInCollection
+		}
+	}
+	m.DB.collectionSnippets[id] = append(m.DB.collectionSnippets[id], snippetID)
+	return nil
+}
+
+// Removes a snippet from a collection
+func (m *CollectionModel) RemoveSnippet(ctx context.Context, id, snippetID int) error {
+	if err := ctx.Err(); err != nil {
+		return models.ContextError(ctx, err)
+	}
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	ids := m.DB.collectionSnippets[id]
+	kept := removeID(ids, snippetID)
+	if len(kept) == len(ids) {
+		return models.ErrNoRecord
+	}
+	m.DB.collectionSnippets[id] = kept
+	return nil
+}
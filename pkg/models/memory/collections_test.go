@@ -0,0 +1,115 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"yudhiesh/snippetbox/pkg/models"
+)
+
+func TestCollectionModel(t *testing.T) {
+	db := newTestDB(t)
+	m := CollectionModel{db}
+	snippets := SnippetModel{db}
+	ctx := context.Background()
+
+	public, err := m.Insert(ctx, 1, "Haiku", "Poems about ponds", "public")
+	if err != nil {
+		t.Fatal(err)
+	}
+	private, err := m.Insert(ctx, 1, "Drafts", "", "private")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var slugs []string
+	var ids []int
+	for _, title := range []string{"An old silent pond", "A frog jumps in", "The sound of water"} {
+		slug, err := snippets.Insert(ctx, 1, title, oneFile(title, ""), nextWeek, "public", false, "", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s, err := snippets.GetBySlug(ctx, slug)
+		if err != nil {
+			t.Fatal(err)
+		}
+		slugs = append(slugs, slug)
+		ids = append(ids, s.ID)
+	}
+
+	// Snippets are kept in the order they were added
+	for _, i := range []int{1, 0, 2} {
+		if err := m.AddSnippet(ctx, public, ids[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := m.AddSnippet(ctx, public, ids[0]); !errors.Is(err, models.ErrInCollection) {
+		t.Errorf("want ErrInCollection adding a snippet twice; got %v", err)
+	}
+
+	// and only the live ones are returned
+	if err := snippets.Delete(ctx, slugs[2], 1); err != nil {
+		t.Fatal(err)
+	}
+	c, err := m.Get(ctx, public)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Title != "Haiku" || c.Description != "Poems about ponds" || c.Visibility != "public" || c.UserID != 1 || c.Owner != "Alice Jones" {
+		t.Errorf("unexpected collection %+v", c)
+	}
+	var got []int
+	for _, s := range c.Snippets {
+		got = append(got, s.ID)
+	}
+	if want := []int{ids[1], ids[0]}; !reflect.DeepEqual(got, want) {
+		t.Errorf("want snippets %v; got %v", want, got)
+	}
+
+	collections, err := m.ByUser(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(collections) != 2 || collections[0].ID != private || collections[1].ID != public {
+		t.Errorf("want the newest collection first; got %d collections", len(collections))
+	}
+	collections, err = m.Containing(ctx, 1, ids[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(collections) != 1 || collections[0].ID != public {
+		t.Errorf("want the snippet in the public collection only; got %d collections", len(collections))
+	}
+
+	if err := m.RemoveSnippet(ctx, public, ids[1]); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.RemoveSnippet(ctx, public, ids[1]); !errors.Is(err, models.ErrNoRecord) {
+		t.Errorf("want ErrNoRecord removing a snippet twice; got %v", err)
+	}
+
+	if err := m.Update(ctx, public, "Haiku", "", "private"); err != nil {
+		t.Fatal(err)
+	}
+	c, err = m.Get(ctx, public)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Visibility != "private" || c.Description != "" || len(c.Snippets) != 1 {
+		t.Errorf("want a private collection with one snippet; got %+v", c)
+	}
+	if err := m.Update(ctx, 99, "Haiku", "", "public"); !errors.Is(err, models.ErrNoRecord) {
+		t.Errorf("want ErrNoRecord updating a missing collection; got %v", err)
+	}
+
+	if err := m.Delete(ctx, public); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Get(ctx, public); !errors.Is(err, models.ErrNoRecord) {
+		t.Errorf("want ErrNoRecord for a deleted collection; got %v", err)
+	}
+	if err := m.Delete(ctx, public); !errors.Is(err, models.ErrNoRecord) {
+		t.Errorf("want ErrNoRecord deleting a collection twice; got %v", err)
+	}
+}
@@ -0,0 +1,79 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"yudhiesh/snippetbox/pkg/models"
+)
+
+type CommentModel struct {
+	DB *DB
+}
+
+// Adds a comment to a snippet and returns its ID. Comments which aren't
+// anchored to any lines have a start and end line of 0.
+func (m *CommentModel) Insert(ctx context.Context, snippetID, userID int, body string, file, startLine, endLine int) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, models.ContextError(ctx, err)
+	}
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	// Like the foreign keys of the comments table
+	if _, ok := m.DB.snippets[snippetID]; !ok {
+		return 0, fmt.Errorf("memory: snippet %d does not exist", snippetID)
+	}
+	if _, ok := m.DB.users[userID]; !ok {
+		return 0, fmt.Errorf("memory: user %d does not exist", userID)
+	}
+	m.DB.lastCommentID++
+	m.DB.comments[snippetID] = append(m.DB.comments[snippetID], &models.Comment{
+		ID:        m.DB.lastCommentID,
+		SnippetID: snippetID,
+		UserID:    userID,
+		Body:      body,
+		File:      file,
+		StartLine: startLine,
+		EndLine:   endLine,
+		Created:   now(),
+	})
+	return m.DB.lastCommentID, nil
+}
+
+// Returns copies of the comments on a snippet with their authors filled in,
+// oldest first
+func (m *CommentModel) BySnippet(ctx context.Context, snippetID int) ([]*models.Comment, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, models.ContextError(ctx, err)
+	}
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	comments := []*models.Comment{}
+	for _, c := range m.DB.comments[snippetID] {
+		comment := *c
+		if u, ok := m.DB.users[c.UserID]; ok {
+			comment.Author = u.Name
+		}
+		comments = append(comments, &comment)
+	}
+	return comments, nil
+}
+
+// Deletes a comment on the given snippet
+func (m *CommentModel) Delete(ctx context.Context, id, snippetID int) error {
+	if err := ctx.Err(); err != nil {
+		return models.ContextError(ctx, err)
+	}
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	comments := m.DB.comments[snippetID]
+	for i, c := range comments {
+		if c.ID == id {
+			m.DB.comments[snippetID] = append(comments[:i:i], comments[i+1:]...)
+			return nil
+		}
+	}
+	return models.ErrNoRecord
+}
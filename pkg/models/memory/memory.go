@@ -0,0 +1,66 @@
+// Package memory implements the snippet, comment, star, view, collection and
+// user models in memory. Nothing is persisted, which makes it useful for
+// development and for tests which need the models to behave like a real
+// database.
+package memory
+
+import (
+	"sync"
+	"time"
+	"yudhiesh/snippetbox/pkg/models"
+)
+
+// DB holds every record of the in-memory backend. It is shared by the models
+// in the same way that a *sql.DB connection pool is shared by the SQL
+// backends, and is safe for concurrent use. Nothing blocks on I/O, so the
+// models only check that the context of a call hasn't already ended before
+// starting it.
+type DB struct {
+	mu sync.RWMutex
+
+	users     map[int]*models.User
+	snippets  map[int]*models.Snippet
+	slugs     map[string]int
+	revisions map[int][]*models.Revision
+	// The bcrypt hashes of the passwords of protected snippets
+	passwords map[int][]byte
+	// The comments on each snippet, oldest first
+	comments map[int][]*models.Comment
+	// The users who starred each snippet, and when they starred it
+	stars map[int]map[int]time.Time
+	// The number of times each snippet has been read
+	views map[int]int
+	// Collections are stored without their snippets, which are kept as the
+	// IDs of the snippets in each collection in the order they were added
+	collections        map[int]*models.Collection
+	collectionSnippets map[int][]int
+
+	lastUserID       int
+	lastSnippetID    int
+	lastRevisionID   int
+	lastCommentID    int
+	lastCollectionID int
+}
+
+// Returns a new empty database
+func New() *DB {
+	return &DB{
+		users:     map[int]*models.User{},
+		snippets:  map[int]*models.Snippet{},
+		slugs:     map[string]int{},
+		revisions: map[int][]*models.Revision{},
+		passwords: map[int][]byte{},
+		comments:  map[int][]*models.Comment{},
+		stars:     map[int]map[int]time.Time{},
+		views:     map[int]int{},
+
+		collections:        map[int]*models.Collection{},
+		collectionSnippets: map[int][]int{},
+	}
+}
+
+// Returns the current time in UTC with the same one second resolution as the
+// DATETIME columns of the SQL backends
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Second)
+}
@@ -0,0 +1,570 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"yudhiesh/snippetbox/pkg/models"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+type SnippetModel struct {
+	DB *DB
+}
+
+// Returns a copy of a stored snippet with the author filled in, so that
+// callers can't change the stored snippet. Like the listings of the SQL
+// backends the copy has no files. The caller must hold the lock.
+func (db *DB) snippet(s *models.Snippet) *models.Snippet {
+	c := *s
+	c.Tags = append([]string{}, s.Tags...)
+	c.Files = nil
+	if u, ok := db.users[s.UserID]; ok {
+		c.Author = u.Name
+	}
+	return &c
+}
+
+// Reports whether a snippet has expired by the given time. Snippets without an
+// expiry time never do.
+func expired(s *models.Snippet, t time.Time) bool {
+	return !s.Expires.IsZero() && !s.Expires.After(t)
+}
+
+// Reports whether a snippet can be viewed at the given time
+func live(s *models.Snippet, t time.Time) bool {
+	return s.Deleted.IsZero() && !expired(s, t)
+}
+
+// Reports whether a snippet appears in the public listings at the given time.
+// Burn-after-reading and password-protected snippets never do, so that their
+// content can't be searched for.
+func listed(s *models.Snippet, t time.Time) bool {
+	return live(s, t) && s.Visibility == models.VisibilityPublic && !s.BurnAfterReading && !s.Protected
+}
+
+// Sorts snippets newest first, using the ID to break ties like the SQL
+// backends' ORDER BY created DESC, id DESC
+func sortNewestFirst(snippets []*models.Snippet) {
+	sort.Slice(snippets, func(i, j int) bool {
+		if !snippets[i].Created.Equal(snippets[j].Created) {
+			return snippets[i].Created.After(snippets[j].Created)
+		}
+		return snippets[i].ID > snippets[j].ID
+	})
+}
+
+// Returns the copies of the stored snippets for which keep returns true,
+// newest first. The caller must hold the lock.
+func (db *DB) filter(keep func(s *models.Snippet) bool) []*models.Snippet {
+	snippets := []*models.Snippet{}
+	for _, s := range db.snippets {
+		if keep(s) {
+			snippets = append(snippets, db.snippet(s))
+		}
+	}
+	sortNewestFirst(snippets)
+	return snippets
+}
+
+// Returns copies of the files of a snippet
+func copyFiles(files []*models.File) []*models.File {
+	c := make([]*models.File, len(files))
+	for i, f := range files {
+		file := *f
+		c[i] = &file
+	}
+	return c
+}
+
+// Inserts a new snippet owned by the given user which expires at the given
+// time, or never if it is zero, and returns the random slug it was given. The
+// content and language of the first file are also those of the snippet.
+func (m *SnippetModel) Insert(ctx context.Context, userID int, title string, files []*models.File, expires time.Time, visibility string, burn bool, password string, tags []string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", models.ContextError(ctx, err)
+	}
+	return m.insert(0, userID, title, files, expires, visibility, burn, password, tags)
+}
+
+// Inserts a snippet like Insert, recording the ID of the snippet it was forked
+// from unless that is 0
+func (m *SnippetModel) insert(forkedFrom, userID int, title string, files []*models.File, expires time.Time, visibility string, burn bool, password string, tags []string) (string, error) {
+	if len(files) == 0 {
+		return "", models.ErrNoFiles
+	}
+	slug, err := models.NewSlug()
+	if err != nil {
+		return "", err
+	}
+	var hashedPassword []byte
+	if password != "" {
+		hashedPassword, err = bcrypt.GenerateFromPassword([]byte(password), 12)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	if _, ok := m.DB.users[userID]; !ok {
+		return "", fmt.Errorf("memory: user %d does not exist", userID)
+	}
+
+	created := now()
+	m.DB.lastSnippetID++
+	s := &models.Snippet{
+		ID:               m.DB.lastSnippetID,
+		Slug:             slug,
+		Visibility:       visibility,
+		BurnAfterReading: burn,
+		Protected:        hashedPassword != nil,
+		UserID:           userID,
+		ForkedFrom:       forkedFrom,
+		Title:            title,
+		Content:          files[0].Content,
+		Language:         files[0].Language,
+		Files:            copyFiles(files),
+		Tags:             append([]string{}, tags...),
+		Created:          created,
+		Expires:          expires.UTC().Truncate(time.Second),
+	}
+	sort.Strings(s.Tags)
+	m.DB.snippets[s.ID] = s
+	m.DB.slugs[slug] = s.ID
+	if hashedPassword != nil {
+		m.DB.passwords[s.ID] = hashedPassword
+	}
+	m.DB.addRevision(s.ID, title, files, created)
+	return slug, nil
+}
+
+// Appends the next revision to a snippet's history. The caller must hold the
+// write lock.
+func (db *DB) addRevision(snippetID int, title string, files []*models.File, created time.Time) {
+	db.lastRevisionID++
+	db.revisions[snippetID] = append(db.revisions[snippetID], &models.Revision{
+		ID:        db.lastRevisionID,
+		SnippetID: snippetID,
+		Version:   len(db.revisions[snippetID]) + 1,
+		Title:     title,
+		Content:   files[0].Content,
+		Files:     copyFiles(files),
+		Created:   created,
+	})
+}
+
+// Return a single snippet by its ID. ErrDeleted, ErrExpired and ErrBurned are
+// returned for snippets which exist but can no longer be viewed, along with
+// the snippet without its files so that the caller can tell who may know of
+// it.
+func (m *SnippetModel) Get(ctx context.Context, id int) (*models.Snippet, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, models.ContextError(ctx, err)
+	}
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	return m.DB.get(id)
+}
+
+// Return a single snippet by its slug, like Get
+func (m *SnippetModel) GetBySlug(ctx context.Context, slug string) (*models.Snippet, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, models.ContextError(ctx, err)
+	}
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	id, ok := m.DB.slugs[slug]
+	if !ok {
+		return nil, models.ErrNoRecord
+	}
+	return m.DB.get(id)
+}
+
+// Returns a copy of the snippet with the given ID, whatever its visibility.
+// The caller must hold the lock.
+func (db *DB) get(id int) (*models.Snippet, error) {
+	s, ok := db.snippets[id]
+	switch {
+	case !ok:
+		return nil, models.ErrNoRecord
+	case !s.Burned.IsZero():
+		return db.snippet(s), models.ErrBurned
+	case !s.Deleted.IsZero():
+		return db.snippet(s), models.ErrDeleted
+	case expired(s, now()):
+		return db.snippet(s), models.ErrExpired
+	}
+	c := db.snippet(s)
+	c.Files = copyFiles(s.Files)
+	return c, nil
+}
+
+// Returns a burn-after-reading snippet and burns it, so that it can only be
+// read once. The title, content, history, tags and files are dropped, leaving
+// a tombstone which makes later reads return ErrBurned. ErrNoRecord is
+// returned for snippets which aren't burn after reading.
+func (m *SnippetModel) Burn(ctx context.Context, slug string) (*models.Snippet, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, models.ContextError(ctx, err)
+	}
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	id, ok := m.DB.slugs[slug]
+	if !ok {
+		return nil, models.ErrNoRecord
+	}
+	c, err := m.DB.get(id)
+	if err != nil {
+		return nil, err
+	}
+	if !c.BurnAfterReading {
+		return nil, models.ErrNoRecord
+	}
+
+	// Like the SQL backends the tombstone counts as deleted, which keeps it
+	// out of every listing
+	s := m.DB.snippets[id]
+	s.Title = ""
+	s.Content = ""
+	s.Tags = []string{}
+	s.Files = nil
+	s.Burned = now()
+	s.Deleted = s.Burned
+	delete(m.DB.revisions, id)
+	return c, nil
+}
+
+// Checks the password of a protected snippet, returning ErrInvalidCredentials
+// if it is wrong. Snippets without a password are always unlocked.
+func (m *SnippetModel) Unlock(ctx context.Context, id int, password string) error {
+	if err := ctx.Err(); err != nil {
+		return models.ContextError(ctx, err)
+	}
+	m.DB.mu.RLock()
+	_, ok := m.DB.snippets[id]
+	hashedPassword := m.DB.passwords[id]
+	m.DB.mu.RUnlock()
+
+	if !ok {
+		return models.ErrNoRecord
+	}
+	if hashedPassword == nil {
+		return nil
+	}
+	// The comparison is slow, so it is made without holding the lock
+	err := bcrypt.CompareHashAndPassword(hashedPassword, []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return models.ErrInvalidCredentials
+	}
+	return err
+}
+
+// Returns a page of at most limit live public snippets, newest first. When before is
+// given only snippets older than that cursor are returned, and when after is
+// given only snippets newer than it.
+func (m *SnippetModel) Latest(ctx context.Context, before, after *models.Cursor, limit int) ([]*models.Snippet, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, models.ContextError(ctx, err)
+	}
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	t := now()
+	snippets := m.DB.filter(func(s *models.Snippet) bool {
+		if !listed(s, t) {
+			return false
+		}
+		switch {
+		case before != nil:
+			return s.Created.Before(before.Created) || (s.Created.Equal(before.Created) && s.ID < before.ID)
+		case after != nil:
+			return s.Created.After(after.Created) || (s.Created.Equal(after.Created) && s.ID > after.ID)
+		}
+		return true
+	})
+
+	// When paging backwards keep the snippets closest to the cursor, which
+	// are at the end of the newest first list
+	if len(snippets) > limit {
+		if after != nil {
+			snippets = snippets[len(snippets)-limit:]
+		} else {
+			snippets = snippets[:limit]
+		}
+	}
+	return snippets, nil
+}
+
+// Returns at most limit live public snippets containing any of the words in
+// the query, ranked by how many times the words occur in the title and
+// content
+func (m *SnippetModel) Search(ctx context.Context, query string, limit int) ([]*models.Snippet, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, models.ContextError(ctx, err)
+	}
+	words := strings.Fields(strings.ToLower(query))
+	if len(words) == 0 {
+		return []*models.Snippet{}, nil
+	}
+
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	t := now()
+	scores := map[int]int{}
+	snippets := m.DB.filter(func(s *models.Snippet) bool {
+		if !listed(s, t) {
+			return false
+		}
+		text := strings.ToLower(s.Title + "\n" + s.Content)
+		for _, word := range words {
+			scores[s.ID] += strings.Count(text, word)
+		}
+		return scores[s.ID] > 0
+	})
+
+	// The stable sort keeps snippets with the same score newest first
+	sort.SliceStable(snippets, func(i, j int) bool {
+		return scores[snippets[i].ID] > scores[snippets[j].ID]
+	})
+	if len(snippets) > limit {
+		snippets = snippets[:limit]
+	}
+	return snippets, nil
+}
+
+// Returns at most limit live public snippets with the given tag, newest
+// first
+func (m *SnippetModel) ByTag(ctx context.Context, tag string, limit int) ([]*models.Snippet, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, models.ContextError(ctx, err)
+	}
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	t := now()
+	snippets := m.DB.filter(func(s *models.Snippet) bool {
+		if !listed(s, t) {
+			return false
+		}
+		for _, name := range s.Tags {
+			if name == tag {
+				return true
+			}
+		}
+		return false
+	})
+	if len(snippets) > limit {
+		snippets = snippets[:limit]
+	}
+	return snippets, nil
+}
+
+// Returns every snippet created by the given user, newest first. Expired
+// snippets are included but deleted snippets are not.
+func (m *SnippetModel) ByUser(ctx context.Context, userID int) ([]*models.Snippet, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, models.ContextError(ctx, err)
+	}
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	return m.DB.filter(func(s *models.Snippet) bool {
+		return s.UserID == userID && s.Deleted.IsZero()
+	}), nil
+}
+
+// Copies a live snippet into a new one owned by the given user which expires
+// at the given time, or never if it is zero, and returns the slug of the copy.
+// The copy keeps the title, files, tags and visibility of the snippet but not
+// its password. Burn-after-reading snippets can't be forked.
+func (m *SnippetModel) Fork(ctx context.Context, id, userID int, expires time.Time) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", models.ContextError(ctx, err)
+	}
+	m.DB.mu.RLock()
+	s, err := m.DB.get(id)
+	m.DB.mu.RUnlock()
+	if err != nil {
+		return "", err
+	}
+	if s.BurnAfterReading {
+		return "", models.ErrNoRecord
+	}
+	return m.insert(s.ID, userID, s.Title, s.Files, expires, s.Visibility, false, "", s.Tags)
+}
+
+// Returns at most limit live public snippets forked from the given snippet,
+// newest first
+func (m *SnippetModel) Forks(ctx context.Context, id, limit int) ([]*models.Snippet, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, models.ContextError(ctx, err)
+	}
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	t := now()
+	snippets := m.DB.filter(func(s *models.Snippet) bool {
+		return s.ForkedFrom == id && listed(s, t)
+	})
+	if len(snippets) > limit {
+		snippets = snippets[:limit]
+	}
+	return snippets, nil
+}
+
+// Replaces the title, visibility and files of a live snippet owned by the
+// given user and stores the new version as the next revision in its history
+func (m *SnippetModel) Update(ctx context.Context, id, userID int, title, visibility string, files []*models.File) error {
+	if err := ctx.Err(); err != nil {
+		return models.ContextError(ctx, err)
+	}
+	if len(files) == 0 {
+		return models.ErrNoFiles
+	}
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	s, ok := m.DB.snippets[id]
+	if !ok || s.UserID != userID || !live(s, now()) {
+		return models.ErrNoRecord
+	}
+	s.Title = title
+	s.Content = files[0].Content
+	s.Language = files[0].Language
+	s.Files = copyFiles(files)
+	s.Visibility = visibility
+	m.DB.addRevision(id, title, files, now())
+	return nil
+}
+
+// Moves the expiry time of a snippet owned by the given user, or removes it if
+// expires is zero. Expired snippets can be renewed until they are purged, but
+// deleted snippets can't.
+func (m *SnippetModel) Renew(ctx context.Context, slug string, userID int, expires time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return models.ContextError(ctx, err)
+	}
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	s, ok := m.DB.snippets[m.DB.slugs[slug]]
+	if !ok || s.UserID != userID || !s.Deleted.IsZero() {
+		return models.ErrNoRecord
+	}
+	s.Expires = expires.UTC().Truncate(time.Second)
+	return nil
+}
+
+// Returns every stored revision of a snippet along with its files, newest
+// first
+func (m *SnippetModel) Revisions(ctx context.Context, id int) ([]*models.Revision, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, models.ContextError(ctx, err)
+	}
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	stored := m.DB.revisions[id]
+	revisions := make([]*models.Revision, 0, len(stored))
+	for i := len(stored) - 1; i >= 0; i-- {
+		rev := *stored[i]
+		rev.Files = copyFiles(rev.Files)
+		revisions = append(revisions, &rev)
+	}
+	return revisions, nil
+}
+
+// Soft-deletes a snippet owned by the given user
+func (m *SnippetModel) Delete(ctx context.Context, slug string, userID int) error {
+	if err := ctx.Err(); err != nil {
+		return models.ContextError(ctx, err)
+	}
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	s, ok := m.DB.snippets[m.DB.slugs[slug]]
+	if !ok || s.UserID != userID || !s.Deleted.IsZero() {
+		return models.ErrNoRecord
+	}
+	s.Deleted = now()
+	return nil
+}
+
+// Restores a snippet owned by the given user which was deleted less than
+// window ago. Burned snippets are gone for good.
+func (m *SnippetModel) Restore(ctx context.Context, slug string, userID int, window time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return models.ContextError(ctx, err)
+	}
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	s, ok := m.DB.snippets[m.DB.slugs[slug]]
+	if !ok || s.UserID != userID || !s.Deleted.After(now().Add(-window)) || !s.Burned.IsZero() {
+		return models.ErrNoRecord
+	}
+	s.Deleted = time.Time{}
+	return nil
+}
+
+// Returns the snippets of the given user which were deleted less than window
+// ago, most recently deleted first
+func (m *SnippetModel) Deleted(ctx context.Context, userID int, window time.Duration) ([]*models.Snippet, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, models.ContextError(ctx, err)
+	}
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	cutoff := now().Add(-window)
+	snippets := m.DB.filter(func(s *models.Snippet) bool {
+		return s.UserID == userID && s.Deleted.After(cutoff) && s.Burned.IsZero()
+	})
+	sort.SliceStable(snippets, func(i, j int) bool {
+		return snippets[i].Deleted.After(snippets[j].Deleted)
+	})
+	return snippets, nil
+}
+
+// Permanently deletes up to limit expired snippets, along with their history,
+// and returns how many were deleted
+func (m *SnippetModel) PurgeExpired(ctx context.Context, limit int) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, models.ContextError(ctx, err)
+	}
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	t := now()
+	n := 0
+	for id, s := range m.DB.snippets {
+		if n == limit {
+			break
+		}
+		if !expired(s, t) {
+			continue
+		}
+		delete(m.DB.snippets, id)
+		delete(m.DB.slugs, s.Slug)
+		delete(m.DB.revisions, id)
+		delete(m.DB.passwords, id)
+		delete(m.DB.comments, id)
+		delete(m.DB.stars, id)
+		delete(m.DB.views, id)
+		for cid, ids := range m.DB.collectionSnippets {
+			m.DB.collectionSnippets[cid] = removeID(ids, id)
+		}
+		n++
+	}
+	return n, nil
+}
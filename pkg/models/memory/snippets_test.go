@@ -0,0 +1,630 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+	"yudhiesh/snippetbox/pkg/models"
+)
+
+func TestSnippetModelGet(t *testing.T) {
+	m := SnippetModel{newTestDB(t)}
+	ctx := context.Background()
+
+	slug, err := m.Insert(ctx, 1, "An old silent pond", oneFile("An old silent pond...", "plaintext"), nextWeek, "public", false, "", []string{"haiku", "basho"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	deletedSlug, err := m.Insert(ctx, 1, "Deleted", oneFile("Deleted", ""), nextWeek, "public", false, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = m.Delete(ctx, deletedSlug, 1); err != nil {
+		t.Fatal(err)
+	}
+	expiredSlug, err := m.Insert(ctx, 1, "Expired", oneFile("Expired", ""), yesterday, "public", false, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		slug      string
+		wantTitle string
+		wantTags  []string
+		wantError error
+	}{
+		{"Valid slug", slug, "An old silent pond", []string{"basho", "haiku"}, nil},
+		{"Deleted slug", deletedSlug, "", nil, models.ErrDeleted},
+		{"Expired slug", expiredSlug, "", nil, models.ErrExpired},
+		{"Non-existent slug", "nonexistentslug", "", nil, models.ErrNoRecord},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := m.GetBySlug(ctx, tt.slug)
+			if !errors.Is(err, tt.wantError) {
+				t.Fatalf("want %v; got %v", tt.wantError, err)
+			}
+			if err != nil {
+				return
+			}
+			if s.Title != tt.wantTitle {
+				t.Errorf("want %q; got %q", tt.wantTitle, s.Title)
+			}
+			if s.Author != "Alice Jones" {
+				t.Errorf("want %q; got %q", "Alice Jones", s.Author)
+			}
+			if s.Language != "plaintext" {
+				t.Errorf("want %q; got %q", "plaintext", s.Language)
+			}
+			if !reflect.DeepEqual(s.Tags, tt.wantTags) {
+				t.Errorf("want %v; got %v", tt.wantTags, s.Tags)
+			}
+		})
+	}
+}
+
+func TestSnippetModelLatest(t *testing.T) {
+	m := SnippetModel{newTestDB(t)}
+	ctx := context.Background()
+
+	// Insert five snippets created in the same second, so that the ID has to
+	// break the ties between them
+	for i := 0; i < 5; i++ {
+		if _, err := m.Insert(ctx, 1, "Snippet", oneFile("Content", ""), nextWeek, "public", false, "", nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	first, err := m.Latest(ctx, nil, nil, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	older, err := m.Latest(ctx, models.CursorFor(first[1]), nil, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newer, err := m.Latest(ctx, nil, models.CursorFor(older[0]), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		snippets []*models.Snippet
+		wantIDs  []int
+	}{
+		{"First page", first, []int{5, 4}},
+		{"Older page", older, []int{3, 2}},
+		{"Newer page", newer, []int{5, 4}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ids := []int{}
+			for _, s := range tt.snippets {
+				ids = append(ids, s.ID)
+			}
+			if !reflect.DeepEqual(ids, tt.wantIDs) {
+				t.Errorf("want %v; got %v", tt.wantIDs, ids)
+			}
+		})
+	}
+}
+
+func TestSnippetModelSearch(t *testing.T) {
+	m := SnippetModel{newTestDB(t)}
+	ctx := context.Background()
+
+	if _, err := m.Insert(ctx, 1, "An old silent pond", oneFile("A frog jumps into the pond", ""), nextWeek, "public", false, "", nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Insert(ctx, 1, "Over the wintry forest", oneFile("Winds howl in rage", ""), nextWeek, "public", false, "", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		query     string
+		wantCount int
+	}{
+		{"Title", "silent", 1},
+		{"Content", "frog", 1},
+		{"Either word", "frog winds", 2},
+		{"Special characters", `"frog* OR`, 1},
+		{"No match", "cherry", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			snippets, err := m.Search(ctx, tt.query, 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(snippets) != tt.wantCount {
+				t.Errorf("want %d; got %d", tt.wantCount, len(snippets))
+			}
+		})
+	}
+}
+
+func TestSnippetModelUpdate(t *testing.T) {
+	m := SnippetModel{newTestDB(t)}
+	ctx := context.Background()
+
+	slug, err := m.Insert(ctx, 1, "An old silent pond", oneFile("An old silent pond...", ""), nextWeek, "public", false, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := m.GetBySlug(ctx, slug)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = m.Update(ctx, s.ID, 1, "An old pond", models.VisibilityUnlisted, oneFile("A frog jumps in", "")); err != nil {
+		t.Fatal(err)
+	}
+	if err = m.Update(ctx, s.ID, 2, "Not mine", models.VisibilityPublic, oneFile("Not mine", "")); !errors.Is(err, models.ErrNoRecord) {
+		t.Errorf("want %v; got %v", models.ErrNoRecord, err)
+	}
+
+	revisions, err := m.Revisions(ctx, s.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revisions) != 2 {
+		t.Fatalf("want %d revisions; got %d", 2, len(revisions))
+	}
+	if revisions[0].Version != 2 || revisions[0].Title != "An old pond" {
+		t.Errorf("want version 2 titled %q; got version %d titled %q", "An old pond", revisions[0].Version, revisions[0].Title)
+	}
+}
+
+func TestSnippetModelRestore(t *testing.T) {
+	m := SnippetModel{newTestDB(t)}
+	ctx := context.Background()
+
+	slug, err := m.Insert(ctx, 1, "An old silent pond", oneFile("An old silent pond...", ""), nextWeek, "public", false, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = m.Delete(ctx, slug, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	deleted, err := m.Deleted(ctx, 1, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deleted) != 1 || deleted[0].Deleted.IsZero() {
+		t.Fatalf("want one deleted snippet; got %v", deleted)
+	}
+	if err = m.Restore(ctx, slug, 1, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = m.GetBySlug(ctx, slug); err != nil {
+		t.Errorf("want restored snippet; got %v", err)
+	}
+}
+
+func TestSnippetModelPurgeExpired(t *testing.T) {
+	m := SnippetModel{newTestDB(t)}
+	ctx := context.Background()
+
+	live, err := m.Insert(ctx, 1, "An old silent pond", oneFile("An old silent pond...", ""), nextWeek, "public", false, "", []string{"haiku"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err = m.Insert(ctx, 1, "Over the wintry forest", oneFile("Over the wintry forest...", ""), yesterday, "public", false, "", []string{"haiku"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Purging in batches of two takes two rounds
+	for _, want := range []int{2, 1, 0} {
+		n, err := m.PurgeExpired(ctx, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != want {
+			t.Errorf("want %d purged; got %d", want, n)
+		}
+	}
+
+	if _, err = m.GetBySlug(ctx, live); err != nil {
+		t.Errorf("want live snippet kept; got %v", err)
+	}
+	snippets, err := m.ByTag(ctx, "haiku", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snippets) != 1 {
+		t.Errorf("want 1 tagged snippet; got %d", len(snippets))
+	}
+}
+
+func TestSnippetModelVisibility(t *testing.T) {
+	m := SnippetModel{newTestDB(t)}
+	ctx := context.Background()
+
+	slugs := map[string]string{}
+	for _, visibility := range []string{models.VisibilityPublic, models.VisibilityUnlisted, models.VisibilityPrivate} {
+		slug, err := m.Insert(ctx, 1, "A frog jumps in", oneFile("A frog jumps in...", ""), nextWeek, visibility, false, "", []string{"haiku"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(slug) != models.SlugLength {
+			t.Errorf("want slug of length %d; got %q", models.SlugLength, slug)
+		}
+		slugs[visibility] = slug
+	}
+
+	for visibility, slug := range slugs {
+		s, err := m.GetBySlug(ctx, slug)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.Visibility != visibility {
+			t.Errorf("want visibility %q; got %q", visibility, s.Visibility)
+		}
+	}
+
+	latest, err := m.Latest(ctx, nil, nil, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found, err := m.Search(ctx, "frog", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tagged, err := m.ByTag(ctx, "haiku", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, listing := range [][]*models.Snippet{latest, found, tagged} {
+		if len(listing) != 1 || listing[0].Slug != slugs[models.VisibilityPublic] {
+			t.Errorf("want only the public snippet listed; got %v", listing)
+		}
+	}
+}
+
+func TestSnippetModelBurn(t *testing.T) {
+	m := SnippetModel{newTestDB(t)}
+	ctx := context.Background()
+
+	slug, err := m.Insert(ctx, 1, "Database password", oneFile("hunter2", ""), nextWeek, "public", true, "", []string{"secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain, err := m.Insert(ctx, 1, "An old silent pond", oneFile("An old silent pond...", ""), nextWeek, "public", false, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Burn-after-reading snippets are never listed
+	latest, err := m.Latest(ctx, nil, nil, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(latest) != 1 || latest[0].Slug != plain {
+		t.Errorf("want only the plain snippet listed; got %v", latest)
+	}
+
+	s, err := m.Burn(ctx, slug)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Content != "hunter2" || !reflect.DeepEqual(s.Tags, []string{"secret"}) {
+		t.Errorf("want the content and tags of the snippet; got %q and %v", s.Content, s.Tags)
+	}
+	if len(s.Files) != 1 || s.Files[0].Content != "hunter2" {
+		t.Errorf("want the files of the snippet; got %v", s.Files)
+	}
+
+	tests := []struct {
+		name      string
+		call      func() error
+		wantError error
+	}{
+		{"Burn again", func() error { _, err := m.Burn(ctx, slug); return err }, models.ErrBurned},
+		{"Read again", func() error { _, err := m.GetBySlug(ctx, slug); return err }, models.ErrBurned},
+		{"Restore", func() error { return m.Restore(ctx, slug, 1, time.Hour) }, models.ErrNoRecord},
+		{"Not burn after reading", func() error { _, err := m.Burn(ctx, plain); return err }, models.ErrNoRecord},
+		{"Non-existent slug", func() error { _, err := m.Burn(ctx, "nonexistentslug"); return err }, models.ErrNoRecord},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.call(); !errors.Is(err, tt.wantError) {
+				t.Errorf("want %v; got %v", tt.wantError, err)
+			}
+		})
+	}
+
+	// Nothing of the content is left behind
+	revisions, err := m.Revisions(ctx, s.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revisions) != 0 {
+		t.Errorf("want no revisions; got %d", len(revisions))
+	}
+	deleted, err := m.Deleted(ctx, 1, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deleted) != 0 {
+		t.Errorf("want no restorable snippets; got %d", len(deleted))
+	}
+}
+
+func TestSnippetModelUnlock(t *testing.T) {
+	m := SnippetModel{newTestDB(t)}
+	ctx := context.Background()
+
+	slug, err := m.Insert(ctx, 1, "An old silent pond", oneFile("An old silent pond...", ""), nextWeek, "public", false, "open sesame", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := m.GetBySlug(ctx, slug)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.Protected {
+		t.Error("want snippet to be protected")
+	}
+	plain, err := m.Insert(ctx, 1, "Over the wintry forest", oneFile("Winds howl in rage", ""), nextWeek, "public", false, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := m.GetBySlug(ctx, plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		snippetID int
+		password  string
+		wantError error
+	}{
+		{"Right password", s.ID, "open sesame", nil},
+		{"Wrong password", s.ID, "open sesame!", models.ErrInvalidCredentials},
+		{"Empty password", s.ID, "", models.ErrInvalidCredentials},
+		{"No password", p.ID, "anything", nil},
+		{"Non-existent ID", 42, "open sesame", models.ErrNoRecord},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := m.Unlock(ctx, tt.snippetID, tt.password); !errors.Is(err, tt.wantError) {
+				t.Errorf("want %v; got %v", tt.wantError, err)
+			}
+		})
+	}
+
+	// Protected snippets are never listed
+	latest, err := m.Latest(ctx, nil, nil, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(latest) != 1 || latest[0].Slug != plain {
+		t.Errorf("want only the unprotected snippet listed; got %v", latest)
+	}
+}
+
+func TestSnippetModelExpiry(t *testing.T) {
+	m := SnippetModel{newTestDB(t)}
+	ctx := context.Background()
+
+	// Snippets which never expire are live and listed, and aren't purged
+	slug, err := m.Insert(ctx, 1, "An old silent pond", oneFile("An old silent pond...", ""), time.Time{}, "public", false, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := m.GetBySlug(ctx, slug)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.Expires.IsZero() {
+		t.Errorf("want no expiry time; got %v", s.Expires)
+	}
+	latest, err := m.Latest(ctx, nil, nil, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(latest) == 0 || latest[0].Slug != slug {
+		t.Errorf("want the snippet listed first; got %v", latest)
+	}
+	if _, err = m.PurgeExpired(ctx, 10); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = m.GetBySlug(ctx, slug); err != nil {
+		t.Errorf("want snippet kept; got %v", err)
+	}
+
+	// Renewing brings an expired snippet back, and can remove its expiry
+	expired, err := m.Insert(ctx, 1, "Over the wintry forest", oneFile("Winds howl in rage", ""), yesterday, "public", false, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = m.Renew(ctx, expired, 2, nextWeek); !errors.Is(err, models.ErrNoRecord) {
+		t.Errorf("want ErrNoRecord renewing somebody else's snippet; got %v", err)
+	}
+	if err = m.Renew(ctx, expired, 1, nextWeek); err != nil {
+		t.Fatal(err)
+	}
+	s, err = m.GetBySlug(ctx, expired)
+	if err != nil {
+		t.Fatalf("want renewed snippet; got %v", err)
+	}
+	if !s.Expires.Equal(nextWeek.UTC().Truncate(time.Second)) {
+		t.Errorf("want expiry %v; got %v", nextWeek, s.Expires)
+	}
+	// Renewing to the same time again still finds the snippet
+	if err = m.Renew(ctx, expired, 1, nextWeek); err != nil {
+		t.Errorf("want renewal to the same time; got %v", err)
+	}
+	if err = m.Renew(ctx, expired, 1, time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	if s, err = m.GetBySlug(ctx, expired); err != nil || !s.Expires.IsZero() {
+		t.Errorf("want snippet which never expires; got %v, %v", s, err)
+	}
+
+	// Deleted snippets can't be renewed
+	if err = m.Delete(ctx, expired, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err = m.Renew(ctx, expired, 1, nextWeek); !errors.Is(err, models.ErrNoRecord) {
+		t.Errorf("want ErrNoRecord renewing a deleted snippet; got %v", err)
+	}
+}
+
+func TestSnippetModelFiles(t *testing.T) {
+	m := SnippetModel{newTestDB(t)}
+	ctx := context.Background()
+
+	files := []*models.File{
+		{Name: "Dockerfile", Language: "docker", Content: "FROM golang:1.16"},
+		{Name: "main.go", Language: "go", Content: "package main"},
+	}
+	slug, err := m.Insert(ctx, 1, "Hello service", files, nextWeek, "public", false, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := m.GetBySlug(ctx, slug)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(s.Files, files) {
+		t.Errorf("want the files in order; got %v", s.Files)
+	}
+	// The first file is also the content of the snippet
+	if s.Content != "FROM golang:1.16" || s.Language != "docker" {
+		t.Errorf("want the content of the first file; got %q in %q", s.Content, s.Language)
+	}
+
+	// Listings don't load the files
+	latest, err := m.Latest(ctx, nil, nil, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(latest) != 1 || latest[0].Slug != slug || latest[0].Files != nil {
+		t.Errorf("want the snippet listed without its files; got %v", latest)
+	}
+
+	// Editing the snippet replaces all of its files and keeps them in its
+	// history
+	edited := []*models.File{
+		{Name: "Dockerfile", Language: "docker", Content: "FROM golang:1.17"},
+		{Name: "server.go", Language: "go", Content: "package server"},
+		{Name: "README.md", Language: "markdown", Content: "# Hello"},
+	}
+	err = m.Update(ctx, s.ID, 1, "Hello service", "public", edited)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err = m.GetBySlug(ctx, slug)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Files) != 3 || s.Files[1].Name != "server.go" || s.Files[1].Content != "package server" || s.Files[2].Name != "README.md" {
+		t.Errorf("want every file edited; got %v", s.Files)
+	}
+	revisions, err := m.Revisions(ctx, s.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revisions) != 2 || len(revisions[0].Files) != 3 || len(revisions[1].Files) != 2 {
+		t.Fatalf("want revisions with 3 and 2 files; got %v", revisions)
+	}
+	if f := revisions[1].Files[1]; f.Name != "main.go" || f.Content != "package main" {
+		t.Errorf("want the first revision to keep main.go; got %+v", f)
+	}
+	if err = m.Update(ctx, s.ID, 1, "Hello service", "public", nil); !errors.Is(err, models.ErrNoFiles) {
+		t.Errorf("want %v; got %v", models.ErrNoFiles, err)
+	}
+
+	_, err = m.Insert(ctx, 1, "Empty", nil, nextWeek, "public", false, "", nil)
+	if !errors.Is(err, models.ErrNoFiles) {
+		t.Errorf("want %v; got %v", models.ErrNoFiles, err)
+	}
+}
+
+func TestSnippetModelFork(t *testing.T) {
+	m := SnippetModel{newTestDB(t)}
+	ctx := context.Background()
+
+	files := []*models.File{
+		{Name: "Dockerfile", Language: "docker", Content: "FROM golang:1.16"},
+		{Name: "main.go", Language: "go", Content: "package main"},
+	}
+	parent, err := m.Insert(ctx, 1, "Hello service", files, nextWeek, "public", false, "open sesame", []string{"docker", "go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := m.GetBySlug(ctx, parent)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	slug, err := m.Fork(ctx, p.ID, 1, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := m.GetBySlug(ctx, slug)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.ForkedFrom != p.ID || s.Title != p.Title || s.Visibility != p.Visibility || !s.Expires.IsZero() {
+		t.Errorf("want a copy of snippet %d which never expires; got %+v", p.ID, s)
+	}
+	if !reflect.DeepEqual(s.Files, files) || !reflect.DeepEqual(s.Tags, []string{"docker", "go"}) {
+		t.Errorf("want the files and tags copied; got %v and %v", s.Files, s.Tags)
+	}
+	// The password isn't copied, so the fork can be listed
+	if s.Protected {
+		t.Error("want the fork unprotected")
+	}
+
+	// Forks of forks only list under their own parent
+	if _, err = m.Fork(ctx, s.ID, 1, nextWeek); err != nil {
+		t.Fatal(err)
+	}
+	forks, err := m.Forks(ctx, p.ID, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(forks) != 1 || forks[0].Slug != slug {
+		t.Errorf("want only the fork listed; got %v", forks)
+	}
+
+	burn, err := m.Insert(ctx, 1, "Database password", oneFile("hunter2", ""), nextWeek, "public", true, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := m.GetBySlug(ctx, burn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = m.Fork(ctx, b.ID, 1, nextWeek); !errors.Is(err, models.ErrNoRecord) {
+		t.Errorf("burn after reading: want %v; got %v", models.ErrNoRecord, err)
+	}
+	if err = m.Delete(ctx, parent, 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = m.Fork(ctx, p.ID, 1, nextWeek); !errors.Is(err, models.ErrDeleted) {
+		t.Errorf("deleted: want %v; got %v", models.ErrDeleted, err)
+	}
+}
+
+func TestSnippetModelCanceled(t *testing.T) {
+	m := SnippetModel{newTestDB(t)}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := m.Get(ctx, 1)
+	if !errors.Is(err, models.ErrCanceled) {
+		t.Errorf("want %v; got %v", models.ErrCanceled, err)
+	}
+}
@@ -0,0 +1,98 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+	"yudhiesh/snippetbox/pkg/models"
+)
+
+type StarModel struct {
+	DB *DB
+}
+
+// Stars a snippet for a user, or removes the star if the user had already
+// starred it, and reports whether the snippet is now starred
+func (m *StarModel) Toggle(ctx context.Context, snippetID, userID int) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, models.ContextError(ctx, err)
+	}
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	if _, ok := m.DB.stars[snippetID][userID]; ok {
+		delete(m.DB.stars[snippetID], userID)
+		return false, nil
+	}
+	// Like the foreign keys of the stars table
+	if _, ok := m.DB.snippets[snippetID]; !ok {
+		return false, fmt.Errorf("memory: snippet %d does not exist", snippetID)
+	}
+	if _, ok := m.DB.users[userID]; !ok {
+		return false, fmt.Errorf("memory: user %d does not exist", userID)
+	}
+	if m.DB.stars[snippetID] == nil {
+		m.DB.stars[snippetID] = map[int]time.Time{}
+	}
+	m.DB.stars[snippetID][userID] = now()
+	return true, nil
+}
+
+// Reports whether a user has starred a snippet
+func (m *StarModel) IsStarred(ctx context.Context, snippetID, userID int) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, models.ContextError(ctx, err)
+	}
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	_, ok := m.DB.stars[snippetID][userID]
+	return ok, nil
+}
+
+// Fills in the Stars field of each snippet
+func (m *StarModel) Count(ctx context.Context, snippets ...*models.Snippet) error {
+	if err := ctx.Err(); err != nil {
+		return models.ContextError(ctx, err)
+	}
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	for _, s := range snippets {
+		s.Stars = len(m.DB.stars[s.ID])
+	}
+	return nil
+}
+
+// Returns the live snippets starred by a user which the user can still view,
+// most recently starred first. Burn-after-reading snippets can't be starred.
+func (m *StarModel) ByUser(ctx context.Context, userID int) ([]*models.Snippet, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, models.ContextError(ctx, err)
+	}
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	t := now()
+	snippets := []*models.Snippet{}
+	starred := map[int]time.Time{}
+	for id, users := range m.DB.stars {
+		s, ok := m.DB.snippets[id]
+		created, starredBy := users[userID]
+		if !ok || !starredBy || !live(s, t) || s.BurnAfterReading || !s.VisibleTo(userID) {
+			continue
+		}
+		snippets = append(snippets, m.DB.snippet(s))
+		starred[id] = created
+	}
+	// Like ORDER BY st.created DESC, st.snippet_id DESC
+	sort.Slice(snippets, func(i, j int) bool {
+		a, b := starred[snippets[i].ID], starred[snippets[j].ID]
+		if !a.Equal(b) {
+			return a.After(b)
+		}
+		return snippets[i].ID > snippets[j].ID
+	})
+	return snippets, nil
+}
@@ -0,0 +1,97 @@
+package memory
+
+import (
+	"context"
+	"testing"
+	"yudhiesh/snippetbox/pkg/models"
+)
+
+func TestStarModel(t *testing.T) {
+	db := newTestDB(t)
+	m := StarModel{db}
+	snippets := SnippetModel{db}
+	ctx := context.Background()
+
+	var ids []int
+	for _, visibility := range []string{"public", "private"} {
+		slug, err := snippets.Insert(ctx, 1, "An old silent pond", oneFile("A frog jumps in", ""), nextWeek, visibility, false, "", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s, err := snippets.GetBySlug(ctx, slug)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, s.ID)
+	}
+
+	for _, id := range ids {
+		starred, err := m.Toggle(ctx, id, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !starred {
+			t.Errorf("want snippet %d starred", id)
+		}
+	}
+	starred, err := m.IsStarred(ctx, ids[0], 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !starred {
+		t.Error("want IsStarred true")
+	}
+
+	// The owner's private snippet is listed too, after the newer star
+	list, err := m.ByUser(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].ID != ids[1] || list[1].ID != ids[0] {
+		t.Fatalf("want snippets %v, most recently starred first; got %v", ids, list)
+	}
+	if list[0].Author != "Alice Jones" || list[0].Tags == nil {
+		t.Errorf("want the author and tags loaded; got %+v", list[0])
+	}
+
+	snippet := &models.Snippet{ID: ids[0]}
+	other := &models.Snippet{ID: ids[0] + 100, Stars: 5}
+	if err = m.Count(ctx, snippet, other); err != nil {
+		t.Fatal(err)
+	}
+	if snippet.Stars != 1 || other.Stars != 0 {
+		t.Errorf("want 1 and 0 stars; got %d and %d", snippet.Stars, other.Stars)
+	}
+
+	// Toggling again removes the star
+	starred, err = m.Toggle(ctx, ids[0], 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if starred {
+		t.Error("want the star removed")
+	}
+	list, err = m.ByUser(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].ID != ids[1] {
+		t.Errorf("want only snippet %d starred; got %v", ids[1], list)
+	}
+
+	// Deleted snippets aren't listed
+	s, err := snippets.Get(ctx, ids[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = snippets.Delete(ctx, s.Slug, 1); err != nil {
+		t.Fatal(err)
+	}
+	list, err = m.ByUser(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 0 {
+		t.Errorf("want no snippets listed; got %v", list)
+	}
+}
@@ -0,0 +1,34 @@
+package memory
+
+import (
+	"testing"
+	"time"
+	"yudhiesh/snippetbox/pkg/models"
+)
+
+// Creates a new database holding the same test data as the SQL backends'
+// testdata/setup.sql scripts
+func newTestDB(t *testing.T) *DB {
+	db := New()
+	db.lastUserID = 1
+	db.users[1] = &models.User{
+		ID:             1,
+		Name:           "Alice Jones",
+		Email:          "alice@example.com",
+		HashedPassword: []byte("$2a$12$NuTjWXm3KKntReFwyBVHyuf/to.HEwTy.eS206TNfkGfr6HzGJSWG"),
+		Created:        time.Date(2018, 12, 23, 17, 25, 22, 0, time.UTC),
+		Active:         true,
+	}
+	return db
+}
+
+// Expiry times for snippets inserted by the tests
+var (
+	nextWeek  = time.Now().AddDate(0, 0, 7)
+	yesterday = time.Now().AddDate(0, 0, -1)
+)
+
+// Returns the files of a snippet holding a single file
+func oneFile(content, language string) []*models.File {
+	return []*models.File{{Name: "file1", Language: language, Content: content}}
+}
@@ -0,0 +1,128 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"yudhiesh/snippetbox/pkg/models"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+type UserModel struct {
+	DB *DB
+}
+
+// Insert a user. Email addresses are compared case-insensitively, like the
+// unique index on the users table of the SQL backends.
+func (m *UserModel) Insert(ctx context.Context, name, email, password string) error {
+	if err := ctx.Err(); err != nil {
+		return models.ContextError(ctx, err)
+	}
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), 12)
+	if err != nil {
+		return err
+	}
+
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	for _, u := range m.DB.users {
+		if strings.EqualFold(u.Email, email) {
+			return models.ErrDuplicateEmail
+		}
+	}
+	m.DB.lastUserID++
+	m.DB.users[m.DB.lastUserID] = &models.User{
+		ID:             m.DB.lastUserID,
+		Name:           name,
+		Email:          email,
+		HashedPassword: hashedPassword,
+		Created:        now(),
+		Active:         true,
+	}
+	return nil
+}
+
+// Authenticate the users email and password
+func (m *UserModel) Authenticate(ctx context.Context, email, password string) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, models.ContextError(ctx, err)
+	}
+	// The user is copied so that the hash can be checked without the lock
+	// while the password is changed
+	m.DB.mu.RLock()
+	var found *models.User
+	for _, u := range m.DB.users {
+		if strings.EqualFold(u.Email, email) && u.Active {
+			c := *u
+			found = &c
+			break
+		}
+	}
+	m.DB.mu.RUnlock()
+
+	if found == nil {
+		return 0, models.ErrInvalidCredentials
+	}
+	err := bcrypt.CompareHashAndPassword(found.HashedPassword, []byte(password))
+	if err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return 0, models.ErrInvalidCredentials
+		}
+		return 0, err
+	}
+	return found.ID, nil
+}
+
+func (m *UserModel) Get(ctx context.Context, id int) (*models.User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, models.ContextError(ctx, err)
+	}
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	u, ok := m.DB.users[id]
+	if !ok {
+		return nil, models.ErrNoRecord
+	}
+	// Return a copy so that callers can't change the stored user. The hash of
+	// the password is left out, as it is by the SQL backends.
+	c := *u
+	c.HashedPassword = nil
+	return &c, nil
+}
+
+func (m *UserModel) ChangePassword(ctx context.Context, id int, currentPassword, newPassword string) error {
+	if err := ctx.Err(); err != nil {
+		return models.ContextError(ctx, err)
+	}
+	m.DB.mu.RLock()
+	u, ok := m.DB.users[id]
+	var currentHashedPassword []byte
+	if ok {
+		currentHashedPassword = u.HashedPassword
+	}
+	m.DB.mu.RUnlock()
+
+	if !ok {
+		return models.ErrNoRecord
+	}
+	err := bcrypt.CompareHashAndPassword(currentHashedPassword, []byte(currentPassword))
+	if err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return models.ErrInvalidCredentials
+		}
+		return err
+	}
+
+	newHashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), 12)
+	if err != nil {
+		return err
+	}
+
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+	u.HashedPassword = newHashedPassword
+	return nil
+}
@@ -0,0 +1,24 @@
+package memory
+
+import (
+	"context"
+	"testing"
+	"yudhiesh/snippetbox/pkg/models"
+	"yudhiesh/snippetbox/pkg/models/modeltest"
+)
+
+func TestUserModel(t *testing.T) {
+	modeltest.TestUsers(t, func(t *testing.T) (modeltest.Users, func()) {
+		return &UserModel{newTestDB(t)}, func() {}
+	})
+}
+
+func TestUserModelInsertCase(t *testing.T) {
+	m := UserModel{newTestDB(t)}
+
+	// Like the case-insensitive collation of the MySQL backend
+	err := m.Insert(context.Background(), "Bob", "Alice@Example.com", "validPa$$word")
+	if err != models.ErrDuplicateEmail {
+		t.Errorf("want %v; got %v", models.ErrDuplicateEmail, err)
+	}
+}
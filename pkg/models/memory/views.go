@@ -0,0 +1,41 @@
+package memory
+
+import (
+	"context"
+	"yudhiesh/snippetbox/pkg/models"
+)
+
+type ViewModel struct {
+	DB *DB
+}
+
+// Adds to the view counts of snippets, given by snippet ID. Snippets which
+// have since been purged are skipped.
+func (m *ViewModel) Add(ctx context.Context, views map[int]int) error {
+	if err := ctx.Err(); err != nil {
+		return models.ContextError(ctx, err)
+	}
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	for id, n := range views {
+		if _, ok := m.DB.snippets[id]; ok {
+			m.DB.views[id] += n
+		}
+	}
+	return nil
+}
+
+// Fills in the Views field of each snippet
+func (m *ViewModel) Count(ctx context.Context, snippets ...*models.Snippet) error {
+	if err := ctx.Err(); err != nil {
+		return models.ContextError(ctx, err)
+	}
+	m.DB.mu.RLock()
+	defer m.DB.mu.RUnlock()
+
+	for _, s := range snippets {
+		s.Views = m.DB.views[s.ID]
+	}
+	return nil
+}
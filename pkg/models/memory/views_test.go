@@ -0,0 +1,42 @@
+package memory
+
+import (
+	"context"
+	"testing"
+	"yudhiesh/snippetbox/pkg/models"
+)
+
+func TestViewModel(t *testing.T) {
+	db := newTestDB(t)
+	m := ViewModel{db}
+	snippets := SnippetModel{db}
+	ctx := context.Background()
+
+	var list []*models.Snippet
+	for i := 0; i < 2; i++ {
+		slug, err := snippets.Insert(ctx, 1, "An old silent pond", oneFile("A frog jumps in", ""), nextWeek, "public", false, "", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s, err := snippets.GetBySlug(ctx, slug)
+		if err != nil {
+			t.Fatal(err)
+		}
+		list = append(list, s)
+	}
+
+	// Batches add up, and views of snippets which no longer exist are
+	// dropped
+	if err := m.Add(ctx, map[int]int{list[0].ID: 3, list[1].ID: 1, 9999: 5}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Add(ctx, map[int]int{list[0].ID: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Count(ctx, list...); err != nil {
+		t.Fatal(err)
+	}
+	if list[0].Views != 5 || list[1].Views != 1 {
+		t.Errorf("want 5 and 1 views; got %d and %d", list[0].Views, list[1].Views)
+	}
+}
@@ -0,0 +1,95 @@
+package mock
+
+import (
+	"context"
+	"time"
+	"yudhiesh/snippetbox/pkg/models"
+)
+
+// The mock user's public collection, which holds a private snippet as well as
+// a public one
+var mockCollection = &models.Collection{
+	ID:          1,
+	UserID:      1,
+	Owner:       "Alice",
+	Title:       "Haiku",
+	Description: "Poems about ponds",
+	Visibility:  models.VisibilityPublic,
+	Created:     time.Now(),
+}
+
+// A private collection of another user
+var mockPrivateCollection = &models.Collection{
+	ID:          2,
+	UserID:      2,
+	Owner:       "Bob",
+	Title:       "Drafts",
+	Description: "",
+	Visibility:  models.VisibilityPrivate,
+	Created:     time.Now(),
+}
+
+type CollectionModel struct{}
+
+func (m *CollectionModel) Insert(ctx context.Context, userID int, title, description, visibility string) (int, error) {
+	return 3, nil
+}
+
+func (m *CollectionModel) Get(ctx context.Context, id int) (*models.Collection, error) {
+	switch id {
+	case mockCollection.ID:
+		c := *mockCollection
+		c.Snippets = []*models.Snippet{mockSnippet, mockPrivateSnippet}
+		return &c, nil
+	case mockPrivateCollection.ID:
+		c := *mockPrivateCollection
+		c.Snippets = []*models.Snippet{}
+		return &c, nil
+	default:
+		return nil, models.ErrNoRecord
+	}
+}
+
+func (m *CollectionModel) ByUser(ctx context.Context, userID int) ([]*models.Collection, error) {
+	switch userID {
+	case mockCollection.UserID:
+		return []*models.Collection{mockCollection}, nil
+	default:
+		return []*models.Collection{}, nil
+	}
+}
+
+func (m *CollectionModel) Containing(ctx context.Context, userID, snippetID int) ([]*models.Collection, error) {
+	if userID == mockCollection.UserID && (snippetID == mockSnippet.ID || snippetID == mockPrivateSnippet.ID) {
+		return []*models.Collection{mockCollection}, nil
+	}
+	return []*models.Collection{}, nil
+}
+
+func (m *CollectionModel) Update(ctx context.Context, id int, title, description, visibility string) error {
+	if id == mockCollection.ID || id == mockPrivateCollection.ID {
+		return nil
+	}
+	return models.ErrNoRecord
+}
+
+func (m *CollectionModel) Delete(ctx context.Context, id int) error {
+	if id == mockCollection.ID || id == mockPrivateCollection.ID {
+		return nil
+	}
+	return models.ErrNoRecord
+}
+
+func (m *CollectionModel) AddSnippet(ctx context.Context, id, snippetID int) error {
+	if id == mockCollection.ID && (snippetID == mockSnippet.ID || snippetID == mockPrivateSnippet.ID) {
+		return models.ErrInCollection
+	}
+	return nil
+}
+
+func (m *CollectionModel) RemoveSnippet(ctx context.Context, id, snippetID int) error {
+	if id == mockCollection.ID && (snippetID == mockSnippet.ID || snippetID == mockPrivateSnippet.ID) {
+		return nil
+	}
+	return models.ErrNoRecord
+}
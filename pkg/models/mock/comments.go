@@ -0,0 +1,49 @@
+package mock
+
+import (
+	"context"
+	"time"
+	"yudhiesh/snippetbox/pkg/models"
+)
+
+var mockComment = &models.Comment{
+	ID:        1,
+	SnippetID: 1,
+	UserID:    2,
+	Author:    "Bob",
+	Body:      "A lovely haiku",
+	Created:   time.Now(),
+}
+
+var mockLineComment = &models.Comment{
+	ID:        2,
+	SnippetID: 1,
+	UserID:    1,
+	Author:    "Alice",
+	Body:      "Which pond?",
+	StartLine: 1,
+	EndLine:   1,
+	Created:   time.Now(),
+}
+
+type CommentModel struct{}
+
+func (m *CommentModel) Insert(ctx context.Context, snippetID, userID int, body string, file, startLine, endLine int) (int, error) {
+	return 3, nil
+}
+
+func (m *CommentModel) BySnippet(ctx context.Context, snippetID int) ([]*models.Comment, error) {
+	switch snippetID {
+	case 1:
+		return []*models.Comment{mockComment, mockLineComment}, nil
+	default:
+		return []*models.Comment{}, nil
+	}
+}
+
+func (m *CommentModel) Delete(ctx context.Context, id, snippetID int) error {
+	if snippetID == 1 && (id == mockComment.ID || id == mockLineComment.ID) {
+		return nil
+	}
+	return models.ErrNoRecord
+}
@@ -1,33 +1,281 @@
 package mock
 
 import (
+	"context"
+	"strings"
 	"time"
 	"yudhiesh/snippetbox/pkg/models"
 )
 
 var mockSnippet = &models.Snippet{
-	ID:      1,
-	Title:   "An old silent pond",
-	Content: "An old silent pond...",
+	ID:         1,
+	Slug:       "pond7kq2mzx4wbna",
+	Visibility: models.VisibilityPublic,
+	UserID:     1,
+	Author:     "Alice",
+	Title:      "An old silent pond",
+	Content:    "An old silent pond...",
+	Files:      []*models.File{{Name: "file1", Content: "An old silent pond..."}},
+	Tags:       []string{"haiku"},
+	Created:    time.Now(),
+	Expires:    time.Now(),
+}
+
+var mockPrivateSnippet = &models.Snippet{
+	ID:         6,
+	Slug:       "frog3hd5ncq2wyta",
+	Visibility: models.VisibilityPrivate,
+	UserID:     1,
+	Author:     "Alice",
+	Title:      "A frog jumps in",
+	Content:    "A frog jumps in...",
+	Files:      []*models.File{{Name: "file1", Content: "A frog jumps in..."}},
+	Tags:       []string{},
+	Created:    time.Now(),
+	Expires:    time.Now(),
+}
+
+var mockBurnSnippet = &models.Snippet{
+	ID:               7,
+	Slug:             "burn5tk3qv7dmxza",
+	Visibility:       models.VisibilityUnlisted,
+	BurnAfterReading: true,
+	UserID:           1,
+	Author:           "Alice",
+	Title:            "Database password",
+	Content:          "hunter2",
+	Files:            []*models.File{{Name: "file1", Content: "hunter2"}},
+	Tags:             []string{},
+	Created:          time.Now(),
+	Expires:          time.Now(),
+}
+
+var mockProtectedSnippet = &models.Snippet{
+	ID:         8,
+	Slug:       "lock4wq7hn2cpzre",
+	Visibility: models.VisibilityUnlisted,
+	Protected:  true,
+	UserID:     1,
+	Author:     "Alice",
+	Title:      "Behind a password",
+	Content:    "The pond is frozen",
+	Files:      []*models.File{{Name: "file1", Content: "The pond is frozen"}},
+	Tags:       []string{},
+	Created:    time.Now(),
+	Expires:    time.Now(),
+}
+
+var mockFilesSnippet = &models.Snippet{
+	ID:         9,
+	Slug:       "many3fq7kd2hwpxa",
+	Visibility: models.VisibilityPublic,
+	UserID:     1,
+	ForkedFrom: 1,
+	Author:     "Alice",
+	Title:      "Hello service",
+	Content:    "FROM golang:1.16\n",
+	Language:   "docker",
+	Files: []*models.File{
+		{Name: "Dockerfile", Language: "docker", Content: "FROM golang:1.16\n"},
+		{Name: "main.go", Language: "go", Content: "package main\n"},
+	},
+	Tags:    []string{},
 	Created: time.Now(),
 	Expires: time.Now(),
 }
 
+// The password which unlocks mockProtectedSnippet
+const mockSnippetPassword = "open sesame"
+
+// The slug of a deleted snippet which can be restored
+const mockDeletedSlug = "gone4ma7pqz2xkrb"
+
+// The deleted snippet of mockDeletedSlug, as returned along with ErrDeleted
+var mockDeletedSnippet = &models.Snippet{
+	ID:         3,
+	Slug:       mockDeletedSlug,
+	Visibility: models.VisibilityPublic,
+	UserID:     1,
+	Author:     "Alice",
+}
+
+// A deleted private snippet, whose tombstone only its owner may see
+var mockDeletedPrivateSnippet = &models.Snippet{
+	ID:         10,
+	Slug:       "hush6vb3kqm8zdte",
+	Visibility: models.VisibilityPrivate,
+	UserID:     1,
+	Author:     "Alice",
+}
+
+// The slug of a snippet which has already been burned
+const mockBurnedSlug = "ash2fz6rwkq4nbpe"
+
+var mockRevision = &models.Revision{
+	ID:        1,
+	SnippetID: 1,
+	Version:   1,
+	Title:     "An old silent pond",
+	Content:   "An old silent pond...",
+	Files:     []*models.File{{Name: "file1", Content: "An old silent pond..."}},
+	Created:   time.Now(),
+}
+
 type SnippetModel struct{}
 
-func (m *SnippetModel) Insert(title, content, expires string) (int, error) {
-	return 2, nil
+func (m *SnippetModel) Insert(ctx context.Context, userID int, title string, files []*models.File, expires time.Time, visibility string, burn bool, password string, tags []string) (string, error) {
+	return "new2snippetslugx", nil
+}
+
+func (m *SnippetModel) Fork(ctx context.Context, id, userID int, expires time.Time) (string, error) {
+	switch id {
+	case mockSnippet.ID, mockPrivateSnippet.ID, mockProtectedSnippet.ID, mockFilesSnippet.ID:
+		return "fork2snippetslug", nil
+	default:
+		return "", models.ErrNoRecord
+	}
+}
+
+func (m *SnippetModel) Forks(ctx context.Context, id, limit int) ([]*models.Snippet, error) {
+	switch id {
+	case mockSnippet.ID:
+		return []*models.Snippet{mockFilesSnippet}, nil
+	default:
+		return []*models.Snippet{}, nil
+	}
 }
 
-func (m *SnippetModel) Get(id int) (*models.Snippet, error) {
+func (m *SnippetModel) Get(ctx context.Context, id int) (*models.Snippet, error) {
 	switch id {
 	case 1:
 		return mockSnippet, nil
+	case 3:
+		return mockDeletedSnippet, models.ErrDeleted
+	case 4:
+		return nil, models.ErrExpired
+	case 5:
+		return nil, models.ErrCanceled
+	case 6:
+		return mockPrivateSnippet, nil
+	default:
+		return nil, models.ErrNoRecord
+	}
+}
+
+func (m *SnippetModel) GetBySlug(ctx context.Context, slug string) (*models.Snippet, error) {
+	switch slug {
+	case mockSnippet.Slug:
+		return mockSnippet, nil
+	case mockPrivateSnippet.Slug:
+		return mockPrivateSnippet, nil
+	case mockBurnSnippet.Slug:
+		return mockBurnSnippet, nil
+	case mockProtectedSnippet.Slug:
+		return mockProtectedSnippet, nil
+	case mockFilesSnippet.Slug:
+		return mockFilesSnippet, nil
+	case mockDeletedSlug:
+		return mockDeletedSnippet, models.ErrDeleted
+	case mockDeletedPrivateSnippet.Slug:
+		return mockDeletedPrivateSnippet, models.ErrDeleted
+	case mockBurnedSlug:
+		return nil, models.ErrBurned
+	default:
+		return nil, models.ErrNoRecord
+	}
+}
+
+func (m *SnippetModel) Unlock(ctx context.Context, id int, password string) error {
+	if id == mockProtectedSnippet.ID && password != mockSnippetPassword {
+		return models.ErrInvalidCredentials
+	}
+	return nil
+}
+
+func (m *SnippetModel) Burn(ctx context.Context, slug string) (*models.Snippet, error) {
+	switch slug {
+	case mockBurnSnippet.Slug:
+		return mockBurnSnippet, nil
+	case mockBurnedSlug:
+		return nil, models.ErrBurned
 	default:
 		return nil, models.ErrNoRecord
 	}
 }
 
-func (m *SnippetModel) Latest() ([]*models.Snippet, error) {
+func (m *SnippetModel) Latest(ctx context.Context, before, after *models.Cursor, limit int) ([]*models.Snippet, error) {
+	if before != nil || after != nil {
+		return []*models.Snippet{}, nil
+	}
 	return []*models.Snippet{mockSnippet}, nil
 }
+
+func (m *SnippetModel) ByUser(ctx context.Context, userID int) ([]*models.Snippet, error) {
+	switch userID {
+	case 1:
+		return []*models.Snippet{mockSnippet}, nil
+	default:
+		return []*models.Snippet{}, nil
+	}
+}
+
+func (m *SnippetModel) Update(ctx context.Context, id, userID int, title, visibility string, files []*models.File) error {
+	if id != 1 || userID != 1 {
+		return models.ErrNoRecord
+	}
+	return nil
+}
+
+func (m *SnippetModel) Renew(ctx context.Context, slug string, userID int, expires time.Time) error {
+	if slug != mockSnippet.Slug || userID != 1 {
+		return models.ErrNoRecord
+	}
+	return nil
+}
+
+func (m *SnippetModel) Revisions(ctx context.Context, id int) ([]*models.Revision, error) {
+	switch id {
+	case 1:
+		return []*models.Revision{mockRevision}, nil
+	default:
+		return []*models.Revision{}, nil
+	}
+}
+
+func (m *SnippetModel) Delete(ctx context.Context, slug string, userID int) error {
+	if slug != mockSnippet.Slug || userID != 1 {
+		return models.ErrNoRecord
+	}
+	return nil
+}
+
+func (m *SnippetModel) Restore(ctx context.Context, slug string, userID int, window time.Duration) error {
+	if slug != mockDeletedSlug || userID != 1 {
+		return models.ErrNoRecord
+	}
+	return nil
+}
+
+func (m *SnippetModel) Deleted(ctx context.Context, userID int, window time.Duration) ([]*models.Snippet, error) {
+	return []*models.Snippet{}, nil
+}
+
+func (m *SnippetModel) Search(ctx context.Context, query string, limit int) ([]*models.Snippet, error) {
+	if strings.Contains(strings.ToLower(mockSnippet.Content), strings.ToLower(query)) {
+		return []*models.Snippet{mockSnippet}, nil
+	}
+	return []*models.Snippet{}, nil
+}
+
+func (m *SnippetModel) ByTag(ctx context.Context, tag string, limit int) ([]*models.Snippet, error) {
+	switch tag {
+	case "haiku":
+		return []*models.Snippet{mockSnippet}, nil
+	default:
+		return []*models.Snippet{}, nil
+	}
+}
+
+func (m *SnippetModel) PurgeExpired(ctx context.Context, limit int) (int, error) {
+	return 0, nil
+}
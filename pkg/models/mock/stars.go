@@ -0,0 +1,38 @@
+package mock
+
+import (
+	"context"
+	"yudhiesh/snippetbox/pkg/models"
+)
+
+type StarModel struct{}
+
+// The mock user has starred the mock snippet, which one other user has too
+func (m *StarModel) Toggle(ctx context.Context, snippetID, userID int) (bool, error) {
+	return snippetID != mockSnippet.ID || userID != mockUser.ID, nil
+}
+
+func (m *StarModel) IsStarred(ctx context.Context, snippetID, userID int) (bool, error) {
+	return snippetID == mockSnippet.ID && userID == mockUser.ID, nil
+}
+
+func (m *StarModel) Count(ctx context.Context, snippets ...*models.Snippet) error {
+	for _, s := range snippets {
+		switch s.ID {
+		case mockSnippet.ID:
+			s.Stars = 2
+		default:
+			s.Stars = 0
+		}
+	}
+	return nil
+}
+
+func (m *StarModel) ByUser(ctx context.Context, userID int) ([]*models.Snippet, error) {
+	switch userID {
+	case mockUser.ID:
+		return []*models.Snippet{mockSnippet}, nil
+	default:
+		return []*models.Snippet{}, nil
+	}
+}
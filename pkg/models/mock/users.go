@@ -1,6 +1,7 @@
 package mock
 
 import (
+	"context"
 	"time"
 	"yudhiesh/snippetbox/pkg/models"
 )
@@ -15,7 +16,7 @@ var mockUser = &models.User{
 
 type UserModel struct{}
 
-func (m *UserModel) Insert(name, email, password string) error {
+func (m *UserModel) Insert(ctx context.Context, name, email, password string) error {
 	switch email {
 	case "dupe@example.com":
 		return models.ErrDuplicateEmail
@@ -24,7 +25,7 @@ func (m *UserModel) Insert(name, email, password string) error {
 	}
 }
 
-func (m *UserModel) Authenticate(email, password string) (int, error) {
+func (m *UserModel) Authenticate(ctx context.Context, email, password string) (int, error) {
 	switch email {
 	case "alice@example.com":
 		return 1, nil
@@ -33,7 +34,7 @@ func (m *UserModel) Authenticate(email, password string) (int, error) {
 	}
 }
 
-func (m *UserModel) Get(id int) (*models.User, error) {
+func (m *UserModel) Get(ctx context.Context, id int) (*models.User, error) {
 	switch id {
 	case 1:
 		return mockUser, nil
@@ -42,6 +43,6 @@ func (m *UserModel) Get(id int) (*models.User, error) {
 	}
 }
 
-func (m *UserModel) ChangePassword(int, string, string) error {
+func (m *UserModel) ChangePassword(context.Context, int, string, string) error {
 	return nil
 }
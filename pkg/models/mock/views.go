@@ -0,0 +1,25 @@
+package mock
+
+import (
+	"context"
+	"yudhiesh/snippetbox/pkg/models"
+)
+
+type ViewModel struct{}
+
+func (m *ViewModel) Add(ctx context.Context, views map[int]int) error {
+	return nil
+}
+
+// The mock snippet has been read 42 times
+func (m *ViewModel) Count(ctx context.Context, snippets ...*models.Snippet) error {
+	for _, s := range snippets {
+		switch s.ID {
+		case mockSnippet.ID:
+			s.Views = 42
+		default:
+			s.Views = 0
+		}
+	}
+	return nil
+}
@@ -1,7 +1,13 @@
 package models
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
 	"errors"
+	"fmt"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -9,14 +15,224 @@ var (
 	ErrNoRecord           = errors.New("models: no matching record found!")
 	ErrInvalidCredentials = errors.New("models: invalid credentials")
 	ErrDuplicateEmail     = errors.New("models: duplicate email")
+	ErrDeleted            = errors.New("models: snippet has been deleted")
+	ErrExpired            = errors.New("models: snippet has expired")
+	ErrBurned             = errors.New("models: snippet has been burned")
+	ErrInvalidCursor      = errors.New("models: invalid cursor")
+	ErrNoFiles            = errors.New("models: snippet has no files")
+	ErrInCollection       = errors.New("models: snippet is already in the collection")
+	// ErrCanceled wraps context.Canceled or context.DeadlineExceeded when a
+	// query is abandoned because its context ended
+	ErrCanceled = errors.New("models: query canceled")
 )
 
+// Derives the context for the queries made by a single model method, adding
+// the timeout when it is positive. The returned function must be deferred
+// with a pointer to the method's error result: it releases the context and
+// turns an error caused by the context ending into ErrCanceled.
+func QueryContext(ctx context.Context, timeout time.Duration) (context.Context, func(*error)) {
+	cancel := func() {}
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	}
+	return ctx, func(err *error) {
+		*err = ContextError(ctx, *err)
+		cancel()
+	}
+}
+
+// The errors which are results of a model method rather than failures. They
+// are kept even when found just as the context ended.
+var resultErrors = []error{
+	ErrNoRecord, ErrInvalidCredentials, ErrDuplicateEmail, ErrDeleted, ErrExpired,
+	ErrBurned, ErrInvalidCursor, ErrNoFiles, ErrInCollection,
+}
+
+// Returns ErrCanceled wrapping the reason if err was caused by ctx ending,
+// and err unchanged otherwise
+func ContextError(ctx context.Context, err error) error {
+	if err == nil || errors.Is(err, ErrCanceled) {
+		return err
+	}
+	for _, result := range resultErrors {
+		if errors.Is(err, result) {
+			return err
+		}
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("%w: %v", ErrCanceled, err)
+	}
+	// Drivers don't always return the context's error when it interrupts a
+	// query, the MySQL driver closing the connection instead
+	if ctx.Err() != nil {
+		return fmt.Errorf("%w: %v", ErrCanceled, ctx.Err())
+	}
+	return err
+}
+
 type Snippet struct {
-	ID      int
-	Title   string
+	ID int
+	// Slug is the random identifier used in the snippet's URLs
+	Slug       string
+	Visibility string
+	// BurnAfterReading snippets are burned by the first successful read
+	BurnAfterReading bool
+	// Protected snippets have to be unlocked with their password by anyone
+	// but their owner
+	Protected bool
+	UserID    int
+	// ForkedFrom is the ID of the snippet this one was forked from, or 0 if it
+	// wasn't forked. That snippet may since have been purged.
+	ForkedFrom int
+	Author     string
+	Title      string
+	// Content and Language are those of the snippet's first file
 	Content string
+	// Language is the name of the syntax the content is highlighted with, or
+	// empty for plain text
+	Language string
+	// Files are only loaded along with a single snippet, not in listings
+	Files []*File
+	Tags  []string
+	// Stars is the number of users who starred the snippet. The snippet
+	// models leave it at zero for the star model to count.
+	Stars int
+	// Views is the number of times the snippet has been read, filled in by
+	// the view model like Stars
+	Views   int
 	Created time.Time
 	Expires time.Time
+	// Deleted is the time the owner deleted the snippet, or the zero time if
+	// it has not been deleted
+	Deleted time.Time
+	// Burned is the time a burn-after-reading snippet was read, or the zero
+	// time if it hasn't been
+	Burned time.Time
+}
+
+// A File is one of the named files of a snippet, in the order they were
+// given when the snippet was created
+type File struct {
+	Name string
+	// Language is the name of the syntax the content is highlighted with
+	Language string
+	Content  string
+}
+
+// The visibility of a snippet. Public snippets are listed everywhere, unlisted
+// snippets can only be reached by their slug and private snippets can only be
+// viewed by their owner.
+const (
+	VisibilityPublic   = "public"
+	VisibilityUnlisted = "unlisted"
+	VisibilityPrivate  = "private"
+)
+
+// Reports whether the user with the given ID may view the snippet through its
+// slug. Anonymous visitors have the user ID 0.
+func (s *Snippet) VisibleTo(userID int) bool {
+	return s.Visibility != VisibilityPrivate || s.UserID == userID
+}
+
+// Slugs are 10 random bytes in lowercase base32, which is URL-safe and
+// unaffected by case-insensitive collations
+var slugEncoding = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+
+// The length of the slugs returned by NewSlug
+const SlugLength = 16
+
+// Returns a new random slug for a snippet
+func NewSlug() (string, error) {
+	b := make([]byte, 10)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return slugEncoding.EncodeToString(b), nil
+}
+
+// A Revision is a single stored version of a snippet. Version 1 is the
+// snippet as it was first published and every edit adds the next version.
+type Revision struct {
+	ID        int
+	SnippetID int
+	Version   int
+	Title     string
+	// Content is that of the revision's first file
+	Content string
+	Files   []*File
+	Created time.Time
+}
+
+// A Comment is left on a snippet by a signed in user. It may be anchored to a
+// range of lines of one of the snippet's files.
+type Comment struct {
+	ID        int
+	SnippetID int
+	UserID    int
+	Author    string
+	Body      string
+	// File is the position of the file the comment is anchored to, and
+	// StartLine and EndLine the first and last lines of the range counting
+	// from 1. Comments which aren't anchored have no lines.
+	File      int
+	StartLine int
+	EndLine   int
+	Created   time.Time
+}
+
+// Reports whether the comment is anchored to a range of lines
+func (c *Comment) Anchored() bool {
+	return c.StartLine != 0
+}
+
+// A Collection is a list of snippets put together by a user, in the order
+// they were added. Public collections can be viewed by anyone with the link
+// and private ones only by their owner.
+type Collection struct {
+	ID          int
+	UserID      int
+	Owner       string
+	Title       string
+	Description string
+	Visibility  string
+	// Snippets are only loaded along with a single collection, and are the
+	// live snippets in it whatever their visibility
+	Snippets []*Snippet
+	Created  time.Time
+}
+
+// A Cursor is a position in the listing of snippets ordered by creation time.
+// The ID breaks ties between snippets that were created in the same second.
+type Cursor struct {
+	Created time.Time
+	ID      int
+}
+
+// Returns the cursor pointing at the given snippet
+func CursorFor(s *Snippet) *Cursor {
+	return &Cursor{Created: s.Created, ID: s.ID}
+}
+
+// Encodes the cursor as "<unix seconds>,<id>" for use in a URL
+func (c *Cursor) String() string {
+	return fmt.Sprintf("%d,%d", c.Created.Unix(), c.ID)
+}
+
+// Decodes a cursor previously encoded with String()
+func ParseCursor(s string) (*Cursor, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 2 {
+		return nil, ErrInvalidCursor
+	}
+	created, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	id, err := strconv.Atoi(parts[1])
+	if err != nil || id < 1 {
+		return nil, ErrInvalidCursor
+	}
+	return &Cursor{Created: time.Unix(created, 0).UTC(), ID: id}, nil
 }
 
 type User struct {
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestContextError(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name         string
+		ctx          context.Context
+		err          error
+		wantCanceled bool
+	}{
+		{"Nil", canceled, nil, false},
+		{"Context Error", canceled, context.Canceled, true},
+		{"Wrapped Context Error", context.Background(), fmt.Errorf("query: %w", context.DeadlineExceeded), true},
+		{"Driver Error After Cancel", canceled, driver.ErrBadConn, true},
+		{"Driver Error", context.Background(), driver.ErrBadConn, false},
+		{"No Record After Cancel", canceled, ErrNoRecord, false},
+		{"Invalid Credentials After Cancel", canceled, ErrInvalidCredentials, false},
+		{"Duplicate Email After Cancel", canceled, fmt.Errorf("insert: %w", ErrDuplicateEmail), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ContextError(tt.ctx, tt.err)
+			if got := errors.Is(err, ErrCanceled); got != tt.wantCanceled {
+				t.Errorf("want canceled %v; got %v", tt.wantCanceled, err)
+			}
+			if !tt.wantCanceled && err != tt.err {
+				t.Errorf("want %v unchanged; got %v", tt.err, err)
+			}
+		})
+	}
+}
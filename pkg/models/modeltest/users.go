@@ -0,0 +1,132 @@
+// Package modeltest holds the tests which every storage backend has to pass.
+// Each backend runs them from its own tests with a constructor for its
+// models, so that the backends are held to the same suite.
+package modeltest
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+	"yudhiesh/snippetbox/pkg/models"
+)
+
+// Users is the user model of a backend
+type Users interface {
+	Insert(ctx context.Context, name, email, password string) error
+	Authenticate(ctx context.Context, email, password string) (int, error)
+	Get(ctx context.Context, id int) (*models.User, error)
+}
+
+// Runs the tests of a user model. newUsers returns the model of a new
+// database holding the test data of testdata/setup.sql, along with a
+// function which tears the database down.
+func TestUsers(t *testing.T, newUsers func(t *testing.T) (Users, func())) {
+	t.Run("Get", func(t *testing.T) { testUserModelGet(t, newUsers) })
+	t.Run("Insert", func(t *testing.T) { testUserModelInsert(t, newUsers) })
+	t.Run("Authenticate", func(t *testing.T) { testUserModelAuthenticate(t, newUsers) })
+}
+
+func testUserModelGet(t *testing.T, newUsers func(t *testing.T) (Users, func())) {
+	// Set up a suite of table-driven tests and expected results.
+	tests := []struct {
+		name      string
+		userID    int
+		wantUser  *models.User
+		wantError error
+	}{
+		{
+			name:   "Valid ID",
+			userID: 1,
+			wantUser: &models.User{
+				ID:      1,
+				Name:    "Alice Jones",
+				Email:   "alice@example.com",
+				Created: time.Date(2018, 12, 23, 17, 25, 22, 0, time.UTC),
+				Active:  true,
+			},
+			wantError: nil,
+		},
+		{
+			name:      "Zero ID",
+			userID:    0,
+			wantUser:  nil,
+			wantError: models.ErrNoRecord,
+		},
+		{
+			name:      "Non-existent ID",
+			userID:    2,
+			wantUser:  nil,
+			wantError: models.ErrNoRecord,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, teardown := newUsers(t)
+			defer teardown()
+			ctx := context.Background()
+
+			user, err := m.Get(ctx, tt.userID)
+			if err != tt.wantError {
+				t.Errorf("want %v; got %s", tt.wantError, err)
+			}
+			// Check for equality between arbitrarily complex custom types
+			if !reflect.DeepEqual(user, tt.wantUser) {
+				t.Errorf("want %v; got %v", tt.wantUser, user)
+			}
+		})
+	}
+}
+
+func testUserModelInsert(t *testing.T, newUsers func(t *testing.T) (Users, func())) {
+	tests := []struct {
+		name      string
+		email     string
+		wantError error
+	}{
+		{"New email", "bob@example.com", nil},
+		{"Duplicate email", "alice@example.com", models.ErrDuplicateEmail},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, teardown := newUsers(t)
+			defer teardown()
+			ctx := context.Background()
+
+			err := m.Insert(ctx, "Bob", tt.email, "validPa$$word")
+			if err != tt.wantError {
+				t.Errorf("want %v; got %v", tt.wantError, err)
+			}
+		})
+	}
+}
+
+func testUserModelAuthenticate(t *testing.T, newUsers func(t *testing.T) (Users, func())) {
+	// No one is logged in when the credentials can't be checked
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name      string
+		ctx       context.Context
+		email     string
+		password  string
+		wantError error
+	}{
+		{"Unknown email", context.Background(), "bob@example.com", "validPa$$word", models.ErrInvalidCredentials},
+		{"Wrong password", context.Background(), "alice@example.com", "wrongPa$$word", models.ErrInvalidCredentials},
+		{"Canceled", canceled, "alice@example.com", "validPa$$word", models.ErrCanceled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, teardown := newUsers(t)
+			defer teardown()
+
+			id, err := m.Authenticate(tt.ctx, tt.email, tt.password)
+			if id != 0 || !errors.Is(err, tt.wantError) {
+				t.Errorf("want 0, %v; got %d, %v", tt.wantError, id, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,207 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+	"yudhiesh/snippetbox/pkg/models"
+)
+
+type CollectionModel struct {
+	DB      *sql.DB
+	Timeout time.Duration // as for SnippetModel
+}
+
+// Creates an empty collection and returns its ID
+func (m *CollectionModel) Insert(ctx context.Context, userID int, title, description, visibility string) (_ int, err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	stmt := `INSERT INTO collections (user_id, title, description, visibility, created)
+	VALUES(?, ?, ?, ?, UTC_TIMESTAMP())`
+	result, err := m.DB.ExecContext(ctx, stmt, userID, title, description, visibility)
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return int(id), nil
+}
+
+// Returns a collection along with its live snippets, in the order they were
+// added
+func (m *CollectionModel) Get(ctx context.Context, id int) (_ *models.Collection, err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	c := &models.Collection{}
+	stmt := `SELECT c.id, c.user_id, u.name, c.title, c.description, c.visibility, c.created
+	FROM collections c INNER JOIN users u ON u.id = c.user_id WHERE c.id = ?`
+	err = m.DB.QueryRowContext(ctx, stmt, id).Scan(&c.ID, &c.UserID, &c.Owner, &c.Title, &c.Description, &c.Visibility, &c.Created)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrNoRecord
+		}
+		return nil, err
+	}
+
+	stmt = `SELECT s.id, s.slug, s.visibility, s.burn_after_reading, s.hashed_password IS NOT NULL, s.user_id, COALESCE(s.forked_from, 0), u.name, s.title, s.content, s.language, s.created, s.expires
+	FROM collection_snippets cs INNER JOIN snippets s ON s.id = cs.snippet_id
+	INNER JOIN users u ON u.id = s.user_id
+	WHERE cs.collection_id = ? AND (s.expires IS NULL OR s.expires > UTC_TIMESTAMP()) AND s.deleted IS NULL
+	ORDER BY cs.position`
+	rows, err := m.DB.QueryContext(ctx, stmt, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	c.Snippets = []*models.Snippet{}
+	for rows.Next() {
+		s := &models.Snippet{}
+		err := rows.Scan(&s.ID, &s.Slug, &s.Visibility, &s.BurnAfterReading, &s.Protected, &s.UserID, &s.ForkedFrom, &s.Author, &s.Title, &s.Content, &s.Language, &s.Created, expiryTime{&s.Expires})
+		if err != nil {
+			return nil, err
+		}
+		c.Snippets = append(c.Snippets, s)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	if err = loadTags(ctx, m.DB, c.Snippets); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Returns the collections owned by a user, most recently created first. Their
+// snippets aren't loaded.
+func (m *CollectionModel) ByUser(ctx context.Context, userID int) (_ []*models.Collection, err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	stmt := `SELECT c.id, c.user_id, u.name, c.title, c.description, c.visibility, c.created
+	FROM collections c INNER JOIN users u ON u.id = c.user_id
+	WHERE c.user_id = ? ORDER BY c.created DESC, c.id DESC`
+	return m.query(ctx, stmt, userID)
+}
+
+// Returns the collections owned by a user which hold a snippet, most recently
+// created first. Their snippets aren't loaded.
+func (m *CollectionModel) Containing(ctx context.Context, userID, snippetID int) (_ []*models.Collection, err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	stmt := `SELECT c.id, c.user_id, u.name, c.title, c.description, c.visibility, c.created
+	FROM collections c INNER JOIN users u ON u.id = c.user_id
+	INNER JOIN collection_snippets cs ON cs.collection_id = c.id
+	WHERE c.user_id = ? AND cs.snippet_id = ? ORDER BY c.created DESC, c.id DESC`
+	return m.query(ctx, stmt, userID, snippetID)
+}
+
+// Runs a query selecting the columns of collections without their snippets
+func (m *CollectionModel) query(ctx context.Context, stmt string, args ...interface{}) ([]*models.Collection, error) {
+	rows, err := m.DB.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	collections := []*models.Collection{}
+	for rows.Next() {
+		c := &models.Collection{}
+		err := rows.Scan(&c.ID, &c.UserID, &c.Owner, &c.Title, &c.Description, &c.Visibility, &c.Created)
+		if err != nil {
+			return nil, err
+		}
+		collections = append(collections, c)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return collections, nil
+}
+
+// Changes the title, description and visibility of a collection
+func (m *CollectionModel) Update(ctx context.Context, id int, title, description, visibility string) (err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	// MySQL counts unchanged rows as unaffected, so the row is counted with a
+	// separate query
+	var exists bool
+	err = m.DB.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM collections WHERE id = ?)`, id).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return models.ErrNoRecord
+	}
+	stmt := `UPDATE collections SET title = ?, description = ?, visibility = ? WHERE id = ?`
+	_, err = m.DB.ExecContext(ctx, stmt, title, description, visibility, id)
+	return err
+}
+
+// Deletes a collection. The snippets in it are kept.
+func (m *CollectionModel) Delete(ctx context.Context, id int) (err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	result, err := m.DB.ExecContext(ctx, `DELETE FROM collections WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return models.ErrNoRecord
+	}
+	return nil
+}
+
+// Adds a snippet to the end of a collection. It returns ErrInCollection if
+// the snippet is already in it.
+func (m *CollectionModel) AddSnippet(ctx context.Context, id, snippetID int) (err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	stmt := `INSERT IGNORE INTO collection_snippets (collection_id, snippet_id, position)
+	SELECT ?, ?, COALESCE(MAX(position), 0) + 1 FROM collection_snippets WHERE collection_id = ?`
+	result, err := m.DB.ExecContext(ctx, stmt, id, snippetID, id)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return models.ErrInCollection
+	}
+	return nil
+}
+
+// Removes a snippet from a collection
+func (m *CollectionModel) RemoveSnippet(ctx context.Context, id, snippetID int) (err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	stmt := `DELETE FROM collection_snippets WHERE collection_id = ? AND snippet_id = ?`
+	result, err := m.DB.ExecContext(ctx, stmt, id, snippetID)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return models.ErrNoRecord
+	}
+	return nil
+}
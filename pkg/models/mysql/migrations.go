@@ -0,0 +1,14 @@
+package mysql
+
+import (
+	"embed"
+	"io/fs"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// The versioned schema migrations, embedded into the binary. They are applied
+// with the migrate package over a connection which allows multiple
+// statements.
+var Migrations, _ = fs.Sub(migrations, "migrations")
@@ -0,0 +1,15 @@
+CREATE TABLE snippets (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    user_id INTEGER NOT NULL,
+    title VARCHAR(100) NOT NULL,
+    content TEXT NOT NULL,
+    created DATETIME NOT NULL,
+    expires DATETIME NOT NULL,
+    deleted DATETIME NULL
+);
+
+CREATE INDEX idx_snippets_created ON snippets(created);
+
+CREATE FULLTEXT INDEX idx_snippets_fulltext ON snippets(title, content);
+
+ALTER TABLE snippets ADD CONSTRAINT fk_snippets_user FOREIGN KEY (user_id) REFERENCES users(id);
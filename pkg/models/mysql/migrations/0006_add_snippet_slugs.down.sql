@@ -0,0 +1 @@
+ALTER TABLE snippets DROP INDEX snippets_uc_slug, DROP COLUMN visibility, DROP COLUMN slug;
@@ -0,0 +1,11 @@
+ALTER TABLE snippets
+    ADD COLUMN slug CHAR(16) NULL AFTER id,
+    ADD COLUMN visibility VARCHAR(10) NOT NULL DEFAULT 'public' AFTER slug;
+
+-- Existing snippets stay public and get a random slug of the same length as
+-- the ones generated by the application
+UPDATE snippets SET slug = LOWER(HEX(RANDOM_BYTES(8)));
+
+ALTER TABLE snippets MODIFY slug CHAR(16) NOT NULL;
+
+ALTER TABLE snippets ADD CONSTRAINT snippets_uc_slug UNIQUE (slug);
@@ -0,0 +1 @@
+ALTER TABLE snippets DROP COLUMN burned, DROP COLUMN burn_after_reading;
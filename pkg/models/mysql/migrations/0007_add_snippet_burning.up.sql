@@ -0,0 +1,3 @@
+ALTER TABLE snippets
+    ADD COLUMN burn_after_reading BOOLEAN NOT NULL DEFAULT FALSE AFTER visibility,
+    ADD COLUMN burned DATETIME NULL AFTER deleted;
@@ -0,0 +1,4 @@
+-- Snippets which never expired are kept for another year
+UPDATE snippets SET expires = DATE_ADD(UTC_TIMESTAMP(), INTERVAL 1 YEAR) WHERE expires IS NULL;
+
+ALTER TABLE snippets MODIFY expires DATETIME NOT NULL;
@@ -0,0 +1 @@
+ALTER TABLE snippets MODIFY expires DATETIME NULL;
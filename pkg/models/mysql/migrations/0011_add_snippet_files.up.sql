@@ -0,0 +1,17 @@
+CREATE TABLE snippet_files (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    snippet_id INTEGER NOT NULL,
+    position INTEGER NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    language VARCHAR(32) NOT NULL DEFAULT '',
+    content TEXT NOT NULL
+);
+
+ALTER TABLE snippet_files ADD CONSTRAINT snippet_files_uc_position UNIQUE (snippet_id, position);
+
+ALTER TABLE snippet_files ADD CONSTRAINT snippet_files_uc_name UNIQUE (snippet_id, name);
+
+ALTER TABLE snippet_files ADD CONSTRAINT fk_snippet_files_snippet FOREIGN KEY (snippet_id) REFERENCES snippets(id) ON DELETE CASCADE;
+
+INSERT INTO snippet_files (snippet_id, position, name, language, content)
+SELECT id, 0, 'file1', language, content FROM snippets WHERE burned IS NULL;
@@ -0,0 +1,16 @@
+CREATE TABLE comments (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    snippet_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    body TEXT NOT NULL,
+    file_position INTEGER NOT NULL DEFAULT 0,
+    start_line INTEGER NULL,
+    end_line INTEGER NULL,
+    created DATETIME NOT NULL
+);
+
+CREATE INDEX idx_comments_snippet ON comments(snippet_id, created);
+
+ALTER TABLE comments ADD CONSTRAINT fk_comments_snippet FOREIGN KEY (snippet_id) REFERENCES snippets(id) ON DELETE CASCADE;
+
+ALTER TABLE comments ADD CONSTRAINT fk_comments_user FOREIGN KEY (user_id) REFERENCES users(id);
@@ -0,0 +1,12 @@
+CREATE TABLE stars (
+    user_id INTEGER NOT NULL,
+    snippet_id INTEGER NOT NULL,
+    created DATETIME NOT NULL,
+    PRIMARY KEY (user_id, snippet_id)
+);
+
+CREATE INDEX idx_stars_snippet ON stars(snippet_id);
+
+ALTER TABLE stars ADD CONSTRAINT fk_stars_user FOREIGN KEY (user_id) REFERENCES users(id);
+
+ALTER TABLE stars ADD CONSTRAINT fk_stars_snippet FOREIGN KEY (snippet_id) REFERENCES snippets(id) ON DELETE CASCADE;
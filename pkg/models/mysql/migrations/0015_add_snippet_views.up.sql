@@ -0,0 +1 @@
+ALTER TABLE snippets ADD COLUMN views INTEGER NOT NULL DEFAULT 0 AFTER language;
@@ -0,0 +1,25 @@
+CREATE TABLE collections (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    user_id INTEGER NOT NULL,
+    title VARCHAR(100) NOT NULL,
+    description TEXT NOT NULL,
+    visibility VARCHAR(10) NOT NULL DEFAULT 'private',
+    created DATETIME NOT NULL
+);
+
+CREATE INDEX idx_collections_user ON collections(user_id);
+
+ALTER TABLE collections ADD CONSTRAINT fk_collections_user FOREIGN KEY (user_id) REFERENCES users(id);
+
+CREATE TABLE collection_snippets (
+    collection_id INTEGER NOT NULL,
+    snippet_id INTEGER NOT NULL,
+    position INTEGER NOT NULL,
+    PRIMARY KEY (collection_id, snippet_id)
+);
+
+CREATE INDEX idx_collection_snippets_snippet ON collection_snippets(snippet_id);
+
+ALTER TABLE collection_snippets ADD CONSTRAINT fk_collection_snippets_collection FOREIGN KEY (collection_id) REFERENCES collections(id) ON DELETE CASCADE;
+
+ALTER TABLE collection_snippets ADD CONSTRAINT fk_collection_snippets_snippet FOREIGN KEY (snippet_id) REFERENCES snippets(id) ON DELETE CASCADE;
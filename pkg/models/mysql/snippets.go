@@ -1,69 +1,154 @@
 package mysql
 
 import (
+	"context"
 	"database/sql"
 	"errors"
+	"fmt"
+	"time"
 	"yudhiesh/snippetbox/pkg/models"
+
+	"golang.org/x/crypto/bcrypt"
 )
 
 // Define a SnippetModel type which wraps a sql.DB connection pool
 type SnippetModel struct {
 	DB *sql.DB
+	// Timeout bounds how long each method may spend on its queries. There
+	// is no limit when it is zero.
+	Timeout time.Duration
 }
 
-// This will insert a new snippet into the database
-func (m *SnippetModel) Insert(title, content, expires string) (int, error) {
+// This will insert a new snippet owned by the given user into the database and
+// return the random slug it was given. The content and language of the first
+// file are also stored as those of the snippet.
+func (m *SnippetModel) Insert(ctx context.Context, userID int, title string, files []*models.File, expires time.Time, visibility string, burn bool, password string, tags []string) (_ string, err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	return m.insert(ctx, 0, userID, title, files, expires, visibility, burn, password, tags)
+}
+
+// Inserts a snippet like Insert, recording the ID of the snippet it was forked
+// from unless that is 0
+func (m *SnippetModel) insert(ctx context.Context, forkedFrom, userID int, title string, files []*models.File, expires time.Time, visibility string, burn bool, password string, tags []string) (string, error) {
+	if len(files) == 0 {
+		return "", models.ErrNoFiles
+	}
+	content, language := files[0].Content, files[0].Language
+	slug, err := models.NewSlug()
+	if err != nil {
+		return "", err
+	}
+	// Like the passwords of users, only a bcrypt hash of the password is
+	// stored. It is made before the transaction begins as it is slow.
+	var hashedPassword sql.NullString
+	if password != "" {
+		h, err := bcrypt.GenerateFromPassword([]byte(password), 12)
+		if err != nil {
+			return "", err
+		}
+		hashedPassword = sql.NullString{String: string(h), Valid: true}
+	}
+	// Snippets which never expire have no expiry time
+	parentID := sql.NullInt64{Int64: int64(forkedFrom), Valid: forkedFrom != 0}
+	expiresAt := sql.NullTime{Time: expires.UTC(), Valid: !expires.IsZero()}
+
 	// Start a transaction
 	// Each action that is done is atomic in nature:
 	// All statements are executed successfully or no statement is executed
-	tx, err := m.DB.Begin()
+	tx, err := m.DB.BeginTx(ctx, nil)
 	if err != nil {
-		return 0, nil
+		return "", err
 	}
 
 	// Statement to insert data to the database
-	stmt := `INSERT INTO snippets (title, content, created, expires)
-	VALUES(?, ?, UTC_TIMESTAMP(), DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? DAY))`
+	stmt := `INSERT INTO snippets (slug, visibility, burn_after_reading, hashed_password, user_id, forked_from, title, content, language, created, expires)
+	VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, UTC_TIMESTAMP(), ?)`
 	// Pass in the placeholder parameters aka the ? in the stmt
-	result, err := tx.Exec(stmt, title, content, expires)
+	result, err := tx.ExecContext(ctx, stmt, slug, visibility, burn, hashedPassword, userID, parentID, title, content, language, expiresAt)
 	if err != nil {
 		tx.Rollback()
-		return 0, err
+		return "", err
 	}
-	// Return the id of the inserted record in the snippets table
+	// The id of the inserted record in the snippets table
 	id, err := result.LastInsertId()
 	if err != nil {
 		tx.Rollback()
-		return 0, err
+		return "", err
+	}
+
+	err = insertFiles(ctx, tx, int(id), files)
+	if err != nil {
+		tx.Rollback()
+		return "", err
+	}
+
+	// Record the published snippet as the first revision in its history
+	err = insertRevision(ctx, tx, int(id), title, files)
+	if err != nil {
+		tx.Rollback()
+		return "", err
+	}
+
+	err = insertTags(ctx, tx, int(id), tags)
+	if err != nil {
+		tx.Rollback()
+		return "", err
 	}
 
-	// id is an int64 to convert it to a int
 	err = tx.Commit()
-	return int(id), err
+	return slug, err
+
+}
+
+// Return a single snippet by its ID. ErrDeleted, ErrExpired and ErrBurned are
+// returned for snippets which exist but can no longer be viewed, along with
+// the snippet without its tags and files so that the caller can tell who may
+// know of it.
+func (m *SnippetModel) Get(ctx context.Context, id int) (_ *models.Snippet, err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
 
+	return m.get(ctx, "s.id = ?", id)
 }
 
-// Return a single snippet
-func (m *SnippetModel) Get(id int) (*models.Snippet, error) {
-	tx, err := m.DB.Begin()
+// Return a single snippet by its slug, like Get
+func (m *SnippetModel) GetBySlug(ctx context.Context, slug string) (_ *models.Snippet, err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	return m.get(ctx, "s.slug = ?", slug)
+}
+
+// Returns the snippet matching the condition. Whether the snippet may be
+// viewed by the current user is up to the caller.
+func (m *SnippetModel) get(ctx context.Context, where string, arg interface{}) (*models.Snippet, error) {
+	tx, err := m.DB.BeginTx(ctx, nil)
 	if err != nil {
-		tx.Rollback()
 		return nil, err
 	}
-	stmt := `SELECT id, title, content, created, expires FROM snippets
-    WHERE expires > UTC_TIMESTAMP() AND id = ?`
+	// Join on the users table so that the name of the author is available.
+	// Deleted and expired snippets are still selected so that the caller can
+	// tell them apart from snippets that never existed.
+	stmt := `SELECT s.id, s.slug, s.visibility, s.burn_after_reading, s.hashed_password IS NOT NULL, s.user_id, COALESCE(s.forked_from, 0), u.name, s.title, s.content, s.language, s.created, s.expires,
+	s.deleted, s.burned, s.expires IS NOT NULL AND s.expires <= UTC_TIMESTAMP()
+	FROM snippets s INNER JOIN users u ON u.id = s.user_id
+	WHERE ` + where
 	// m.DB.QueryRow returns a pointer to a sql.Row object which holds the
 	// result from the database
-	row := tx.QueryRow(stmt, id)
+	row := tx.QueryRowContext(ctx, stmt, arg)
 
 	// Initialize a pointer to a new zeroed Snippet struct
 	s := &models.Snippet{}
+	var deleted, burned sql.NullTime
+	var expired bool
 
 	// row.Scan() copies the values from each field to the Snippet struct s,
 	// All the values passed are pointers to the place you want to copy the data
 	// into, and the number of arguments must be exactly the same as the number
 	// of columns returned by your statement
-	err = row.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
+	err = row.Scan(&s.ID, &s.Slug, &s.Visibility, &s.BurnAfterReading, &s.Protected, &s.UserID, &s.ForkedFrom, &s.Author, &s.Title, &s.Content, &s.Language, &s.Created, expiryTime{&s.Expires}, &deleted, &burned, &expired)
 	if err != nil {
 		// If the query returns no rows then row.Scan() will return a
 		// sql.ErrNoRows error.
@@ -76,20 +161,157 @@ func (m *SnippetModel) Get(id int) (*models.Snippet, error) {
 			return nil, err
 		}
 	}
+	// Burned snippets are deleted too, so this is checked first
+	if burned.Valid {
+		tx.Rollback()
+		return s, models.ErrBurned
+	}
+	if deleted.Valid {
+		tx.Rollback()
+		return s, models.ErrDeleted
+	}
+	if expired {
+		tx.Rollback()
+		return s, models.ErrExpired
+	}
+	err = loadTags(ctx, tx, []*models.Snippet{s})
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	err = loadFiles(ctx, tx, s)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
 	// If everything is OK then return the Snippet object
 	err = tx.Commit()
 	return s, err
 }
 
-// Returns the 10 most recently created snippets
-func (m *SnippetModel) Latest() ([]*models.Snippet, error) {
-	tx, err := m.DB.Begin()
+// Returns a burn-after-reading snippet and burns it, so that it can only be
+// read once. The title and content are wiped along with the history, tags and
+// files, leaving a tombstone which makes later reads return ErrBurned.
+// ErrNoRecord is returned for snippets which aren't burn after reading.
+func (m *SnippetModel) Burn(ctx context.Context, slug string) (_ *models.Snippet, err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	tx, err := m.DB.BeginTx(ctx, nil)
 	if err != nil {
 		return nil, err
 	}
-	stmt := `SELECT id, title, content, created, expires FROM snippets
-	WHERE expires > UTC_TIMESTAMP() ORDER BY created DESC LIMIT 10`
-	rows, err := tx.Query(stmt)
+
+	// Lock the snippet row so that a concurrent read of the same snippet
+	// waits and then finds it burned
+	stmt := `SELECT s.id, s.slug, s.visibility, s.burn_after_reading, s.hashed_password IS NOT NULL, s.user_id, COALESCE(s.forked_from, 0), u.name, s.title, s.content, s.language, s.created, s.expires
+	FROM snippets s INNER JOIN users u ON u.id = s.user_id
+	WHERE s.slug = ? AND s.burn_after_reading = TRUE AND (s.expires IS NULL OR s.expires > UTC_TIMESTAMP()) AND s.deleted IS NULL
+	FOR UPDATE`
+	s := &models.Snippet{}
+	err = tx.QueryRowContext(ctx, stmt, slug).Scan(&s.ID, &s.Slug, &s.Visibility, &s.BurnAfterReading, &s.Protected, &s.UserID, &s.ForkedFrom, &s.Author, &s.Title, &s.Content, &s.Language, &s.Created, expiryTime{&s.Expires})
+	if errors.Is(err, sql.ErrNoRows) {
+		tx.Rollback()
+		// A regular read tells why the snippet can't be burned
+		if _, err = m.get(ctx, "s.slug = ?", slug); err != nil {
+			return nil, err
+		}
+		return nil, models.ErrNoRecord
+	}
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err = loadTags(ctx, tx, []*models.Snippet{s}); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err = loadFiles(ctx, tx, s); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	// The tombstone is marked as deleted as well, which keeps it out of
+	// every listing
+	for _, stmt := range []string{
+		`UPDATE snippets SET title = '', content = '', burned = UTC_TIMESTAMP(), deleted = UTC_TIMESTAMP() WHERE id = ?`,
+		`DELETE FROM snippet_revisions WHERE snippet_id = ?`,
+		`DELETE FROM snippet_tags WHERE snippet_id = ?`,
+		`DELETE FROM snippet_files WHERE snippet_id = ?`,
+	} {
+		if _, err = tx.ExecContext(ctx, stmt, s.ID); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+
+	err = tx.Commit()
+	return s, err
+}
+
+// Checks the password of a protected snippet, returning ErrInvalidCredentials
+// if it is wrong. Snippets without a password are always unlocked.
+func (m *SnippetModel) Unlock(ctx context.Context, id int, password string) (err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	var hashedPassword sql.NullString
+	stmt := `SELECT hashed_password FROM snippets WHERE id = ?`
+	err = m.DB.QueryRowContext(ctx, stmt, id).Scan(&hashedPassword)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.ErrNoRecord
+		}
+		return err
+	}
+	if !hashedPassword.Valid {
+		return nil
+	}
+
+	err = bcrypt.CompareHashAndPassword([]byte(hashedPassword.String), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return models.ErrInvalidCredentials
+	}
+	return err
+}
+
+// Returns a page of at most limit live public snippets, newest first. When before is
+// given only snippets older than that cursor are returned, and when after is
+// given only snippets newer than it. Like the other listings, it leaves out
+// burn-after-reading and password-protected snippets, whose content mustn't be
+// found by a search. The (created, id) keyset is served by
+// idx_snippets_created, as InnoDB secondary indexes implicitly end with the
+// primary key.
+func (m *SnippetModel) Latest(ctx context.Context, before, after *models.Cursor, limit int) (_ []*models.Snippet, err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	stmt := `SELECT s.id, s.slug, s.visibility, s.burn_after_reading, s.hashed_password IS NOT NULL, s.user_id, COALESCE(s.forked_from, 0), u.name, s.title, s.content, s.language, s.created, s.expires
+	FROM snippets s INNER JOIN users u ON u.id = s.user_id
+	WHERE (s.expires IS NULL OR s.expires > UTC_TIMESTAMP()) AND s.deleted IS NULL AND s.visibility = 'public'
+	AND s.burn_after_reading = FALSE AND s.hashed_password IS NULL`
+	args := []interface{}{}
+
+	// Paging backwards walks the index in ascending order so that the LIMIT
+	// keeps the snippets closest to the cursor. They are reversed below.
+	order := "DESC"
+	switch {
+	case before != nil:
+		stmt += ` AND (s.created < ? OR (s.created = ? AND s.id < ?))`
+		args = append(args, before.Created, before.Created, before.ID)
+	case after != nil:
+		stmt += ` AND (s.created > ? OR (s.created = ? AND s.id > ?))`
+		args = append(args, after.Created, after.Created, after.ID)
+		order = "ASC"
+	}
+	stmt += fmt.Sprintf(` ORDER BY s.created %[1]s, s.id %[1]s LIMIT ?`, order)
+	args = append(args, limit)
+
+	rows, err := tx.QueryContext(ctx, stmt, args...)
 	if err != nil {
 		tx.Rollback()
 		return nil, err
@@ -108,7 +330,7 @@ func (m *SnippetModel) Latest() ([]*models.Snippet, error) {
 		s := &models.Snippet{}
 
 		// Copy the values from the rows to the new Snippet object
-		err := rows.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
+		err := rows.Scan(&s.ID, &s.Slug, &s.Visibility, &s.BurnAfterReading, &s.Protected, &s.UserID, &s.ForkedFrom, &s.Author, &s.Title, &s.Content, &s.Language, &s.Created, expiryTime{&s.Expires})
 		if err != nil {
 			tx.Rollback()
 			return nil, err
@@ -122,6 +344,422 @@ func (m *SnippetModel) Latest() ([]*models.Snippet, error) {
 		tx.Rollback()
 		return nil, err
 	}
+	// The result set has to be closed before the transaction can be used for
+	// another query
+	rows.Close()
+	if err = loadTags(ctx, tx, snippets); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if after != nil {
+		for i, j := 0, len(snippets)-1; i < j; i, j = i+1, j-1 {
+			snippets[i], snippets[j] = snippets[j], snippets[i]
+		}
+	}
 	err = tx.Commit()
 	return snippets, err
 }
+
+// Returns at most limit live public snippets matching the query, most relevant
+// first. The search uses the FULLTEXT index over the title and content in
+// natural language mode.
+func (m *SnippetModel) Search(ctx context.Context, query string, limit int) (_ []*models.Snippet, err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	stmt := `SELECT s.id, s.slug, s.visibility, s.burn_after_reading, s.hashed_password IS NOT NULL, s.user_id, COALESCE(s.forked_from, 0), u.name, s.title, s.content, s.language, s.created, s.expires
+	FROM snippets s INNER JOIN users u ON u.id = s.user_id
+	WHERE MATCH(s.title, s.content) AGAINST(? IN NATURAL LANGUAGE MODE)
+	AND (s.expires IS NULL OR s.expires > UTC_TIMESTAMP()) AND s.deleted IS NULL AND s.visibility = 'public'
+	AND s.burn_after_reading = FALSE AND s.hashed_password IS NULL
+	ORDER BY MATCH(s.title, s.content) AGAINST(? IN NATURAL LANGUAGE MODE) DESC, s.created DESC
+	LIMIT ?`
+	rows, err := m.DB.QueryContext(ctx, stmt, query, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	snippets := []*models.Snippet{}
+	for rows.Next() {
+		s := &models.Snippet{}
+		err := rows.Scan(&s.ID, &s.Slug, &s.Visibility, &s.BurnAfterReading, &s.Protected, &s.UserID, &s.ForkedFrom, &s.Author, &s.Title, &s.Content, &s.Language, &s.Created, expiryTime{&s.Expires})
+		if err != nil {
+			return nil, err
+		}
+		snippets = append(snippets, s)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	if err = loadTags(ctx, m.DB, snippets); err != nil {
+		return nil, err
+	}
+	return snippets, nil
+}
+
+// Returns at most limit live public snippets with the given tag, newest
+// first
+func (m *SnippetModel) ByTag(ctx context.Context, tag string, limit int) (_ []*models.Snippet, err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	stmt := `SELECT s.id, s.slug, s.visibility, s.burn_after_reading, s.hashed_password IS NOT NULL, s.user_id, COALESCE(s.forked_from, 0), u.name, s.title, s.content, s.language, s.created, s.expires
+	FROM snippets s INNER JOIN users u ON u.id = s.user_id
+	INNER JOIN snippet_tags st ON st.snippet_id = s.id
+	INNER JOIN tags t ON t.id = st.tag_id
+	WHERE t.name = ? AND (s.expires IS NULL OR s.expires > UTC_TIMESTAMP()) AND s.deleted IS NULL AND s.visibility = 'public'
+	AND s.burn_after_reading = FALSE AND s.hashed_password IS NULL
+	ORDER BY s.created DESC LIMIT ?`
+	rows, err := m.DB.QueryContext(ctx, stmt, tag, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	snippets := []*models.Snippet{}
+	for rows.Next() {
+		s := &models.Snippet{}
+		err := rows.Scan(&s.ID, &s.Slug, &s.Visibility, &s.BurnAfterReading, &s.Protected, &s.UserID, &s.ForkedFrom, &s.Author, &s.Title, &s.Content, &s.Language, &s.Created, expiryTime{&s.Expires})
+		if err != nil {
+			return nil, err
+		}
+		snippets = append(snippets, s)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	if err = loadTags(ctx, m.DB, snippets); err != nil {
+		return nil, err
+	}
+	return snippets, nil
+}
+
+// Returns every snippet created by the given user, newest first. Expired
+// snippets are included so that the owner can still see what they posted, but
+// deleted snippets are not.
+func (m *SnippetModel) ByUser(ctx context.Context, userID int) (_ []*models.Snippet, err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	stmt := `SELECT s.id, s.slug, s.visibility, s.burn_after_reading, s.hashed_password IS NOT NULL, s.user_id, COALESCE(s.forked_from, 0), u.name, s.title, s.content, s.language, s.created, s.expires
+	FROM snippets s INNER JOIN users u ON u.id = s.user_id
+	WHERE s.user_id = ? AND s.deleted IS NULL ORDER BY s.created DESC`
+	rows, err := m.DB.QueryContext(ctx, stmt, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	snippets := []*models.Snippet{}
+	for rows.Next() {
+		s := &models.Snippet{}
+		err := rows.Scan(&s.ID, &s.Slug, &s.Visibility, &s.BurnAfterReading, &s.Protected, &s.UserID, &s.ForkedFrom, &s.Author, &s.Title, &s.Content, &s.Language, &s.Created, expiryTime{&s.Expires})
+		if err != nil {
+			return nil, err
+		}
+		snippets = append(snippets, s)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	if err = loadTags(ctx, m.DB, snippets); err != nil {
+		return nil, err
+	}
+	return snippets, nil
+}
+
+// Copies a live snippet into a new one owned by the given user which expires
+// at the given time, or never if it is zero, and returns the slug of the copy.
+// The copy keeps the title, files, tags and visibility of the snippet but not
+// its password, and records the snippet it was forked from. Whether the user
+// may view the snippet is up to the caller. Burn-after-reading snippets can't
+// be forked, so ErrNoRecord is returned for them.
+func (m *SnippetModel) Fork(ctx context.Context, id, userID int, expires time.Time) (_ string, err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	s, err := m.get(ctx, "s.id = ?", id)
+	if err != nil {
+		return "", err
+	}
+	if s.BurnAfterReading {
+		return "", models.ErrNoRecord
+	}
+	return m.insert(ctx, s.ID, userID, s.Title, s.Files, expires, s.Visibility, false, "", s.Tags)
+}
+
+// Returns at most limit live public snippets forked from the given snippet,
+// newest first
+func (m *SnippetModel) Forks(ctx context.Context, id, limit int) (_ []*models.Snippet, err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	stmt := `SELECT s.id, s.slug, s.visibility, s.burn_after_reading, s.hashed_password IS NOT NULL, s.user_id, COALESCE(s.forked_from, 0), u.name, s.title, s.content, s.language, s.created, s.expires
+	FROM snippets s INNER JOIN users u ON u.id = s.user_id
+	WHERE s.forked_from = ? AND (s.expires IS NULL OR s.expires > UTC_TIMESTAMP()) AND s.deleted IS NULL AND s.visibility = 'public'
+	AND s.burn_after_reading = FALSE AND s.hashed_password IS NULL
+	ORDER BY s.created DESC, s.id DESC LIMIT ?`
+	rows, err := m.DB.QueryContext(ctx, stmt, id, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	snippets := []*models.Snippet{}
+	for rows.Next() {
+		s := &models.Snippet{}
+		err := rows.Scan(&s.ID, &s.Slug, &s.Visibility, &s.BurnAfterReading, &s.Protected, &s.UserID, &s.ForkedFrom, &s.Author, &s.Title, &s.Content, &s.Language, &s.Created, expiryTime{&s.Expires})
+		if err != nil {
+			return nil, err
+		}
+		snippets = append(snippets, s)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	if err = loadTags(ctx, m.DB, snippets); err != nil {
+		return nil, err
+	}
+	return snippets, nil
+}
+
+// Replaces the title, visibility and files of a snippet owned by the given
+// user and stores the new version as the next revision in the snippet's
+// history. The content and language of the first file are also stored as
+// those of the snippet.
+// ErrNoRecord is returned if the snippet doesn't exist, has expired, has been
+// deleted or is owned by somebody else.
+func (m *SnippetModel) Update(ctx context.Context, id, userID int, title, visibility string, files []*models.File) (err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	if len(files) == 0 {
+		return models.ErrNoFiles
+	}
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	// Lock the snippet row so that concurrent edits are given consecutive
+	// version numbers
+	var ownerID int
+	stmt := `SELECT user_id FROM snippets
+	WHERE (expires IS NULL OR expires > UTC_TIMESTAMP()) AND deleted IS NULL AND id = ? FOR UPDATE`
+	err = tx.QueryRowContext(ctx, stmt, id).Scan(&ownerID)
+	if err != nil {
+		tx.Rollback()
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.ErrNoRecord
+		}
+		return err
+	}
+	if ownerID != userID {
+		tx.Rollback()
+		return models.ErrNoRecord
+	}
+
+	stmt = `UPDATE snippets SET title = ?, content = ?, language = ?, visibility = ? WHERE id = ?`
+	_, err = tx.ExecContext(ctx, stmt, title, files[0].Content, files[0].Language, visibility, id)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// The files are replaced as a whole, as they may have been renamed,
+	// reordered, added or removed
+	_, err = tx.ExecContext(ctx, `DELETE FROM snippet_files WHERE snippet_id = ?`, id)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err = insertFiles(ctx, tx, id, files); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err = insertRevision(ctx, tx, id, title, files); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Moves the expiry time of a snippet owned by the given user, or removes it if
+// expires is zero. Expired snippets can be renewed until they are purged, but
+// deleted snippets can't.
+func (m *SnippetModel) Renew(ctx context.Context, slug string, userID int, expires time.Time) (err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	// MySQL only counts the rows an UPDATE changed, so renewing a snippet to
+	// the expiry time it already has would look like a missing snippet.
+	// Instead the row is found and locked first.
+	var id int
+	stmt := `SELECT id FROM snippets WHERE slug = ? AND user_id = ? AND deleted IS NULL FOR UPDATE`
+	err = tx.QueryRowContext(ctx, stmt, slug, userID).Scan(&id)
+	if err != nil {
+		tx.Rollback()
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.ErrNoRecord
+		}
+		return err
+	}
+
+	stmt = `UPDATE snippets SET expires = ? WHERE id = ?`
+	expiresAt := sql.NullTime{Time: expires.UTC(), Valid: !expires.IsZero()}
+	_, err = tx.ExecContext(ctx, stmt, expiresAt, id)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Returns every stored revision of a snippet along with its files, newest
+// first
+func (m *SnippetModel) Revisions(ctx context.Context, id int) (_ []*models.Revision, err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	stmt := `SELECT id, snippet_id, version, title, content, created FROM snippet_revisions
+	WHERE snippet_id = ? ORDER BY version DESC`
+	rows, err := m.DB.QueryContext(ctx, stmt, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []*models.Revision{}
+	for rows.Next() {
+		rev := &models.Revision{}
+		err := rows.Scan(&rev.ID, &rev.SnippetID, &rev.Version, &rev.Title, &rev.Content, &rev.Created)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, rev)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	if err = loadRevisionFiles(ctx, m.DB, id, revisions); err != nil {
+		return nil, err
+	}
+	return revisions, nil
+}
+
+// Soft-deletes a snippet owned by the given user. The row is kept so that the
+// owner can restore it and visitors are shown a tombstone instead of a 404.
+func (m *SnippetModel) Delete(ctx context.Context, slug string, userID int) (err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	stmt := `UPDATE snippets SET deleted = UTC_TIMESTAMP()
+	WHERE slug = ? AND user_id = ? AND deleted IS NULL`
+	result, err := m.DB.ExecContext(ctx, stmt, slug, userID)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return models.ErrNoRecord
+	}
+	return nil
+}
+
+// Restores a snippet owned by the given user which was deleted less than
+// window ago. Burned snippets are gone for good.
+func (m *SnippetModel) Restore(ctx context.Context, slug string, userID int, window time.Duration) (err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	stmt := `UPDATE snippets SET deleted = NULL
+	WHERE slug = ? AND user_id = ? AND deleted > DATE_SUB(UTC_TIMESTAMP(), INTERVAL ? SECOND)
+	AND burned IS NULL`
+	result, err := m.DB.ExecContext(ctx, stmt, slug, userID, int(window.Seconds()))
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return models.ErrNoRecord
+	}
+	return nil
+}
+
+// Returns the snippets of the given user which were deleted less than window
+// ago and can therefore still be restored
+func (m *SnippetModel) Deleted(ctx context.Context, userID int, window time.Duration) (_ []*models.Snippet, err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	stmt := `SELECT s.id, s.slug, s.visibility, s.burn_after_reading, s.hashed_password IS NOT NULL, s.user_id, COALESCE(s.forked_from, 0), u.name, s.title, s.content, s.language, s.created, s.expires, s.deleted
+	FROM snippets s INNER JOIN users u ON u.id = s.user_id
+	WHERE s.user_id = ? AND s.deleted > DATE_SUB(UTC_TIMESTAMP(), INTERVAL ? SECOND)
+	AND s.burned IS NULL
+	ORDER BY s.deleted DESC`
+	rows, err := m.DB.QueryContext(ctx, stmt, userID, int(window.Seconds()))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	snippets := []*models.Snippet{}
+	for rows.Next() {
+		s := &models.Snippet{}
+		err := rows.Scan(&s.ID, &s.Slug, &s.Visibility, &s.BurnAfterReading, &s.Protected, &s.UserID, &s.ForkedFrom, &s.Author, &s.Title, &s.Content, &s.Language, &s.Created, expiryTime{&s.Expires}, &s.Deleted)
+		if err != nil {
+			return nil, err
+		}
+		snippets = append(snippets, s)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return snippets, nil
+}
+
+// Permanently deletes up to limit expired snippets, along with their history
+// and tags, and returns how many were deleted
+func (m *SnippetModel) PurgeExpired(ctx context.Context, limit int) (_ int, err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	// The revisions and tags of a snippet are removed by ON DELETE CASCADE
+	stmt := `DELETE FROM snippets WHERE expires <= UTC_TIMESTAMP() LIMIT ?`
+	result, err := m.DB.ExecContext(ctx, stmt, limit)
+	if err != nil {
+		return 0, err
+	}
+	n, err := result.RowsAffected()
+	return int(n), err
+}
+
+// Scans a nullable expiry time into a snippet, leaving the zero time for
+// snippets which never expire
+type expiryTime struct {
+	t *time.Time
+}
+
+func (e expiryTime) Scan(src interface{}) error {
+	var nt sql.NullTime
+	if err := nt.Scan(src); err != nil {
+		return err
+	}
+	*e.t = nt.Time
+	return nil
+}
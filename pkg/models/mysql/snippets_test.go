@@ -0,0 +1,30 @@
+package mysql
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"yudhiesh/snippetbox/pkg/models"
+)
+
+func TestSnippetModelCanceled(t *testing.T) {
+	// Skip the test if the `-short` flag is provided
+	if testing.Short() {
+		t.Skip("mysql: skipping integration test")
+	}
+	db, teardown := newTestDB(t)
+	defer teardown()
+
+	m := SnippetModel{DB: db}
+
+	// The transaction can't even be begun with a canceled context
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := m.Get(ctx, 1); !errors.Is(err, models.ErrCanceled) {
+		t.Errorf("Get: want %v; got %v", models.ErrCanceled, err)
+	}
+	if _, err := m.GetBySlug(ctx, "pond7kq2mzx4wbna"); !errors.Is(err, models.ErrCanceled) {
+		t.Errorf("GetBySlug: want %v; got %v", models.ErrCanceled, err)
+	}
+}
@@ -0,0 +1,132 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+	"yudhiesh/snippetbox/pkg/models"
+)
+
+type StarModel struct {
+	DB      *sql.DB
+	Timeout time.Duration // as for SnippetModel
+}
+
+// Stars a snippet for a user, or removes the star if the user had already
+// starred it, and reports whether the snippet is now starred
+func (m *StarModel) Toggle(ctx context.Context, snippetID, userID int) (_ bool, err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	result, err := tx.ExecContext(ctx, `DELETE FROM stars WHERE user_id = ? AND snippet_id = ?`, userID, snippetID)
+	if err != nil {
+		tx.Rollback()
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		tx.Rollback()
+		return false, err
+	}
+	if n == 0 {
+		// A star added by a concurrent toggle is kept
+		stmt := `INSERT IGNORE INTO stars (user_id, snippet_id, created) VALUES(?, ?, UTC_TIMESTAMP())`
+		if _, err = tx.ExecContext(ctx, stmt, userID, snippetID); err != nil {
+			tx.Rollback()
+			return false, err
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return false, err
+	}
+	return n == 0, nil
+}
+
+// Reports whether a user has starred a snippet
+func (m *StarModel) IsStarred(ctx context.Context, snippetID, userID int) (_ bool, err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	var starred bool
+	stmt := `SELECT EXISTS(SELECT 1 FROM stars WHERE user_id = ? AND snippet_id = ?)`
+	err = m.DB.QueryRowContext(ctx, stmt, userID, snippetID).Scan(&starred)
+	return starred, err
+}
+
+// Fills in the Stars field of each snippet
+func (m *StarModel) Count(ctx context.Context, snippets ...*models.Snippet) (err error) {
+	if len(snippets) == 0 {
+		return nil
+	}
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	byID := make(map[int][]*models.Snippet, len(snippets))
+	args := make([]interface{}, 0, len(snippets))
+	for _, s := range snippets {
+		s.Stars = 0
+		byID[s.ID] = append(byID[s.ID], s)
+		args = append(args, s.ID)
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(args)), ", ")
+	stmt := `SELECT snippet_id, COUNT(*) FROM stars
+	WHERE snippet_id IN (` + placeholders + `) GROUP BY snippet_id`
+	rows, err := m.DB.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id, n int
+		if err := rows.Scan(&id, &n); err != nil {
+			return err
+		}
+		for _, s := range byID[id] {
+			s.Stars = n
+		}
+	}
+	return rows.Err()
+}
+
+// Returns the live snippets starred by a user which the user can still view,
+// most recently starred first. Burn-after-reading snippets can't be starred.
+func (m *StarModel) ByUser(ctx context.Context, userID int) (_ []*models.Snippet, err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	stmt := `SELECT s.id, s.slug, s.visibility, s.burn_after_reading, s.hashed_password IS NOT NULL, s.user_id, COALESCE(s.forked_from, 0), u.name, s.title, s.content, s.language, s.created, s.expires
+	FROM stars st INNER JOIN snippets s ON s.id = st.snippet_id
+	INNER JOIN users u ON u.id = s.user_id
+	WHERE st.user_id = ? AND (s.expires IS NULL OR s.expires > UTC_TIMESTAMP()) AND s.deleted IS NULL
+	AND s.burn_after_reading = FALSE AND (s.visibility <> 'private' OR s.user_id = ?)
+	ORDER BY st.created DESC, st.snippet_id DESC`
+	rows, err := m.DB.QueryContext(ctx, stmt, userID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	snippets := []*models.Snippet{}
+	for rows.Next() {
+		s := &models.Snippet{}
+		err := rows.Scan(&s.ID, &s.Slug, &s.Visibility, &s.BurnAfterReading, &s.Protected, &s.UserID, &s.ForkedFrom, &s.Author, &s.Title, &s.Content, &s.Language, &s.Created, expiryTime{&s.Expires})
+		if err != nil {
+			return nil, err
+		}
+		snippets = append(snippets, s)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	if err = loadTags(ctx, m.DB, snippets); err != nil {
+		return nil, err
+	}
+	return snippets, nil
+}
@@ -0,0 +1,73 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"yudhiesh/snippetbox/pkg/models"
+)
+
+// querier is satisfied by both *sql.DB and *sql.Tx so that helpers can be
+// used inside and outside of a transaction
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// Attaches the tags to a snippet, creating any tags which don't exist yet
+func insertTags(ctx context.Context, tx *sql.Tx, snippetID int, tags []string) error {
+	for _, tag := range tags {
+		// LAST_INSERT_ID(id) makes LastInsertId() return the id of the
+		// existing row when the tag is already in the table
+		stmt := `INSERT INTO tags (name) VALUES(?) ON DUPLICATE KEY UPDATE id = LAST_INSERT_ID(id)`
+		result, err := tx.ExecContext(ctx, stmt, tag)
+		if err != nil {
+			return err
+		}
+		tagID, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+
+		stmt = `INSERT INTO snippet_tags (snippet_id, tag_id) VALUES(?, ?)`
+		_, err = tx.ExecContext(ctx, stmt, snippetID, tagID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Fills in the Tags field of the snippets with a single query
+func loadTags(ctx context.Context, q querier, snippets []*models.Snippet) error {
+	if len(snippets) == 0 {
+		return nil
+	}
+
+	byID := make(map[int]*models.Snippet, len(snippets))
+	args := make([]interface{}, 0, len(snippets))
+	for _, s := range snippets {
+		s.Tags = []string{}
+		byID[s.ID] = s
+		args = append(args, s.ID)
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(args)), ", ")
+	stmt := `SELECT st.snippet_id, t.name FROM snippet_tags st
+	INNER JOIN tags t ON t.id = st.tag_id
+	WHERE st.snippet_id IN (` + placeholders + `) ORDER BY t.name`
+	rows, err := q.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			return err
+		}
+		byID[id].Tags = append(byID[id].Tags, name)
+	}
+	return rows.Err()
+}
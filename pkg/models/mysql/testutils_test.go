@@ -4,6 +4,8 @@ import (
 	"database/sql"
 	"io/ioutil"
 	"testing"
+
+	"yudhiesh/snippetbox/pkg/migrate"
 )
 
 func newTestDB(t *testing.T) (*sql.DB, func()) {
@@ -12,6 +14,15 @@ func newTestDB(t *testing.T) (*sql.DB, func()) {
 		t.Fatal(err)
 	}
 
+	// Create the schema with the same migrations used in production
+	migrator, err := migrate.New(db, Migrations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = migrator.Up(); err != nil {
+		t.Fatal(err)
+	}
+
 	script, err := ioutil.ReadFile("./testdata/setup.sql")
 	if err != nil {
 		t.Fatal(err)
@@ -22,6 +33,9 @@ func newTestDB(t *testing.T) (*sql.DB, func()) {
 		t.Fatal(err)
 	}
 	return db, func() {
+		if _, err := migrator.Down(len(migrator.Migrations)); err != nil {
+			t.Fatal(err)
+		}
 		script, err := ioutil.ReadFile("./testdata/teardown.sql")
 		if err != nil {
 			t.Fatal(err)
@@ -1,9 +1,11 @@
 package mysql
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"strings"
+	"time"
 	"yudhiesh/snippetbox/pkg/models"
 
 	"github.com/go-sql-driver/mysql"
@@ -11,12 +13,16 @@ import (
 )
 
 type UserModel struct {
-	DB *sql.DB
+	DB      *sql.DB
+	Timeout time.Duration // as for SnippetModel
 }
 
 // Insert a user into the users table
-func (m *UserModel) Insert(name, email, password string) error {
-	tx, err := m.DB.Begin()
+func (m *UserModel) Insert(ctx context.Context, name, email, password string) (err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	tx, err := m.DB.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
@@ -26,7 +32,7 @@ func (m *UserModel) Insert(name, email, password string) error {
 	}
 	stmt := `INSERT INTO users (name, email, hashed_password, created)
     VALUES(?, ?, ?, UTC_TIMESTAMP())`
-	_, err = tx.Exec(stmt, name, email, string(hashedPassword))
+	_, err = tx.ExecContext(ctx, stmt, name, email, string(hashedPassword))
 	if err != nil {
 		var mySQLError *mysql.MySQLError
 		if errors.As(err, &mySQLError) {
@@ -43,10 +49,13 @@ func (m *UserModel) Insert(name, email, password string) error {
 }
 
 // Authenticate the users email and password
-func (m *UserModel) Authenticate(email, password string) (int, error) {
-	tx, err := m.DB.Begin()
+func (m *UserModel) Authenticate(ctx context.Context, email, password string) (_ int, err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	tx, err := m.DB.BeginTx(ctx, nil)
 	if err != nil {
-		return 0, nil
+		return 0, err
 	}
 	// Retrieve the id and hashedPassword from the email
 	// If no matching email exists, or the user is not active, we return the
@@ -54,7 +63,7 @@ func (m *UserModel) Authenticate(email, password string) (int, error) {
 	var id int
 	var hashedPassword []byte
 	stmt := `SELECT id, hashed_password FROM users WHERE email = ? AND active = true`
-	row := tx.QueryRow(stmt, email)
+	row := tx.QueryRowContext(ctx, stmt, email)
 	err = row.Scan(&id, &hashedPassword)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -75,7 +84,7 @@ func (m *UserModel) Authenticate(email, password string) (int, error) {
 			return 0, models.ErrInvalidCredentials
 		} else {
 			tx.Rollback()
-			return 0, nil
+			return 0, err
 		}
 	}
 
@@ -83,12 +92,15 @@ func (m *UserModel) Authenticate(email, password string) (int, error) {
 	return id, err
 }
 
-func (m *UserModel) Get(id int) (*models.User, error) {
+func (m *UserModel) Get(ctx context.Context, id int) (_ *models.User, err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
 	u := &models.User{}
 
 	stmt := `SELECT id, name, email, created, active FROM users WHERE id = ?`
 
-	err := m.DB.QueryRow(stmt, id).Scan(&u.ID, &u.Name, &u.Email, &u.Created, &u.Active)
+	err = m.DB.QueryRowContext(ctx, stmt, id).Scan(&u.ID, &u.Name, &u.Email, &u.Created, &u.Active)
 
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -101,10 +113,13 @@ func (m *UserModel) Get(id int) (*models.User, error) {
 	return u, nil
 }
 
-func (m *UserModel) ChangePassword(id int, currentPassword, newPassword string) error {
+func (m *UserModel) ChangePassword(ctx context.Context, id int, currentPassword, newPassword string) (err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
 	var currentHashedPassword []byte
-	row := m.DB.QueryRow("SELECT hashed_password FROM users WHERE id = ?", id)
-	err := row.Scan(&currentHashedPassword)
+	row := m.DB.QueryRowContext(ctx, "SELECT hashed_password FROM users WHERE id = ?", id)
+	err = row.Scan(&currentHashedPassword)
 	if err != nil {
 		return err
 	}
@@ -124,6 +139,6 @@ func (m *UserModel) ChangePassword(id int, currentPassword, newPassword string)
 	}
 
 	stmt := "UPDATE users SET hashed_password = ? WHERE id = ?"
-	_, err = m.DB.Exec(stmt, string(newHashedPassword), id)
+	_, err = m.DB.ExecContext(ctx, stmt, string(newHashedPassword), id)
 	return err
 }
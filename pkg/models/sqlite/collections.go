@@ -0,0 +1,154 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+	"yudhiesh/snippetbox/pkg/models"
+)
+
+type CollectionModel struct {
+	DB      *sql.DB
+	Timeout time.Duration // as for SnippetModel
+}
+
+// The columns of a collection, without its snippets, selected from
+// collections c joined with the users u owning them
+const collectionColumns = `c.id, c.user_id, u.name, c.title, c.description, c.visibility, c.created`
+
+// Creates an empty collection and returns its ID
+func (m *CollectionModel) Insert(ctx context.Context, userID int, title, description, visibility string) (_ int, err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	stmt := `INSERT INTO collections (user_id, title, description, visibility, created)
+	VALUES(?, ?, ?, ?, datetime('now'))`
+	result, err := m.DB.ExecContext(ctx, stmt, userID, title, description, visibility)
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return int(id), nil
+}
+
+// Returns a collection along with its live snippets, in the order they were
+// added
+func (m *CollectionModel) Get(ctx context.Context, id int) (_ *models.Collection, err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	c := &models.Collection{}
+	stmt := `SELECT ` + collectionColumns + `
+	FROM collections c INNER JOIN users u ON u.id = c.user_id WHERE c.id = ?`
+	err = m.DB.QueryRowContext(ctx, stmt, id).Scan(&c.ID, &c.UserID, &c.Owner, &c.Title, &c.Description, &c.Visibility, &c.Created)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrNoRecord
+		}
+		return nil, err
+	}
+
+	stmt = `SELECT ` + snippetColumns + `
+	FROM collection_snippets cs INNER JOIN snippets s ON s.id = cs.snippet_id
+	INNER JOIN users u ON u.id = s.user_id
+	WHERE cs.collection_id = ? AND (s.expires IS NULL OR s.expires > datetime('now')) AND s.deleted IS NULL
+	ORDER BY cs.position`
+	c.Snippets, err = querySnippets(ctx, m.DB, stmt, id)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Returns the collections owned by a user, most recently created first. Their
+// snippets aren't loaded.
+func (m *CollectionModel) ByUser(ctx context.Context, userID int) (_ []*models.Collection, err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	stmt := `SELECT ` + collectionColumns + `
+	FROM collections c INNER JOIN users u ON u.id = c.user_id
+	WHERE c.user_id = ? ORDER BY c.created DESC, c.id DESC`
+	return m.query(ctx, stmt, userID)
+}
+
+// Returns the collections owned by a user which hold a snippet, most recently
+// created first. Their snippets aren't loaded.
+func (m *CollectionModel) Containing(ctx context.Context, userID, snippetID int) (_ []*models.Collection, err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	stmt := `SELECT ` + collectionColumns + `
+	FROM collections c INNER JOIN users u ON u.id = c.user_id
+	INNER JOIN collection_snippets cs ON cs.collection_id = c.id
+	WHERE c.user_id = ? AND cs.snippet_id = ? ORDER BY c.created DESC, c.id DESC`
+	return m.query(ctx, stmt, userID, snippetID)
+}
+
+// Runs a query selecting collectionColumns
+func (m *CollectionModel) query(ctx context.Context, stmt string, args ...interface{}) ([]*models.Collection, error) {
+	rows, err := m.DB.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	collections := []*models.Collection{}
+	for rows.Next() {
+		c := &models.Collection{}
+		err := rows.Scan(&c.ID, &c.UserID, &c.Owner, &c.Title, &c.Description, &c.Visibility, &c.Created)
+		if err != nil {
+			return nil, err
+		}
+		collections = append(collections, c)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return collections, nil
+}
+
+// Changes the title, description and visibility of a collection
+func (m *CollectionModel) Update(ctx context.Context, id int, title, description, visibility string) (err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	stmt := `UPDATE collections SET title = ?, description = ?, visibility = ? WHERE id = ?`
+	return execOne(ctx, m.DB, stmt, title, description, visibility, id)
+}
+
+// Deletes a collection. The snippets in it are kept.
+func (m *CollectionModel) Delete(ctx context.Context, id int) (err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	return execOne(ctx, m.DB, `DELETE FROM collections WHERE id = ?`, id)
+}
+
+// Adds a snippet to the end of a collection. It returns ErrInCollection if
+// the snippet is already in it.
+func (m *CollectionModel) AddSnippet(ctx context.Context, id, snippetID int) (err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	stmt := `INSERT OR IGNORE INTO collection_snippets (collection_id, snippet_id, position)
+	SELECT ?, ?, COALESCE(MAX(position), 0) + 1 FROM collection_snippets WHERE collection_id = ?`
+	err = execOne(ctx, m.DB, stmt, id, snippetID, id)
+	if errors.Is(err, models.ErrNoRecord) {
+		return models.ErrInCollection
+	}
+	return err
+}
+
+// Removes a snippet from a collection
+func (m *CollectionModel) RemoveSnippet(ctx context.Context, id, snippetID int) (err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	stmt := `DELETE FROM collection_snippets WHERE collection_id = ? AND snippet_id = ?`
+	return execOne(ctx, m.DB, stmt, id, snippetID)
+}
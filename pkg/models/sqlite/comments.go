@@ -0,0 +1,75 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"time"
+	"yudhiesh/snippetbox/pkg/models"
+)
+
+type CommentModel struct {
+	DB      *sql.DB
+	Timeout time.Duration // as for SnippetModel
+}
+
+// Adds a comment to a snippet and returns its ID. Comments which aren't
+// anchored to any lines have a start and end line of 0.
+func (m *CommentModel) Insert(ctx context.Context, snippetID, userID int, body string, file, startLine, endLine int) (_ int, err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	var start, end sql.NullInt64
+	if startLine != 0 {
+		start = sql.NullInt64{Int64: int64(startLine), Valid: true}
+		end = sql.NullInt64{Int64: int64(endLine), Valid: true}
+	}
+	stmt := `INSERT INTO comments (snippet_id, user_id, body, file_position, start_line, end_line, created)
+	VALUES(?, ?, ?, ?, ?, ?, datetime('now'))`
+	result, err := m.DB.ExecContext(ctx, stmt, snippetID, userID, body, file, start, end)
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	return int(id), nil
+}
+
+// Returns the comments on a snippet, oldest first
+func (m *CommentModel) BySnippet(ctx context.Context, snippetID int) (_ []*models.Comment, err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	stmt := `SELECT c.id, c.snippet_id, c.user_id, u.name, c.body, c.file_position, COALESCE(c.start_line, 0), COALESCE(c.end_line, 0), c.created
+	FROM comments c INNER JOIN users u ON u.id = c.user_id
+	WHERE c.snippet_id = ? ORDER BY c.created, c.id`
+	rows, err := m.DB.QueryContext(ctx, stmt, snippetID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments := []*models.Comment{}
+	for rows.Next() {
+		c := &models.Comment{}
+		err := rows.Scan(&c.ID, &c.SnippetID, &c.UserID, &c.Author, &c.Body, &c.File, &c.StartLine, &c.EndLine, &c.Created)
+		if err != nil {
+			return nil, err
+		}
+		comments = append(comments, c)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return comments, nil
+}
+
+// Deletes a comment on the given snippet
+func (m *CommentModel) Delete(ctx context.Context, id, snippetID int) (err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	stmt := `DELETE FROM comments WHERE id = ? AND snippet_id = ?`
+	return execOne(ctx, m.DB, stmt, id, snippetID)
+}
@@ -0,0 +1,92 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"yudhiesh/snippetbox/pkg/models"
+)
+
+func TestCommentModel(t *testing.T) {
+	db, teardown := newTestDB(t)
+	defer teardown()
+	m := CommentModel{DB: db}
+	snippets := SnippetModel{DB: db}
+	ctx := context.Background()
+
+	slug, err := snippets.Insert(ctx, 1, "An old silent pond", oneFile("An old silent pond\nA frog jumps in", ""), nextWeek, "public", false, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := snippets.GetBySlug(ctx, slug)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := m.Insert(ctx, s.ID, 1, "A lovely haiku", 0, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := m.Insert(ctx, s.ID, 1, "Which pond?", 0, 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	comments, err := m.BySnippet(ctx, s.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(comments) != 2 || comments[0].ID != first || comments[1].ID != second {
+		t.Fatalf("want comments %d and %d, oldest first; got %v", first, second, comments)
+	}
+	c := comments[1]
+	if c.SnippetID != s.ID || c.UserID != 1 || c.Author != "Alice Jones" || c.Body != "Which pond?" || c.Created.IsZero() {
+		t.Errorf("want the comment as inserted; got %+v", c)
+	}
+	if !c.Anchored() || c.File != 0 || c.StartLine != 1 || c.EndLine != 2 {
+		t.Errorf("want the comment anchored to lines 1 to 2; got %+v", c)
+	}
+	if comments[0].Anchored() {
+		t.Errorf("want the first comment not anchored; got %+v", comments[0])
+	}
+
+	// Comments can only be deleted through the snippet they are on
+	if err = m.Delete(ctx, first, s.ID+1); !errors.Is(err, models.ErrNoRecord) {
+		t.Errorf("other snippet: want %v; got %v", models.ErrNoRecord, err)
+	}
+	if err = m.Delete(ctx, first, s.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err = m.Delete(ctx, first, s.ID); !errors.Is(err, models.ErrNoRecord) {
+		t.Errorf("deleted twice: want %v; got %v", models.ErrNoRecord, err)
+	}
+	comments, err = m.BySnippet(ctx, s.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(comments) != 1 || comments[0].ID != second {
+		t.Errorf("want only comment %d left; got %v", second, comments)
+	}
+
+	// Comments go along with purged snippets
+	expired, err := snippets.Insert(ctx, 1, "Over the wintry forest", oneFile("Winds howl in rage", ""), yesterday, "public", false, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Expired snippets can't be read, but were inserted right after s
+	if _, err = snippets.GetBySlug(ctx, expired); !errors.Is(err, models.ErrExpired) {
+		t.Fatalf("want %v; got %v", models.ErrExpired, err)
+	}
+	if _, err = m.Insert(ctx, s.ID+1, 1, "Too late", 0, 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = snippets.PurgeExpired(ctx, 10); err != nil {
+		t.Fatal(err)
+	}
+	comments, err = m.BySnippet(ctx, s.ID+1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(comments) != 0 {
+		t.Errorf("want no comments left; got %v", comments)
+	}
+}
@@ -0,0 +1,94 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"yudhiesh/snippetbox/pkg/models"
+)
+
+// Stores the files of a snippet in the order they were given
+func insertFiles(ctx context.Context, tx *sql.Tx, snippetID int, files []*models.File) error {
+	stmt := `INSERT INTO snippet_files (snippet_id, position, name, language, content) VALUES(?, ?, ?, ?, ?)`
+	for i, f := range files {
+		_, err := tx.ExecContext(ctx, stmt, snippetID, i, f.Name, f.Language, f.Content)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Fills in the Files field of a single snippet
+func loadFiles(ctx context.Context, q querier, s *models.Snippet) error {
+	stmt := `SELECT name, language, content FROM snippet_files WHERE snippet_id = ? ORDER BY position`
+	rows, err := q.QueryContext(ctx, stmt, s.ID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	s.Files = []*models.File{}
+	for rows.Next() {
+		f := &models.File{}
+		if err := rows.Scan(&f.Name, &f.Language, &f.Content); err != nil {
+			return err
+		}
+		s.Files = append(s.Files, f)
+	}
+	return rows.Err()
+}
+
+// Records the title and files of a snippet as the next revision in its
+// history, which is the first if it has none yet. The content of the first
+// file is also stored as that of the revision.
+func insertRevision(ctx context.Context, tx *sql.Tx, snippetID int, title string, files []*models.File) error {
+	stmt := `INSERT INTO snippet_revisions (snippet_id, version, title, content, created)
+	SELECT ?, COALESCE(MAX(version), 0) + 1, ?, ?, datetime('now')
+	FROM snippet_revisions WHERE snippet_id = ?`
+	result, err := tx.ExecContext(ctx, stmt, snippetID, title, files[0].Content, snippetID)
+	if err != nil {
+		return err
+	}
+	revisionID, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	stmt = `INSERT INTO snippet_revision_files (revision_id, position, name, language, content) VALUES(?, ?, ?, ?, ?)`
+	for i, f := range files {
+		_, err := tx.ExecContext(ctx, stmt, revisionID, i, f.Name, f.Language, f.Content)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Fills in the Files field of the revisions of a snippet
+func loadRevisionFiles(ctx context.Context, q querier, snippetID int, revisions []*models.Revision) error {
+	byID := map[int]*models.Revision{}
+	for _, rev := range revisions {
+		rev.Files = []*models.File{}
+		byID[rev.ID] = rev
+	}
+	stmt := `SELECT f.revision_id, f.name, f.language, f.content
+	FROM snippet_revision_files f INNER JOIN snippet_revisions r ON r.id = f.revision_id
+	WHERE r.snippet_id = ? ORDER BY f.revision_id, f.position`
+	rows, err := q.QueryContext(ctx, stmt, snippetID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var revisionID int
+		f := &models.File{}
+		if err := rows.Scan(&revisionID, &f.Name, &f.Language, &f.Content); err != nil {
+			return err
+		}
+		if rev, ok := byID[revisionID]; ok {
+			rev.Files = append(rev.Files, f)
+		}
+	}
+	return rows.Err()
+}
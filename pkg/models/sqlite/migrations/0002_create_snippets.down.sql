@@ -0,0 +1,3 @@
+DROP TABLE snippets_fts;
+
+DROP TABLE snippets;
@@ -0,0 +1,29 @@
+CREATE TABLE snippets (
+    id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    title VARCHAR(100) NOT NULL,
+    content TEXT NOT NULL,
+    created DATETIME NOT NULL,
+    expires DATETIME NOT NULL,
+    deleted DATETIME NULL
+);
+
+CREATE INDEX idx_snippets_created ON snippets(created, id);
+
+-- Full-text index over the snippets table, kept in sync by the triggers below
+CREATE VIRTUAL TABLE snippets_fts USING fts5(
+    title, content, content='snippets', content_rowid='id'
+);
+
+CREATE TRIGGER snippets_fts_insert AFTER INSERT ON snippets BEGIN
+    INSERT INTO snippets_fts (rowid, title, content) VALUES (new.id, new.title, new.content);
+END;
+
+CREATE TRIGGER snippets_fts_delete AFTER DELETE ON snippets BEGIN
+    INSERT INTO snippets_fts (snippets_fts, rowid, title, content) VALUES ('delete', old.id, old.title, old.content);
+END;
+
+CREATE TRIGGER snippets_fts_update AFTER UPDATE OF title, content ON snippets BEGIN
+    INSERT INTO snippets_fts (snippets_fts, rowid, title, content) VALUES ('delete', old.id, old.title, old.content);
+    INSERT INTO snippets_fts (rowid, title, content) VALUES (new.id, new.title, new.content);
+END;
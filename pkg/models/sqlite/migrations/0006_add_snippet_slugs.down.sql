@@ -0,0 +1,5 @@
+DROP INDEX snippets_uc_slug;
+
+ALTER TABLE snippets DROP COLUMN visibility;
+
+ALTER TABLE snippets DROP COLUMN slug;
@@ -0,0 +1,11 @@
+-- SQLite can't add a NOT NULL column without a default, so the slug column
+-- relies on the unique index and the application to always be set
+ALTER TABLE snippets ADD COLUMN slug CHAR(16);
+
+ALTER TABLE snippets ADD COLUMN visibility VARCHAR(10) NOT NULL DEFAULT 'public';
+
+-- Existing snippets stay public and get a random slug of the same length as
+-- the ones generated by the application
+UPDATE snippets SET slug = lower(hex(randomblob(8)));
+
+CREATE UNIQUE INDEX snippets_uc_slug ON snippets(slug);
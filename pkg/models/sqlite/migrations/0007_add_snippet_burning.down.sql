@@ -0,0 +1,3 @@
+ALTER TABLE snippets DROP COLUMN burned;
+
+ALTER TABLE snippets DROP COLUMN burn_after_reading;
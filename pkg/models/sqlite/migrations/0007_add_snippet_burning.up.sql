@@ -0,0 +1,3 @@
+ALTER TABLE snippets ADD COLUMN burn_after_reading BOOLEAN NOT NULL DEFAULT FALSE;
+
+ALTER TABLE snippets ADD COLUMN burned DATETIME NULL;
@@ -0,0 +1,12 @@
+-- Snippets which never expired are kept for another year
+ALTER TABLE snippets ADD COLUMN expires_not_null DATETIME NOT NULL DEFAULT '';
+
+UPDATE snippets SET expires_not_null = COALESCE(expires, datetime('now', '+1 year'));
+
+DROP INDEX idx_snippets_expires;
+
+ALTER TABLE snippets DROP COLUMN expires;
+
+ALTER TABLE snippets RENAME COLUMN expires_not_null TO expires;
+
+CREATE INDEX idx_snippets_expires ON snippets(expires);
@@ -0,0 +1,13 @@
+-- Snippets which never expire have no expiry time. SQLite can't change the
+-- constraints of a column, so the column is replaced with a nullable copy.
+ALTER TABLE snippets ADD COLUMN expires_nullable DATETIME NULL;
+
+UPDATE snippets SET expires_nullable = expires;
+
+DROP INDEX idx_snippets_expires;
+
+ALTER TABLE snippets DROP COLUMN expires;
+
+ALTER TABLE snippets RENAME COLUMN expires_nullable TO expires;
+
+CREATE INDEX idx_snippets_expires ON snippets(expires);
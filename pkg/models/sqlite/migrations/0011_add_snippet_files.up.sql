@@ -0,0 +1,13 @@
+CREATE TABLE snippet_files (
+    id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    snippet_id INTEGER NOT NULL REFERENCES snippets(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    language VARCHAR(32) NOT NULL DEFAULT '',
+    content TEXT NOT NULL,
+    CONSTRAINT snippet_files_uc_position UNIQUE (snippet_id, position),
+    CONSTRAINT snippet_files_uc_name UNIQUE (snippet_id, name)
+);
+
+INSERT INTO snippet_files (snippet_id, position, name, language, content)
+SELECT id, 0, 'file1', language, content FROM snippets WHERE burned IS NULL;
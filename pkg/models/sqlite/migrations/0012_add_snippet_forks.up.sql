@@ -0,0 +1,3 @@
+ALTER TABLE snippets ADD COLUMN forked_from INTEGER NULL;
+
+CREATE INDEX idx_snippets_forked_from ON snippets(forked_from);
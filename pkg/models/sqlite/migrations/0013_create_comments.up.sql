@@ -0,0 +1,12 @@
+CREATE TABLE comments (
+    id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    snippet_id INTEGER NOT NULL REFERENCES snippets(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    body TEXT NOT NULL,
+    file_position INTEGER NOT NULL DEFAULT 0,
+    start_line INTEGER NULL,
+    end_line INTEGER NULL,
+    created DATETIME NOT NULL
+);
+
+CREATE INDEX idx_comments_snippet ON comments(snippet_id, created);
@@ -0,0 +1,8 @@
+CREATE TABLE stars (
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    snippet_id INTEGER NOT NULL REFERENCES snippets(id) ON DELETE CASCADE,
+    created DATETIME NOT NULL,
+    PRIMARY KEY (user_id, snippet_id)
+);
+
+CREATE INDEX idx_stars_snippet ON stars(snippet_id);
@@ -0,0 +1,19 @@
+CREATE TABLE collections (
+    id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    title VARCHAR(100) NOT NULL,
+    description TEXT NOT NULL,
+    visibility VARCHAR(10) NOT NULL DEFAULT 'private',
+    created DATETIME NOT NULL
+);
+
+CREATE INDEX idx_collections_user ON collections(user_id);
+
+CREATE TABLE collection_snippets (
+    collection_id INTEGER NOT NULL REFERENCES collections(id) ON DELETE CASCADE,
+    snippet_id INTEGER NOT NULL REFERENCES snippets(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    PRIMARY KEY (collection_id, snippet_id)
+);
+
+CREATE INDEX idx_collection_snippets_snippet ON collection_snippets(snippet_id);
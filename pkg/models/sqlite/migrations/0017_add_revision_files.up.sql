@@ -0,0 +1,15 @@
+CREATE TABLE snippet_revision_files (
+    id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    revision_id INTEGER NOT NULL REFERENCES snippet_revisions(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    language VARCHAR(32) NOT NULL DEFAULT '',
+    content TEXT NOT NULL,
+    CONSTRAINT snippet_revision_files_uc_position UNIQUE (revision_id, position)
+);
+
+-- Revisions used to keep only the content of the first file, which is given
+-- the name and language of the snippet's first file
+INSERT INTO snippet_revision_files (revision_id, position, name, language, content)
+SELECT r.id, 0, COALESCE(f.name, 'file1'), COALESCE(f.language, ''), r.content
+FROM snippet_revisions r LEFT JOIN snippet_files f ON f.snippet_id = r.snippet_id AND f.position = 0;
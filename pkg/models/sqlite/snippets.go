@@ -0,0 +1,596 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"yudhiesh/snippetbox/pkg/models"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Define a SnippetModel type which wraps a sql.DB connection pool
+type SnippetModel struct {
+	DB *sql.DB
+	// The deadline for the queries of each method call, or none if zero
+	Timeout time.Duration
+}
+
+// The columns selected for every snippet, in the order scanSnippet expects
+const snippetColumns = `s.id, s.slug, s.visibility, s.burn_after_reading, s.hashed_password IS NOT NULL, s.user_id, COALESCE(s.forked_from, 0), u.name, s.title, s.content, s.language, s.created, s.expires`
+
+// Copies a row selected with snippetColumns into a new Snippet
+func scanSnippet(rows *sql.Rows) (*models.Snippet, error) {
+	s := &models.Snippet{}
+	err := rows.Scan(&s.ID, &s.Slug, &s.Visibility, &s.BurnAfterReading, &s.Protected, &s.UserID, &s.ForkedFrom, &s.Author, &s.Title, &s.Content, &s.Language, &s.Created, expiryTime{&s.Expires})
+	return s, err
+}
+
+// Runs a query selecting snippetColumns and returns the snippets with their
+// tags loaded
+func querySnippets(ctx context.Context, q querier, stmt string, args ...interface{}) ([]*models.Snippet, error) {
+	rows, err := q.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	snippets := []*models.Snippet{}
+	for rows.Next() {
+		s, err := scanSnippet(rows)
+		if err != nil {
+			return nil, err
+		}
+		snippets = append(snippets, s)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	// Close the result set before running the next query, as the querier may
+	// be a transaction with a single connection
+	rows.Close()
+	if err = loadTags(ctx, q, snippets); err != nil {
+		return nil, err
+	}
+	return snippets, nil
+}
+
+// This will insert a new snippet owned by the given user into the database and
+// return the random slug it was given. The content and language of the first
+// file are also stored as those of the snippet.
+func (m *SnippetModel) Insert(ctx context.Context, userID int, title string, files []*models.File, expires time.Time, visibility string, burn bool, password string, tags []string) (_ string, err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	return m.insert(ctx, 0, userID, title, files, expires, visibility, burn, password, tags)
+}
+
+// Inserts a snippet like Insert, recording the ID of the snippet it was forked
+// from unless that is 0
+func (m *SnippetModel) insert(ctx context.Context, forkedFrom, userID int, title string, files []*models.File, expires time.Time, visibility string, burn bool, password string, tags []string) (string, error) {
+	if len(files) == 0 {
+		return "", models.ErrNoFiles
+	}
+	content, language := files[0].Content, files[0].Language
+	slug, err := models.NewSlug()
+	if err != nil {
+		return "", err
+	}
+	// Like the passwords of users, only a bcrypt hash of the password is
+	// stored. It is made before the transaction begins as it is slow.
+	var hashedPassword sql.NullString
+	if password != "" {
+		h, err := bcrypt.GenerateFromPassword([]byte(password), 12)
+		if err != nil {
+			return "", err
+		}
+		hashedPassword = sql.NullString{String: string(h), Valid: true}
+	}
+	// Snippets which never expire have no expiry time
+	parentID := sql.NullInt64{Int64: int64(forkedFrom), Valid: forkedFrom != 0}
+	expiresAt := sql.NullString{String: formatTime(expires), Valid: !expires.IsZero()}
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return "", err
+	}
+
+	stmt := `INSERT INTO snippets (slug, visibility, burn_after_reading, hashed_password, user_id, forked_from, title, content, language, created, expires)
+	VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, datetime('now'), ?)`
+	result, err := tx.ExecContext(ctx, stmt, slug, visibility, burn, hashedPassword, userID, parentID, title, content, language, expiresAt)
+	if err != nil {
+		tx.Rollback()
+		return "", err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		tx.Rollback()
+		return "", err
+	}
+
+	err = insertFiles(ctx, tx, int(id), files)
+	if err != nil {
+		tx.Rollback()
+		return "", err
+	}
+
+	// Record the published snippet as the first revision in its history
+	err = insertRevision(ctx, tx, int(id), title, files)
+	if err != nil {
+		tx.Rollback()
+		return "", err
+	}
+
+	err = insertTags(ctx, tx, int(id), tags)
+	if err != nil {
+		tx.Rollback()
+		return "", err
+	}
+
+	err = tx.Commit()
+	return slug, err
+}
+
+// Return a single snippet by its ID. ErrDeleted, ErrExpired and ErrBurned are
+// returned for snippets which exist but can no longer be viewed, along with
+// the snippet without its tags and files so that the caller can tell who may
+// know of it.
+func (m *SnippetModel) Get(ctx context.Context, id int) (_ *models.Snippet, err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	return m.get(ctx, "s.id = ?", id)
+}
+
+// Return a single snippet by its slug, like Get
+func (m *SnippetModel) GetBySlug(ctx context.Context, slug string) (_ *models.Snippet, err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	return m.get(ctx, "s.slug = ?", slug)
+}
+
+// Returns the snippet matching the condition, whatever its visibility
+func (m *SnippetModel) get(ctx context.Context, where string, arg interface{}) (*models.Snippet, error) {
+	stmt := `SELECT ` + snippetColumns + `, s.deleted, s.burned, s.expires IS NOT NULL AND s.expires <= datetime('now')
+	FROM snippets s INNER JOIN users u ON u.id = s.user_id
+	WHERE ` + where
+
+	s := &models.Snippet{}
+	var deleted, burned sql.NullTime
+	var expired bool
+	err := m.DB.QueryRowContext(ctx, stmt, arg).Scan(&s.ID, &s.Slug, &s.Visibility, &s.BurnAfterReading, &s.Protected, &s.UserID, &s.ForkedFrom, &s.Author, &s.Title, &s.Content, &s.Language, &s.Created, expiryTime{&s.Expires}, &deleted, &burned, &expired)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrNoRecord
+		}
+		return nil, err
+	}
+	// Burned snippets are deleted too, so this is checked first
+	if burned.Valid {
+		return s, models.ErrBurned
+	}
+	if deleted.Valid {
+		return s, models.ErrDeleted
+	}
+	if expired {
+		return s, models.ErrExpired
+	}
+	if err = loadTags(ctx, m.DB, []*models.Snippet{s}); err != nil {
+		return nil, err
+	}
+	if err = loadFiles(ctx, m.DB, s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Returns a burn-after-reading snippet and burns it, so that it can only be
+// read once. The title and content are wiped along with the history, tags and
+// files, leaving a tombstone which makes later reads return ErrBurned.
+// ErrNoRecord is returned for snippets which aren't burn after reading.
+func (m *SnippetModel) Burn(ctx context.Context, slug string) (_ *models.Snippet, err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	// The transaction holds the database write lock from the start, so a
+	// concurrent read of the same snippet waits and then finds it burned
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	stmt := `SELECT ` + snippetColumns + `
+	FROM snippets s INNER JOIN users u ON u.id = s.user_id
+	WHERE s.slug = ? AND s.burn_after_reading = TRUE AND (s.expires IS NULL OR s.expires > datetime('now')) AND s.deleted IS NULL`
+	snippets, err := querySnippets(ctx, tx, stmt, slug)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if len(snippets) == 0 {
+		tx.Rollback()
+		// A regular read tells why the snippet can't be burned
+		if _, err = m.get(ctx, "s.slug = ?", slug); err != nil {
+			return nil, err
+		}
+		return nil, models.ErrNoRecord
+	}
+	s := snippets[0]
+	if err = loadFiles(ctx, tx, s); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	// The tombstone is marked as deleted as well, which keeps it out of
+	// every listing
+	for _, stmt := range []string{
+		`UPDATE snippets SET title = '', content = '', burned = datetime('now'), deleted = datetime('now') WHERE id = ?`,
+		`DELETE FROM snippet_revisions WHERE snippet_id = ?`,
+		`DELETE FROM snippet_tags WHERE snippet_id = ?`,
+		`DELETE FROM snippet_files WHERE snippet_id = ?`,
+	} {
+		if _, err = tx.ExecContext(ctx, stmt, s.ID); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+
+	err = tx.Commit()
+	return s, err
+}
+
+// Checks the password of a protected snippet, returning ErrInvalidCredentials
+// if it is wrong. Snippets without a password are always unlocked.
+func (m *SnippetModel) Unlock(ctx context.Context, id int, password string) (err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	var hashedPassword sql.NullString
+	stmt := `SELECT hashed_password FROM snippets WHERE id = ?`
+	err = m.DB.QueryRowContext(ctx, stmt, id).Scan(&hashedPassword)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.ErrNoRecord
+		}
+		return err
+	}
+	if !hashedPassword.Valid {
+		return nil
+	}
+
+	err = bcrypt.CompareHashAndPassword([]byte(hashedPassword.String), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return models.ErrInvalidCredentials
+	}
+	return err
+}
+
+// Returns a page of at most limit live public snippets, newest first. When before is
+// given only snippets older than that cursor are returned, and when after is
+// given only snippets newer than it. Like the other listings, it leaves out
+// burn-after-reading and password-protected snippets, whose content mustn't be
+// found by a search.
+func (m *SnippetModel) Latest(ctx context.Context, before, after *models.Cursor, limit int) (_ []*models.Snippet, err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	stmt := `SELECT ` + snippetColumns + `
+	FROM snippets s INNER JOIN users u ON u.id = s.user_id
+	WHERE (s.expires IS NULL OR s.expires > datetime('now')) AND s.deleted IS NULL AND s.visibility = 'public'
+	AND s.burn_after_reading = FALSE AND s.hashed_password IS NULL`
+	args := []interface{}{}
+
+	// Paging backwards walks the index in ascending order so that the LIMIT
+	// keeps the snippets closest to the cursor. They are reversed below.
+	order := "DESC"
+	switch {
+	case before != nil:
+		stmt += ` AND (s.created, s.id) < (?, ?)`
+		args = append(args, formatTime(before.Created), before.ID)
+	case after != nil:
+		stmt += ` AND (s.created, s.id) > (?, ?)`
+		args = append(args, formatTime(after.Created), after.ID)
+		order = "ASC"
+	}
+	stmt += fmt.Sprintf(` ORDER BY s.created %[1]s, s.id %[1]s LIMIT ?`, order)
+	args = append(args, limit)
+
+	snippets, err := querySnippets(ctx, m.DB, stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	if after != nil {
+		for i, j := 0, len(snippets)-1; i < j; i, j = i+1, j-1 {
+			snippets[i], snippets[j] = snippets[j], snippets[i]
+		}
+	}
+	return snippets, nil
+}
+
+// Returns at most limit live public snippets matching the query, most relevant
+// first, using the FTS5 index over the title and content
+func (m *SnippetModel) Search(ctx context.Context, query string, limit int) (_ []*models.Snippet, err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	match := matchExpression(query)
+	if match == "" {
+		return []*models.Snippet{}, nil
+	}
+	stmt := `SELECT ` + snippetColumns + `
+	FROM snippets_fts f
+	INNER JOIN snippets s ON s.id = f.rowid
+	INNER JOIN users u ON u.id = s.user_id
+	WHERE snippets_fts MATCH ? AND (s.expires IS NULL OR s.expires > datetime('now')) AND s.deleted IS NULL
+	AND s.visibility = 'public' AND s.burn_after_reading = FALSE AND s.hashed_password IS NULL
+	ORDER BY bm25(snippets_fts), s.created DESC LIMIT ?`
+	return querySnippets(ctx, m.DB, stmt, match, limit)
+}
+
+// Turns a free text query into an FTS5 match expression which matches any of
+// the words. Each word is quoted so that characters with a special meaning
+// in the FTS5 query syntax are matched literally.
+func matchExpression(query string) string {
+	terms := []string{}
+	for _, word := range strings.Fields(query) {
+		word = strings.ReplaceAll(word, `"`, `""`)
+		terms = append(terms, `"`+word+`"`)
+	}
+	return strings.Join(terms, " OR ")
+}
+
+// Returns at most limit live public snippets with the given tag, newest
+// first
+func (m *SnippetModel) ByTag(ctx context.Context, tag string, limit int) (_ []*models.Snippet, err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	stmt := `SELECT ` + snippetColumns + `
+	FROM snippets s INNER JOIN users u ON u.id = s.user_id
+	INNER JOIN snippet_tags st ON st.snippet_id = s.id
+	INNER JOIN tags t ON t.id = st.tag_id
+	WHERE t.name = ? AND (s.expires IS NULL OR s.expires > datetime('now')) AND s.deleted IS NULL
+	AND s.visibility = 'public' AND s.burn_after_reading = FALSE AND s.hashed_password IS NULL
+	ORDER BY s.created DESC LIMIT ?`
+	return querySnippets(ctx, m.DB, stmt, tag, limit)
+}
+
+// Returns every snippet created by the given user, newest first. Expired
+// snippets are included so that the owner can still see what they posted, but
+// deleted snippets are not.
+func (m *SnippetModel) ByUser(ctx context.Context, userID int) (_ []*models.Snippet, err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	stmt := `SELECT ` + snippetColumns + `
+	FROM snippets s INNER JOIN users u ON u.id = s.user_id
+	WHERE s.user_id = ? AND s.deleted IS NULL ORDER BY s.created DESC`
+	return querySnippets(ctx, m.DB, stmt, userID)
+}
+
+// Copies a live snippet into a new one owned by the given user which expires
+// at the given time, or never if it is zero, and returns the slug of the copy.
+// The copy keeps the title, files, tags and visibility of the snippet but not
+// its password, and records the snippet it was forked from. Whether the user
+// may view the snippet is up to the caller. Burn-after-reading snippets can't
+// be forked, so ErrNoRecord is returned for them.
+func (m *SnippetModel) Fork(ctx context.Context, id, userID int, expires time.Time) (_ string, err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	s, err := m.get(ctx, "s.id = ?", id)
+	if err != nil {
+		return "", err
+	}
+	if s.BurnAfterReading {
+		return "", models.ErrNoRecord
+	}
+	return m.insert(ctx, s.ID, userID, s.Title, s.Files, expires, s.Visibility, false, "", s.Tags)
+}
+
+// Returns at most limit live public snippets forked from the given snippet,
+// newest first
+func (m *SnippetModel) Forks(ctx context.Context, id, limit int) (_ []*models.Snippet, err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	stmt := `SELECT ` + snippetColumns + `
+	FROM snippets s INNER JOIN users u ON u.id = s.user_id
+	WHERE s.forked_from = ? AND (s.expires IS NULL OR s.expires > datetime('now')) AND s.deleted IS NULL
+	AND s.visibility = 'public' AND s.burn_after_reading = FALSE AND s.hashed_password IS NULL
+	ORDER BY s.created DESC, s.id DESC LIMIT ?`
+	return querySnippets(ctx, m.DB, stmt, id, limit)
+}
+
+// Replaces the title, visibility and files of a snippet owned by the given
+// user and stores the new version as the next revision in the snippet's
+// history. The content and language of the first file are also stored as
+// those of the snippet.
+// ErrNoRecord is returned if the snippet doesn't exist, has expired, has been
+// deleted or is owned by somebody else.
+func (m *SnippetModel) Update(ctx context.Context, id, userID int, title, visibility string, files []*models.File) (err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	if len(files) == 0 {
+		return models.ErrNoFiles
+	}
+
+	// The transaction holds the database write lock from the start, so
+	// concurrent edits are given consecutive version numbers
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	stmt := `UPDATE snippets SET title = ?, content = ?, language = ?, visibility = ?
+	WHERE id = ? AND user_id = ? AND (expires IS NULL OR expires > datetime('now')) AND deleted IS NULL`
+	result, err := tx.ExecContext(ctx, stmt, title, files[0].Content, files[0].Language, visibility, id, userID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	// SQLite counts matched rows, even if the values were unchanged
+	n, err := result.RowsAffected()
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if n == 0 {
+		tx.Rollback()
+		return models.ErrNoRecord
+	}
+
+	// The files are replaced as a whole, as they may have been renamed,
+	// reordered, added or removed
+	_, err = tx.ExecContext(ctx, `DELETE FROM snippet_files WHERE snippet_id = ?`, id)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err = insertFiles(ctx, tx, id, files); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err = insertRevision(ctx, tx, id, title, files); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Moves the expiry time of a snippet owned by the given user, or removes it if
+// expires is zero. Expired snippets can be renewed until they are purged, but
+// deleted snippets can't.
+func (m *SnippetModel) Renew(ctx context.Context, slug string, userID int, expires time.Time) (err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	stmt := `UPDATE snippets SET expires = ? WHERE slug = ? AND user_id = ? AND deleted IS NULL`
+	expiresAt := sql.NullString{String: formatTime(expires), Valid: !expires.IsZero()}
+	return execOne(ctx, m.DB, stmt, expiresAt, slug, userID)
+}
+
+// Returns every stored revision of a snippet along with its files, newest
+// first
+func (m *SnippetModel) Revisions(ctx context.Context, id int) (_ []*models.Revision, err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	stmt := `SELECT id, snippet_id, version, title, content, created FROM snippet_revisions
+	WHERE snippet_id = ? ORDER BY version DESC`
+	rows, err := m.DB.QueryContext(ctx, stmt, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []*models.Revision{}
+	for rows.Next() {
+		rev := &models.Revision{}
+		err := rows.Scan(&rev.ID, &rev.SnippetID, &rev.Version, &rev.Title, &rev.Content, &rev.Created)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, rev)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	if err = loadRevisionFiles(ctx, m.DB, id, revisions); err != nil {
+		return nil, err
+	}
+	return revisions, nil
+}
+
+// Soft-deletes a snippet owned by the given user
+func (m *SnippetModel) Delete(ctx context.Context, slug string, userID int) (err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	stmt := `UPDATE snippets SET deleted = datetime('now')
+	WHERE slug = ? AND user_id = ? AND deleted IS NULL`
+	return execOne(ctx, m.DB, stmt, slug, userID)
+}
+
+// Restores a snippet owned by the given user which was deleted less than
+// window ago. Burned snippets are gone for good.
+func (m *SnippetModel) Restore(ctx context.Context, slug string, userID int, window time.Duration) (err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	stmt := `UPDATE snippets SET deleted = NULL
+	WHERE slug = ? AND user_id = ? AND deleted > datetime('now', ?) AND burned IS NULL`
+	return execOne(ctx, m.DB, stmt, slug, userID, modifier(-window))
+}
+
+// Returns the snippets of the given user which were deleted less than window
+// ago and can therefore still be restored
+func (m *SnippetModel) Deleted(ctx context.Context, userID int, window time.Duration) (_ []*models.Snippet, err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	stmt := `SELECT ` + snippetColumns + `, s.deleted
+	FROM snippets s INNER JOIN users u ON u.id = s.user_id
+	WHERE s.user_id = ? AND s.deleted > datetime('now', ?) AND s.burned IS NULL
+	ORDER BY s.deleted DESC`
+	rows, err := m.DB.QueryContext(ctx, stmt, userID, modifier(-window))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	snippets := []*models.Snippet{}
+	for rows.Next() {
+		s := &models.Snippet{}
+		err := rows.Scan(&s.ID, &s.Slug, &s.Visibility, &s.BurnAfterReading, &s.Protected, &s.UserID, &s.ForkedFrom, &s.Author, &s.Title, &s.Content, &s.Language, &s.Created, expiryTime{&s.Expires}, &s.Deleted)
+		if err != nil {
+			return nil, err
+		}
+		snippets = append(snippets, s)
+	}
+	return snippets, rows.Err()
+}
+
+// Permanently deletes up to limit expired snippets, along with their history
+// and tags, and returns how many were deleted
+func (m *SnippetModel) PurgeExpired(ctx context.Context, limit int) (_ int, err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	// SQLite isn't usually built with support for DELETE ... LIMIT, so the
+	// batch is picked by a subquery. The revisions and tags of a snippet are
+	// removed by ON DELETE CASCADE and its search entry by a trigger.
+	stmt := `DELETE FROM snippets WHERE id IN (
+		SELECT id FROM snippets WHERE expires <= datetime('now') LIMIT ?
+	)`
+	result, err := m.DB.ExecContext(ctx, stmt, limit)
+	if err != nil {
+		return 0, err
+	}
+	n, err := result.RowsAffected()
+	return int(n), err
+}
+
+// Runs a statement which should change exactly one row, returning
+// ErrNoRecord if it didn't change any
+func execOne(ctx context.Context, db *sql.DB, stmt string, args ...interface{}) error {
+	result, err := db.ExecContext(ctx, stmt, args...)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return models.ErrNoRecord
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+// Package sqlite implements the snippet and user models on top of an SQLite
+// database using a pure Go driver, so no C toolchain is needed to build it.
+package sqlite
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// The versioned schema migrations, embedded into the binary. They are applied
+// with the migrate package.
+var Migrations, _ = fs.Sub(migrations, "migrations")
+
+// SQLite has no date type, so times are stored as text in the same format
+// produced by datetime('now'). Text in this format sorts chronologically.
+const timeFormat = "2006-01-02 15:04:05"
+
+// Opens the SQLite database at the given path, creating it if it doesn't exist
+// yet. The schema has to be created by applying the migrations.
+func Open(path string) (*sql.DB, error) {
+	// Foreign keys are off by default in SQLite and pragmas only apply to a
+	// single connection, so they are set through the DSN for every connection
+	// in the pool. Write transactions take the lock when they begin, instead
+	// of when they first write, so that a busy database makes them wait
+	// rather than fail.
+	dsn := fmt.Sprintf("file:%s?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_txlock=immediate", path)
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+// Formats a time for comparison against a stored time
+func formatTime(t time.Time) string {
+	return t.UTC().Format(timeFormat)
+}
+
+// Formats a duration as an SQLite date modifier, which are limited to whole
+// seconds
+func modifier(d time.Duration) string {
+	return fmt.Sprintf("%+d seconds", int64(d.Seconds()))
+}
+
+// Scans a nullable expiry time into a snippet, leaving the zero time for
+// snippets which never expire
+type expiryTime struct {
+	t *time.Time
+}
+
+func (e expiryTime) Scan(src interface{}) error {
+	var nt sql.NullTime
+	if err := nt.Scan(src); err != nil {
+		return err
+	}
+	*e.t = nt.Time
+	return nil
+}
@@ -0,0 +1,53 @@
+package sqlite
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"yudhiesh/snippetbox/pkg/migrate"
+	"yudhiesh/snippetbox/pkg/models"
+)
+
+// Creates a new database in a temporary directory with the migrations applied
+// and the test data loaded. The directory is removed when the test finishes.
+func newTestDB(t *testing.T) (*sql.DB, func()) {
+	db, err := Open(filepath.Join(t.TempDir(), "test_snippetbox.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	migrator, err := migrate.New(db, Migrations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = migrator.Up(); err != nil {
+		t.Fatal(err)
+	}
+
+	script, err := ioutil.ReadFile("./testdata/setup.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = db.Exec(string(script))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db, func() {
+		db.Close()
+	}
+}
+
+// Expiry times for snippets inserted by the tests
+var (
+	nextWeek  = time.Now().AddDate(0, 0, 7)
+	yesterday = time.Now().AddDate(0, 0, -1)
+)
+
+// Returns the files of a snippet holding a single file
+func oneFile(content, language string) []*models.File {
+	return []*models.File{{Name: "file1", Language: language, Content: content}}
+}
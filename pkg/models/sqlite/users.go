@@ -0,0 +1,118 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+	"yudhiesh/snippetbox/pkg/models"
+
+	"golang.org/x/crypto/bcrypt"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+type UserModel struct {
+	DB      *sql.DB
+	Timeout time.Duration // as for SnippetModel
+}
+
+// Insert a user into the users table
+func (m *UserModel) Insert(ctx context.Context, name, email, password string) (err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), 12)
+	if err != nil {
+		return err
+	}
+	stmt := `INSERT INTO users (name, email, hashed_password, created)
+	VALUES(?, ?, ?, datetime('now'))`
+	_, err = m.DB.ExecContext(ctx, stmt, name, email, string(hashedPassword))
+	if err != nil {
+		var sqliteError *sqlite.Error
+		if errors.As(err, &sqliteError) {
+			if sqliteError.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE && strings.Contains(sqliteError.Error(), "users.email") {
+				return models.ErrDuplicateEmail
+			}
+		}
+		return err
+	}
+	return nil
+}
+
+// Authenticate the users email and password
+func (m *UserModel) Authenticate(ctx context.Context, email, password string) (_ int, err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	// Retrieve the id and hashedPassword from the email
+	// If no matching email exists, or the user is not active, we return the
+	// ErrInvalidCredentials
+	var id int
+	var hashedPassword []byte
+	stmt := `SELECT id, hashed_password FROM users WHERE email = ? AND active = TRUE`
+	err = m.DB.QueryRowContext(ctx, stmt, email).Scan(&id, &hashedPassword)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, models.ErrInvalidCredentials
+		}
+		return 0, err
+	}
+
+	err = bcrypt.CompareHashAndPassword(hashedPassword, []byte(password))
+	if err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return 0, models.ErrInvalidCredentials
+		}
+		return 0, err
+	}
+	return id, nil
+}
+
+func (m *UserModel) Get(ctx context.Context, id int) (_ *models.User, err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	u := &models.User{}
+
+	stmt := `SELECT id, name, email, created, active FROM users WHERE id = ?`
+	err = m.DB.QueryRowContext(ctx, stmt, id).Scan(&u.ID, &u.Name, &u.Email, &u.Created, &u.Active)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrNoRecord
+		}
+		return nil, err
+	}
+	return u, nil
+}
+
+func (m *UserModel) ChangePassword(ctx context.Context, id int, currentPassword, newPassword string) (err error) {
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	var currentHashedPassword []byte
+	row := m.DB.QueryRowContext(ctx, "SELECT hashed_password FROM users WHERE id = ?", id)
+	err = row.Scan(&currentHashedPassword)
+	if err != nil {
+		return err
+	}
+
+	err = bcrypt.CompareHashAndPassword(currentHashedPassword, []byte(currentPassword))
+	if err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return models.ErrInvalidCredentials
+		}
+		return err
+	}
+
+	newHashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), 12)
+	if err != nil {
+		return err
+	}
+
+	stmt := "UPDATE users SET hashed_password = ? WHERE id = ?"
+	_, err = m.DB.ExecContext(ctx, stmt, string(newHashedPassword), id)
+	return err
+}
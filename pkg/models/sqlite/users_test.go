@@ -0,0 +1,13 @@
+package sqlite
+
+import (
+	"testing"
+	"yudhiesh/snippetbox/pkg/models/modeltest"
+)
+
+func TestUserModel(t *testing.T) {
+	modeltest.TestUsers(t, func(t *testing.T) (modeltest.Users, func()) {
+		db, teardown := newTestDB(t)
+		return &UserModel{DB: db}, teardown
+	})
+}
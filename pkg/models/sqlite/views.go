@@ -0,0 +1,79 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+	"yudhiesh/snippetbox/pkg/models"
+)
+
+type ViewModel struct {
+	DB      *sql.DB
+	Timeout time.Duration // as for SnippetModel
+}
+
+// Adds to the view counts of snippets, given by snippet ID, in a single
+// transaction. Snippets which have since been purged are skipped.
+func (m *ViewModel) Add(ctx context.Context, views map[int]int) (err error) {
+	if len(views) == 0 {
+		return nil
+	}
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	stmt, err := tx.PrepareContext(ctx, `UPDATE snippets SET views = views + ? WHERE id = ?`)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	defer stmt.Close()
+
+	for id, n := range views {
+		if _, err = stmt.ExecContext(ctx, n, id); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Fills in the Views field of each snippet
+func (m *ViewModel) Count(ctx context.Context, snippets ...*models.Snippet) (err error) {
+	if len(snippets) == 0 {
+		return nil
+	}
+	ctx, done := models.QueryContext(ctx, m.Timeout)
+	defer done(&err)
+
+	byID := make(map[int][]*models.Snippet, len(snippets))
+	args := make([]interface{}, 0, len(snippets))
+	for _, s := range snippets {
+		s.Views = 0
+		byID[s.ID] = append(byID[s.ID], s)
+		args = append(args, s.ID)
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(args)), ", ")
+	stmt := `SELECT id, views FROM snippets WHERE id IN (` + placeholders + `)`
+	rows, err := m.DB.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id, n int
+		if err := rows.Scan(&id, &n); err != nil {
+			return err
+		}
+		for _, s := range byID[id] {
+			s.Views = n
+		}
+	}
+	return rows.Err()
+}
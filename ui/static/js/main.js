@@ -5,4 +5,117 @@ for (var i = 0; i < navLinks.length; i++) {
 		link.classList.add("live");
 		break;
 	}
-}
\ No newline at end of file
+}
+// Highlights the lines of a snippet linked to as #L10, or a range as #L10-L20.
+// The lines of the second file of a snippet are linked to as #F2L10, and so on.
+function highlightLines() {
+	var lines = document.querySelectorAll(".chroma .line");
+	for (var i = 0; i < lines.length; i++) {
+		lines[i].classList.remove("hl");
+	}
+	var match = /^#((?:F\d+)?L)(\d+)(?:-\1(\d+))?$/.exec(window.location.hash);
+	if (!match) {
+		return;
+	}
+	var prefix = match[1];
+	var start = parseInt(match[2], 10);
+	var end = match[3] ? parseInt(match[3], 10) : start;
+	if (end < start) {
+		var t = start;
+		start = end;
+		end = t;
+	}
+	var first = null;
+	for (var n = start; n <= end; n++) {
+		var number = document.getElementById(prefix + n);
+		if (!number) {
+			break;
+		}
+		number.parentNode.classList.add("hl");
+		first = first || number;
+	}
+	// Comments are anchored to the lines linked to
+	var field = document.querySelector("form.comment input[name=lines]");
+	if (field) {
+		field.value = window.location.hash.slice(1);
+	}
+	if (first) {
+		// The source of a Markdown snippet is hidden until it is opened
+		var details = first.closest("details");
+		if (details) {
+			details.open = true;
+		}
+		first.scrollIntoView();
+	}
+}
+
+// Shift clicking a line number links to the range from the line of the same
+// file linked to before it
+var lastLine = null;
+var lastPrefix = null;
+var lineLinks = document.querySelectorAll(".chroma .ln a");
+for (var i = 0; i < lineLinks.length; i++) {
+	lineLinks[i].addEventListener("click", function(e) {
+		var match = /^#((?:F\d+)?L)(\d+)$/.exec(this.getAttribute("href"));
+		if (!match) {
+			return;
+		}
+		var prefix = match[1];
+		var line = parseInt(match[2], 10);
+		if (e.shiftKey && lastLine && prefix == lastPrefix) {
+			e.preventDefault();
+			var start = Math.min(lastLine, line);
+			var end = Math.max(lastLine, line);
+			window.location.hash = "#" + prefix + start + "-" + prefix + end;
+			return;
+		}
+		lastLine = line;
+		lastPrefix = prefix;
+	});
+}
+
+window.addEventListener("hashchange", highlightLines);
+highlightLines();
+
+// The create form starts with a single file. More can be added by copying
+// the last one without its values, and removed while more than one is left.
+var files = document.querySelector(".files");
+if (files) {
+	var updateRemoveButtons = function() {
+		var buttons = files.querySelectorAll(".remove-file");
+		for (var i = 0; i < buttons.length; i++) {
+			buttons[i].hidden = buttons.length == 1;
+		}
+	};
+	files.addEventListener("click", function(e) {
+		if (e.target.classList.contains("remove-file")) {
+			e.target.closest(".file").remove();
+			updateRemoveButtons();
+		}
+	});
+	files.querySelector(".add-file").addEventListener("click", function() {
+		var all = files.querySelectorAll(".file");
+		var file = all[all.length - 1].cloneNode(true);
+		var errors = file.querySelectorAll(".error");
+		for (var i = 0; i < errors.length; i++) {
+			errors[i].remove();
+		}
+		file.querySelector("input").value = "";
+		file.querySelector("textarea").value = "";
+		file.querySelector("select").value = "";
+		this.parentNode.insertBefore(file, this);
+		updateRemoveButtons();
+	});
+	updateRemoveButtons();
+}
+
+// A custom expiry time is entered in the browser's time zone, whose offset
+// from UTC at that time is sent along with it. Without this it is read as UTC.
+var expiresAt = document.querySelector("input[name=expires_at]");
+if (expiresAt) {
+	var expiresOffset = expiresAt.form.querySelector("input[name=expires_offset]");
+	expiresAt.form.querySelector(".expires-zone").textContent = "local time";
+	expiresAt.form.addEventListener("submit", function() {
+		expiresOffset.value = expiresAt.value ? new Date(expiresAt.value).getTimezoneOffset() : "";
+	});
+}